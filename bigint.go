@@ -0,0 +1,129 @@
+package erlpack
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+)
+
+// Defines the largest number of bytes a big integer can have.
+const maxBigBytes = 1 << 20
+
+// Used to read a big integer (SMALL_BIG_EXT or LARGE_BIG_EXT). Integers which fit in a int64 or uint64 are returned as
+// one, and anything larger is returned as a *big.Int.
+func readBig(DataType byte, r unpackReader) (interface{}, error) {
+	// Get the number of encoded bytes.
+	var encodedBytes uint32
+	if DataType == 'n' {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, errors.New("unable to read int64 byte count")
+		}
+		encodedBytes = uint32(b)
+	} else {
+		b := make([]byte, 4)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, errors.New("unable to read big integer byte count")
+		}
+		encodedBytes = binary.BigEndian.Uint32(b)
+		if encodedBytes > maxBigBytes {
+			return nil, errors.New("integer is too large")
+		}
+	}
+
+	// Get the signature.
+	signatureChar, err := r.ReadByte()
+	if err != nil {
+		return nil, errors.New("unable to read int64 signature")
+	}
+	negative := signatureChar == 1
+
+	// Read the bytes (which are little endian).
+	digits, err := readBytes(r, encodedBytes)
+	if err != nil {
+		return nil, errors.New("int64 length greater than array")
+	}
+
+	// Use a uint64 if the integer fits in one.
+	trimmed := len(digits)
+	for trimmed > 0 && digits[trimmed-1] == 0 {
+		trimmed--
+	}
+	if trimmed <= 8 {
+		u := uint64(0)
+		for i := trimmed - 1; i >= 0; i-- {
+			u = u<<8 | uint64(digits[i])
+		}
+		if !negative {
+			if u > math.MaxInt64 {
+				return u, nil
+			}
+			return int64(u), nil
+		}
+		if u <= 1<<63 {
+			return int64(u) * -1, nil
+		}
+	}
+
+	// Make a big integer.
+	be := make([]byte, trimmed)
+	for i := range be {
+		be[i] = digits[trimmed-1-i]
+	}
+	n := new(big.Int).SetBytes(be)
+	if negative {
+		n.Neg(n)
+	}
+	return n, nil
+}
+
+// Used to get a big integer from a unpacked integer.
+func bigIntFromTerm(Term interface{}) (*big.Int, bool) {
+	switch x := Term.(type) {
+	case uint8:
+		return big.NewInt(int64(x)), true
+	case int32:
+		return big.NewInt(int64(x)), true
+	case int64:
+		return big.NewInt(x), true
+	case uint64:
+		return new(big.Int).SetUint64(x), true
+	case *big.Int:
+		return new(big.Int).Set(x), true
+	default:
+		return nil, false
+	}
+}
+
+// Used to pack a big integer using the smallest encoding which fits it.
+func packBigInt(Data *big.Int, pad *scratchpad) {
+	if Data.IsInt64() {
+		packInteger(Data.Int64(), pad)
+		return
+	}
+	if Data.IsUint64() {
+		packSmallBig(Data.Uint64(), false, pad)
+		return
+	}
+
+	// Get the little endian bytes of the absolute value.
+	be := new(big.Int).Abs(Data).Bytes()
+	a := make([]byte, 0, len(be)+6)
+	if len(be) <= 255 {
+		a = append(a, 'n', byte(len(be)))
+	} else {
+		a = append(a, 'o', 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(a[1:], uint32(len(be)))
+	}
+	if Data.Sign() < 0 {
+		a = append(a, 1)
+	} else {
+		a = append(a, 0)
+	}
+	for i := len(be) - 1; i >= 0; i-- {
+		a = append(a, be[i])
+	}
+	pad.endAppend(a...)
+}
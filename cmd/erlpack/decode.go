@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Used to decode a term to JSON.
+func runDecode(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("decode", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	annotate := flags.Bool("annotate", false, "write atoms, tuples and binaries which aren't valid UTF-8 as annotated objects")
+	compact := flags.Bool("compact", false, "write the JSON on a single line")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	data, err := readInput(flags.Args(), stdin)
+	if err == nil {
+		data, err = decode(data, *annotate, *compact)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "erlpack decode:", err)
+		return 1
+	}
+	stdout.Write(data)
+	return 0
+}
+
+// Used to get the transcoder options for the annotate flag. The annotations are always read when encoding.
+func transcodeOptions(annotate bool) erlpack.TranscodeOptions {
+	if !annotate {
+		return erlpack.TranscodeOptions{}
+	}
+	return erlpack.TranscodeOptions{
+		Atoms:       erlpack.AtomRuleAnnotated,
+		Tuples:      erlpack.TuplesAnnotated,
+		InvalidUTF8: erlpack.InvalidUTF8Base64,
+	}
+}
+
+// Used to decode the term to JSON.
+func decode(data []byte, annotate, compact bool) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := erlpack.TranscodeToJSONWithOptions(buf, bytes.NewReader(data), transcodeOptions(annotate)); err != nil {
+		return nil, err
+	}
+	if compact {
+		return append(buf.Bytes(), '\n'), nil
+	}
+
+	// Indent the JSON.
+	indented := &bytes.Buffer{}
+	if err := json.Indent(indented, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	indented.WriteByte('\n')
+	return indented.Bytes(), nil
+}
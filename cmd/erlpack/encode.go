@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Used to encode JSON to a term.
+func runEncode(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("encode", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	atomKeys := flags.Bool("atom-keys", false, "write object keys as atoms instead of binaries")
+	atoms := flags.String("atoms", "", "comma separated list of string values to write as atoms instead of binaries")
+	tuples := flags.Bool("tuples", false, "write arrays as tuples instead of lists")
+	compress := flags.Bool("compress", false, "compress the term")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	opts := transcodeOptions(true)
+	opts.AtomKeys = *atomKeys
+	opts.ArraysAsTuples = *tuples
+	if *atoms != "" {
+		opts.AtomStrings = strings.Split(*atoms, ",")
+	}
+
+	data, err := readInput(flags.Args(), stdin)
+	if err == nil {
+		data, err = encode(data, opts, *compress)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "erlpack encode:", err)
+		return 1
+	}
+	stdout.Write(data)
+	return 0
+}
+
+// Used to encode the JSON to a term.
+func encode(data []byte, opts erlpack.TranscodeOptions, compress bool) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := erlpack.TranscodeFromJSONWithOptions(buf, bytes.NewReader(data), opts); err != nil {
+		return nil, err
+	}
+	if !compress {
+		return buf.Bytes(), nil
+	}
+	return erlpack.PackWithOptions(erlpack.RawData(buf.Bytes()[1:]), erlpack.EncoderOptions{Compress: true})
+}
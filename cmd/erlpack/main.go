@@ -0,0 +1,81 @@
+// Command erlpack is used to convert between the external term format and JSON when debugging.
+//
+// Usage:
+//
+//	erlpack decode [-annotate] [-compact] [file]
+//	erlpack encode [-atom-keys] [-atoms a,b,...] [-tuples] [-compress] [file]
+//	erlpack roundtrip [file]
+//
+// When no file is given, the data is read from stdin. The output is always written to stdout.
+//
+// decode writes the term as JSON using erlpack.TranscodeToJSON, so maps are written in the order their pairs were
+// packed. By default, atoms and binaries become strings and tuples become arrays, so the output is easy to read but
+// can't be turned back into the same term. With -annotate, these are written as objects with a single key instead:
+//
+//	{"$atom": "ok"}     an atom
+//	{"$tuple": [...]}   a tuple
+//	{"$base64": "AAE="} a binary which is not valid UTF-8
+//
+// Map keys are always written as strings, and funs, pids, ports, references and improper lists can't be written.
+//
+// encode reads JSON and writes the term using erlpack.TranscodeFromJSON. Annotated objects are always turned back
+// into the term they describe. Other strings become binaries, objects become maps with binary keys and arrays become
+// lists unless a rule says otherwise.
+//
+// roundtrip unpacks the term and packs it again with the Deterministic option, then checks it unpacks to the same
+// term. The bytes can differ where Erlang uses a format Pack doesn't write, such as STRING_EXT or ATOM_EXT, so output
+// from term_to_binary passes. If the terms differ, the offset of the first differing byte is printed. Compressed input
+// is compared after it has been inflated.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Defines a subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+var commands = []command{
+	{name: "decode", summary: "convert a term to JSON", run: runDecode},
+	{name: "encode", summary: "convert JSON to a term", run: runEncode},
+	{name: "roundtrip", summary: "check a term unpacks to the same term after being packed again", run: runRoundTrip},
+}
+
+// Used to run the command with the arguments specified and return the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		for _, c := range commands {
+			if c.name == args[0] {
+				return c.run(args[1:], stdin, stdout, stderr)
+			}
+		}
+		fmt.Fprintf(stderr, "erlpack: unknown command %q\n", args[0])
+	}
+	fmt.Fprintln(stderr, "usage: erlpack <command> [flags] [file]\n\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+	return 2
+}
+
+// Used to read the file specified, or stdin if no file was specified.
+func readInput(args []string, stdin io.Reader) ([]byte, error) {
+	switch len(args) {
+	case 0:
+		return io.ReadAll(stdin)
+	case 1:
+		return os.ReadFile(args[0])
+	default:
+		return nil, fmt.Errorf("expected at most 1 file, got %d", len(args))
+	}
+}
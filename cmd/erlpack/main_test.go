@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// testTerm is {"op" => ok, "d" => {1, <<255, 0>>}, k => 1.0} packed with the Deterministic option.
+const testTerm = "\x83t\x00\x00\x00\x03w\x01kF\x3f\xf0\x00\x00\x00\x00\x00\x00m\x00\x00\x00\x01dh\x02a\x01m\x00\x00\x00\x02\xff\x00" +
+	"m\x00\x00\x00\x02opw\x02ok"
+
+// Used to run the command and return the exit code and output.
+func runTest(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(args, strings.NewReader(stdin), stdout, stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// TestDecode is used to test decoding a term to JSON.
+func TestDecode(t *testing.T) {
+	code, out, errOut := runTest(t, testTerm, "decode", "-compact")
+	if code != 0 {
+		t.Fatal(errOut)
+	}
+	if expected := "{\"k\":1,\"d\":[1,\"�\\u0000\"],\"op\":\"ok\"}\n"; out != expected {
+		t.Fatalf("expected %q, got %q", expected, out)
+	}
+
+	code, out, errOut = runTest(t, testTerm, "decode", "-compact", "-annotate")
+	if code != 0 {
+		t.Fatal(errOut)
+	}
+	expected := `{"k":1,"d":{"$tuple":[1,{"$base64":"/wA="}]},"op":{"$atom":"ok"}}` + "\n"
+	if out != expected {
+		t.Fatalf("expected %q, got %q", expected, out)
+	}
+
+	// The JSON is indented by default.
+	code, out, errOut = runTest(t, "\x83l\x00\x00\x00\x01a\x01j", "decode")
+	if code != 0 || out != "[\n  1\n]\n" {
+		t.Fatal(code, out, errOut)
+	}
+}
+
+// TestEncode is used to test encoding JSON to a term with the rules.
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		json     string
+		expected string
+	}{
+		{
+			name:     "defaults",
+			json:     `{"op": 1, "d": [null, true, "x", 1.5, 4294967296]}`,
+			expected: "\x83t\x00\x00\x00\x02m\x00\x00\x00\x02opa\x01m\x00\x00\x00\x01dl\x00\x00\x00\x05w\x03nilw\x04truem\x00\x00\x00\x01xF\x3f\xf8\x00\x00\x00\x00\x00\x00n\x05\x00\x00\x00\x00\x00\x01j",
+		},
+		{
+			name:     "rules",
+			args:     []string{"-atom-keys", "-atoms", "ok,error", "-tuples"},
+			json:     `{"status": ["ok", "fine"]}`,
+			expected: "\x83t\x00\x00\x00\x01w\x06statush\x02w\x02okm\x00\x00\x00\x04fine",
+		},
+		{
+			name:     "annotations",
+			json:     `{"$tuple": [{"$atom": "a"}, {"$base64": "/wA="}, {"$x": 1}]}`,
+			expected: "\x83h\x03w\x01am\x00\x00\x00\x02\xff\x00t\x00\x00\x00\x01m\x00\x00\x00\x02$xa\x01",
+		},
+		{
+			name:     "big integers",
+			json:     `[1, 18446744073709551616]`,
+			expected: "\x83l\x00\x00\x00\x02a\x01n\x09\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01j",
+		},
+	}
+	for _, tt := range tests {
+		code, out, errOut := runTest(t, tt.json, append([]string{"encode"}, tt.args...)...)
+		if code != 0 {
+			t.Fatal(tt.name, errOut)
+		}
+		if out != tt.expected {
+			t.Fatalf("%s: expected %q, got %q", tt.name, tt.expected, out)
+		}
+	}
+
+	// Compressed terms should decode to the same JSON.
+	long := `["` + strings.Repeat("a", 100) + `"]`
+	code, out, errOut := runTest(t, long, "encode", "-compress")
+	if code != 0 || out[1] != 'P' {
+		t.Fatal(code, errOut)
+	}
+	if code, out, errOut = runTest(t, out, "decode", "-compact"); code != 0 || out != long+"\n" {
+		t.Fatal(code, out, errOut)
+	}
+
+	for _, invalid := range []string{`{"$atom": 1}`, `{"$atom": "a", "b": 1}`, `{"$tuple": 1}`, `[1] [2]`, `[`} {
+		if code, _, _ := runTest(t, invalid, "encode"); code != 1 {
+			t.Fatalf("%s: expected exit code 1, got %d", invalid, code)
+		}
+	}
+}
+
+// TestAnnotatedRoundTrip is used to test that decoding with annotations and encoding gives the original term.
+func TestAnnotatedRoundTrip(t *testing.T) {
+	terms := []string{
+		"\x83h\x02w\x02okm\x00\x00\x00\x02\xff\x00",
+		"\x83l\x00\x00\x00\x02h\x00t\x00\x00\x00\x00j",
+		"\x83t\x00\x00\x00\x01m\x00\x00\x00\x02$xa\x01",
+	}
+	for _, term := range terms {
+		code, out, errOut := runTest(t, term, "decode", "-annotate")
+		if code != 0 {
+			t.Fatal(errOut)
+		}
+		code, out, errOut = runTest(t, out, "encode")
+		if code != 0 {
+			t.Fatal(errOut)
+		}
+		if out != term {
+			t.Fatalf("expected %q, got %q", term, out)
+		}
+	}
+}
+
+// TestRoundTrip is used to test checking if a term re-encodes to the same term.
+func TestRoundTrip(t *testing.T) {
+	compressed, err := erlpack.PackWithOptions([]interface{}{"hello", "hello", "hello", "hello", "hello"}, erlpack.EncoderOptions{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, term := range []string{
+		testTerm,
+		string(compressed),
+		// term_to_binary("abc"), which is packed as STRING_EXT.
+		"\x83k\x00\x03abc",
+		// term_to_binary({ok, "abc"}) from OTP 25, which packs atoms as ATOM_EXT.
+		"\x83h\x02d\x00\x02okk\x00\x03abc",
+	} {
+		if code, out, errOut := runTest(t, term, "roundtrip"); code != 0 || out != "ok\n" {
+			t.Fatal(code, out, errOut)
+		}
+	}
+
+	// A int32 which fits in a small int isn't packed the same way.
+	code, _, errOut := runTest(t, "\x83b\x00\x00\x00\x01", "roundtrip")
+	if code != 1 || !strings.Contains(errOut, "differs at byte 0") {
+		t.Fatal(code, errOut)
+	}
+}
+
+// TestUsage is used to test the exit code for invalid commands.
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{{}, {"nope"}, {"decode", "-nope"}, {"decode", "a", "b"}} {
+		if code, _, _ := runTest(t, "", args...); code == 0 {
+			t.Fatal("expected non-zero exit code for", args)
+		}
+	}
+}
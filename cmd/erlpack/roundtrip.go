@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Used to check a term unpacks to the same term after it is packed again.
+func runRoundTrip(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("roundtrip", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	data, err := readInput(flags.Args(), stdin)
+	if err == nil {
+		err = roundTrip(data)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "erlpack roundtrip:", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, "ok")
+	return 0
+}
+
+// Used to unpack the term and pack it again, returning a error if the packed term unpacks to a different term.
+// The bytes are allowed to differ, since Erlang packs some terms in formats Pack doesn't write (such as STRING_EXT
+// and ATOM_EXT).
+func roundTrip(data []byte) error {
+	// Get the original term. This is inflated if it was compressed.
+	var original erlpack.RawData
+	if err := erlpack.Unpack(data, &original); err != nil {
+		return err
+	}
+
+	// Unpack and pack the term, and then unpack it again.
+	var term interface{}
+	if err := erlpack.Unpack(data, &term); err != nil {
+		return err
+	}
+	b, err := erlpack.PackWithOptions(term, erlpack.EncoderOptions{Deterministic: true})
+	if err != nil {
+		return err
+	}
+	var again interface{}
+	if err = erlpack.Unpack(b, &again); err != nil {
+		return err
+	}
+	if reflect.DeepEqual(term, again) {
+		return nil
+	}
+
+	// Find where the bytes differ.
+	packed := b[1:]
+	i := 0
+	for i < len(original) && i < len(packed) && original[i] == packed[i] {
+		i++
+	}
+	return fmt.Errorf("packed term differs at byte %d of the term: original % x, packed % x",
+		i, window(original, i), window(packed, i))
+}
+
+// Used to get up to 8 bytes from the offset specified.
+func window(b []byte, i int) []byte {
+	end := i + 8
+	if end > len(b) {
+		end = len(b)
+	}
+	return b[i:end]
+}
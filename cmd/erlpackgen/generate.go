@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Defines the import path of erlpack.
+const erlpackImport = "github.com/JakeMakesStuff/go-erlpack"
+
+// Defines how a field is packed and unpacked.
+type fieldKind int
+
+const (
+	// The field is packed and unpacked with reflection.
+	kindValue fieldKind = iota
+	kindString
+	kindBool
+	kindInt
+	kindFloat
+	kindBinary
+	kindAtom
+	kindRaw
+)
+
+// Defines a struct field which methods are generated for.
+type genField struct {
+	name      string
+	key       string
+	kind      fieldKind
+	omitEmpty bool
+	asString  bool
+}
+
+// Defines a struct which methods are generated for.
+type genStruct struct {
+	name   string
+	fields []genField
+}
+
+// Used to generate the methods for the types specified within the file.
+func generate(filename string, types []string) ([]byte, error) {
+	// Parse the file.
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the name erlpack is imported as within the file.
+	erlpackName := ""
+	for _, imp := range f.Imports {
+		if path, _ := strconv.Unquote(imp.Path.Value); path == erlpackImport {
+			erlpackName = "erlpack"
+			if imp.Name != nil {
+				erlpackName = imp.Name.Name
+			}
+		}
+	}
+
+	// Get each struct.
+	structs := make([]genStruct, 0, len(types))
+	for _, name := range types {
+		name = strings.TrimSpace(name)
+		spec := findType(f, name)
+		if spec == nil {
+			return nil, fmt.Errorf("type %s not found in %s", name, filename)
+		}
+		s, err := parseStruct(spec, erlpackName)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		structs = append(structs, s)
+	}
+
+	// Write the code and format it.
+	return format.Source(writeCode(f.Name.Name, structs))
+}
+
+// Used to find a type declaration within the file.
+func findType(f *ast.File, name string) *ast.TypeSpec {
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			if t := spec.(*ast.TypeSpec); t.Name.Name == name {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// Used to get the fields of a struct in the order they were declared.
+func parseStruct(spec *ast.TypeSpec, erlpackName string) (genStruct, error) {
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return genStruct{}, errors.New("type is not a struct")
+	}
+	if spec.TypeParams != nil {
+		return genStruct{}, errors.New("generic types are not supported")
+	}
+
+	s := genStruct{name: spec.Name.Name}
+	keys := map[string]bool{}
+	for _, field := range st.Fields.List {
+		// Get the tag.
+		tag := ""
+		if field.Tag != nil {
+			unquoted, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return genStruct{}, err
+			}
+			tag = reflect.StructTag(unquoted).Get("erlpack")
+		}
+		if tag == "-" {
+			continue
+		}
+		options := strings.Split(tag, ",")
+
+		// Get the names of the fields. Embedded fields are named after their type.
+		names := []string{}
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+		if len(field.Names) == 0 {
+			names = append(names, embeddedName(field.Type))
+		}
+
+		for _, name := range names {
+			if !ast.IsExported(name) {
+				continue
+			}
+			g := genField{name: name, key: options[0], kind: kindOf(field.Type, erlpackName)}
+			if g.key == "" {
+				g.key = name
+			}
+			for _, option := range options[1:] {
+				switch option {
+				case "omitempty":
+					g.omitEmpty = true
+				case "string":
+					g.asString = true
+				case "flatten":
+					return genStruct{}, fmt.Errorf("field %s: flatten is not supported", name)
+				}
+			}
+			if keys[g.key] {
+				return genStruct{}, fmt.Errorf("field %s: duplicate key %q", name, g.key)
+			}
+			keys[g.key] = true
+			s.fields = append(s.fields, g)
+		}
+	}
+	return s, nil
+}
+
+// Used to get the name of a embedded field.
+func embeddedName(expr ast.Expr) string {
+	switch x := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(x.X)
+	case *ast.SelectorExpr:
+		return x.Sel.Name
+	case *ast.Ident:
+		return x.Name
+	default:
+		return ""
+	}
+}
+
+// Used to get how a field of the type specified should be packed and unpacked.
+func kindOf(expr ast.Expr, erlpackName string) fieldKind {
+	switch x := expr.(type) {
+	case *ast.Ident:
+		if x.Obj != nil {
+			// This is declared within the file, so it isn't a builtin.
+			return kindValue
+		}
+		switch x.Name {
+		case "string":
+			return kindString
+		case "bool":
+			return kindBool
+		case "int":
+			return kindInt
+		case "float64":
+			return kindFloat
+		}
+	case *ast.ArrayType:
+		if elt, ok := x.Elt.(*ast.Ident); ok && x.Len == nil && elt.Obj == nil && (elt.Name == "byte" || elt.Name == "uint8") {
+			return kindBinary
+		}
+	case *ast.SelectorExpr:
+		if pkg, ok := x.X.(*ast.Ident); ok && erlpackName != "" && pkg.Name == erlpackName {
+			switch x.Sel.Name {
+			case "Atom":
+				return kindAtom
+			case "RawData":
+				return kindRaw
+			}
+		}
+	}
+	return kindValue
+}
+
+// Used to get the condition for a field not being empty.
+func notEmpty(f genField) string {
+	v := "x." + f.name
+	switch f.kind {
+	case kindString, kindAtom:
+		return v + ` != ""`
+	case kindBool:
+		return v
+	case kindInt, kindFloat:
+		return v + " != 0"
+	case kindBinary, kindRaw:
+		return v + " != nil"
+	default:
+		return "!erlpack.IsZero(" + v + ")"
+	}
+}
+
+// Used to get the code which appends a field to b.
+func appendField(f genField) string {
+	v := "x." + f.name
+	switch f.kind {
+	case kindString:
+		return "b = erlpack.AppendString(b, " + v + ")\n"
+	case kindBool:
+		return "b = erlpack.AppendBool(b, " + v + ")\n"
+	case kindInt:
+		return "b = erlpack.AppendInt(b, int64(" + v + "))\n"
+	case kindFloat:
+		return "if b, err = erlpack.AppendFloat(b, " + v + "); err != nil {\nreturn nil, err\n}\n"
+	case kindBinary:
+		return "b = erlpack.AppendBinary(b, " + v + ")\n"
+	case kindAtom:
+		return "b = erlpack.AppendAtom(b, " + v + ")\n"
+	case kindRaw:
+		// Raw data which is empty is packed as nil, the same as Pack.
+		return "if len(" + v + ") == 0 {\nb = erlpack.AppendNil(b)\n} else {\nb = append(b, " + v + "...)\n}\n"
+	default:
+		return "if b, err = erlpack.AppendValue(b, " + v + "); err != nil {\nreturn nil, err\n}\n"
+	}
+}
+
+// Used to get the code which reads a field.
+func readField(f genField) string {
+	v := "x." + f.name
+	switch f.kind {
+	case kindString:
+		return v + ", err = r.ReadString()\n"
+	case kindBool:
+		return v + ", err = r.ReadBool()\n"
+	case kindInt:
+		return v + ", err = r.ReadInt()\n"
+	case kindFloat:
+		return v + ", err = r.ReadFloat()\n"
+	case kindBinary:
+		return v + ", err = r.ReadBinary()\n"
+	case kindAtom:
+		return v + ", err = r.ReadAtom()\n"
+	case kindRaw:
+		return v + ", err = r.ReadRaw()\n"
+	default:
+		return "err = r.ReadValue(&" + v + ")\n"
+	}
+}
+
+// Used to write the code for the structs.
+func writeCode(pkg string, structs []genStruct) []byte {
+	// Write the methods first so that we know which imports are needed.
+	body := &bytes.Buffer{}
+	usesFmt := false
+	for _, s := range structs {
+		// Check if errors can happen while packing, and if any fields fall back to reflection.
+		usesErr, usesReflection := false, false
+		for _, f := range s.fields {
+			if !f.asString && (f.kind == kindFloat || f.kind == kindValue) {
+				usesErr = true
+			}
+			if !f.asString && f.kind == kindValue {
+				usesReflection = true
+			}
+		}
+		how, fields := " without reflection", ""
+		if usesReflection {
+			how, fields = "", " Fields which aren't basic types, atoms or raw data use reflection."
+		}
+
+		// Write MarshalErlpack.
+		fmt.Fprintf(body, "\n// MarshalErlpack is used to pack %s%s.%s\n", s.name, how, fields)
+		body.WriteString("// Encoder options (including Marshalers) aren't given to this method, so they don't apply to the fields.\n")
+		fmt.Fprintf(body, "func (x %s) MarshalErlpack() ([]byte, error) {\n", s.name)
+		if usesErr {
+			body.WriteString("var err error\n")
+		}
+		body.WriteString("b := erlpack.AppendMapHeader(make([]byte, 0, 64), 0)\nn := uint32(0)\n")
+		for _, f := range s.fields {
+			fmt.Fprintf(body, "\n// %s\n", f.name)
+			code := "b = erlpack.AppendString(b, " + strconv.Quote(f.key) + ")\n" + appendField(f) + "n++\n"
+			if f.asString {
+				// Like Pack, the field is only written if it is a fmt.Stringer.
+				usesFmt = true
+				code = "if s, ok := interface{}(x." + f.name + ").(fmt.Stringer); ok {\n" +
+					"b = erlpack.AppendString(b, " + strconv.Quote(f.key) + ")\nb = erlpack.AppendString(b, s.String())\nn++\n}\n"
+			}
+			if f.omitEmpty {
+				code = "if " + notEmpty(f) + " {\n" + code + "}\n"
+			}
+			body.WriteString(code)
+		}
+		body.WriteString("\nbinary.BigEndian.PutUint32(b[1:5], n)\nreturn b, nil\n}\n")
+
+		// Write UnmarshalErlpack.
+		fmt.Fprintf(body, "\n// UnmarshalErlpack is used to unpack %s%s.%s\n", s.name, how, fields)
+		body.WriteString("// Decoder options aren't given to this method, so the fields are always unpacked with the defaults.\n")
+		fmt.Fprintf(body, "func (x *%s) UnmarshalErlpack(data erlpack.RawData) error {\n", s.name)
+		fmt.Fprintf(body, "*x = %s{}\nr := erlpack.NewReader(data)\nl, err := r.ReadMapHeader()\nif err != nil {\nreturn err\n}\n", s.name)
+		body.WriteString("for i := uint32(0); i < l; i++ {\nkey, err := r.ReadKey()\nif err != nil {\nreturn err\n}\nswitch key {\n")
+		for _, f := range s.fields {
+			fmt.Fprintf(body, "case %s:\n%s", strconv.Quote(f.key), readField(f))
+		}
+		body.WriteString("default:\nerr = r.Skip()\n}\nif err != nil {\nreturn err\n}\n}\nreturn nil\n}\n")
+	}
+
+	// Write the header and imports.
+	out := &bytes.Buffer{}
+	out.WriteString("// Code generated by erlpackgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(out, "package %s\n\nimport (\n\"encoding/binary\"\n", pkg)
+	if usesFmt {
+		out.WriteString("\"fmt\"\n")
+	}
+	fmt.Fprintf(out, "\nerlpack %s\n)\n", strconv.Quote(erlpackImport))
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGenerateUpToDate is used to test that the generated test types match the output of the generator.
+func TestGenerateUpToDate(t *testing.T) {
+	b, err := generate("../../internal/gentest/types.go", []string{"User", "Message", "Presence"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := os.ReadFile("../../internal/gentest/types_erlpack.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, expected) {
+		t.Fatal("internal/gentest/types_erlpack.go is out of date, run go generate")
+	}
+}
+
+// TestGenerateErrors is used to test that unsupported types return errors.
+func TestGenerateErrors(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "types.go")
+	src := "package x\n\ntype A struct {\n\tB B `erlpack:\",flatten\"`\n}\n\ntype B struct {\n\tC int `erlpack:\"c\"`\n\tD int `erlpack:\"c\"`\n}\n\ntype E int\n"
+	if err := os.WriteFile(filename, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"A": "flatten is not supported",
+		"B": "duplicate key",
+		"E": "not a struct",
+		"F": "not found",
+	}
+	for name, expected := range tests {
+		_, err := generate(filename, []string{name})
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%s: expected error containing %q, got %v", name, expected, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Command erlpackgen is used to generate MarshalErlpack and UnmarshalErlpack methods for structs so that they can be
+// packed and unpacked without reflection. The generated code follows the same tag rules as Pack and Unpack.
+//
+// It is meant to be used with go:generate:
+//
+//	//go:generate go run github.com/JakeMakesStuff/go-erlpack/cmd/erlpackgen -type User,Message
+//
+// By default, the methods are written to a file named after the source file with a "_erlpack.go" suffix.
+//
+// Fields which are strings, numbers, booleans, binaries, atoms or raw data are packed and unpacked without reflection.
+// Any other field (such as a slice, map or nested struct) falls back to erlpack.AppendValue and Reader.ReadValue,
+// which use reflection.
+//
+// Options are not supported by the generated methods, since MarshalErlpack and UnmarshalErlpack aren't given them.
+// Structs with generated methods are always packed with the default EncoderOptions (so Deterministic doesn't sort the
+// keys of maps within them, and Marshalers such as the one set by discord.WithSnowflakeFormat don't apply to their
+// fields) and unpacked with the default DecoderOptions. Use erlpack.NewReaderWithOptions when writing a
+// UnmarshalErlpack method by hand which needs options.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	types := flag.String("type", "", "comma separated list of struct types to generate methods for")
+	output := flag.String("output", "", "output file (defaults to <file>_erlpack.go)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: erlpackgen -type T[,T...] [-output file] [file]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Get the source file. When this is run by go generate, this is the file containing the directive.
+	filename := os.Getenv("GOFILE")
+	if flag.NArg() > 0 {
+		filename = flag.Arg(0)
+	}
+	if filename == "" || *types == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Generate the code.
+	b, err := generate(filename, strings.Split(*types, ","))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "erlpackgen:", err)
+		os.Exit(1)
+	}
+
+	// Write the file.
+	out := *output
+	if out == "" {
+		out = strings.TrimSuffix(filename, filepath.Ext(filename)) + "_erlpack.go"
+	}
+	if err = os.WriteFile(out, b, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "erlpackgen:", err)
+		os.Exit(1)
+	}
+}
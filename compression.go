@@ -0,0 +1,77 @@
+package erlpack
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// DefaultMaxInflatedSize is the largest a compressed term can be once it is inflated if DecoderOptions.MaxInflatedSize
+// is 0.
+const DefaultMaxInflatedSize = 64 << 20
+
+// Used to inflate a compressed term. The declared uncompressed size is used as a limit on how much is inflated, and
+// has to be no larger than the maximum size (or DefaultMaxInflatedSize if it is 0).
+func inflateTerm(r unpackReader, MaxSize int) (*bytes.Reader, error) {
+	// Get the uncompressed size.
+	lengthBytes := make([]byte, 4)
+	if _, err := io.ReadFull(r, lengthBytes); err != nil {
+		return nil, errors.New("not enough bytes for uncompressed size")
+	}
+	l := int64(binary.BigEndian.Uint32(lengthBytes))
+	if MaxSize <= 0 {
+		MaxSize = DefaultMaxInflatedSize
+	}
+	if l > int64(MaxSize) {
+		return nil, errors.New("uncompressed size is larger than the maximum inflated size")
+	}
+
+	// Create the zlib reader.
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, errors.New("invalid compressed data")
+	}
+	defer zr.Close()
+
+	// Inflate up to the uncompressed size.
+	buf := &bytes.Buffer{}
+	if _, err := io.CopyN(buf, zr, l); err != nil {
+		return nil, errors.New("compressed data is shorter than the uncompressed size")
+	}
+
+	// Make sure the stream ends where it said it would (this also checks the checksum).
+	if n, err := zr.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		return nil, errors.New("compressed data is longer than the uncompressed size")
+	}
+	return bytes.NewReader(buf.Bytes()), nil
+}
+
+// Used to compress a term (without the version byte). If compressing does not make the term smaller, the term is returned as is.
+func compressTerm(term []byte, level int) ([]byte, error) {
+	// Create the header.
+	buf := &bytes.Buffer{}
+	buf.WriteByte('P')
+	lengthBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBytes, uint32(len(term)))
+	buf.Write(lengthBytes)
+
+	// Write the compressed data.
+	zw, err := zlib.NewWriterLevel(buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = zw.Write(term); err != nil {
+		return nil, err
+	}
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+
+	// Return whichever is smaller.
+	if buf.Len() >= len(term) {
+		return term, nil
+	}
+	return buf.Bytes(), nil
+}
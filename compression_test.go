@@ -0,0 +1,101 @@
+package erlpack
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCompressionRoundTrip is used to test packing and unpacking a compressed term.
+func TestCompressionRoundTrip(t *testing.T) {
+	s := strings.Repeat("hello world ", 100)
+	b, err := PackWithOptions([]string{s, s}, EncoderOptions{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b[0] != 131 || b[1] != 'P' {
+		t.Fatal("term was not compressed")
+	}
+	var a []string
+	err = Unpack(b, &a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 2 || a[0] != s || a[1] != s {
+		t.Fatal("unexpected result")
+	}
+
+	// Make sure RawData gets the uncompressed term.
+	b, err = PackWithOptions(s, EncoderOptions{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncompressed, err := Pack(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r RawData
+	err = Unpack(b, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bytesAssert(uncompressed[1:], r)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestCompressionThreshold is used to test that terms smaller than the threshold are not compressed.
+func TestCompressionThreshold(t *testing.T) {
+	s := strings.Repeat("a", 100)
+	b, err := PackWithOptions(s, EncoderOptions{Compress: true, CompressionThreshold: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b[1] != 'm' {
+		t.Fatal("term was compressed")
+	}
+	b, err = PackWithOptions(s, EncoderOptions{Compress: true, CompressionLevel: 9, CompressionThreshold: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b[1] != 'P' {
+		t.Fatal("term was not compressed")
+	}
+}
+
+// TestCompressionSizeMismatch is used to test that the declared uncompressed size is enforced.
+func TestCompressionSizeMismatch(t *testing.T) {
+	b, err := PackWithOptions(strings.Repeat("a", 100), EncoderOptions{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []byte{104, 106} {
+		b[5] = size
+		var s string
+		if err = Unpack(b, &s); err == nil {
+			t.Fatal("expected error for size", size)
+		}
+	}
+}
+
+// TestCompressionMaxInflatedSize is used to test that terms which inflate to more than the maximum size are refused
+// before they are inflated.
+func TestCompressionMaxInflatedSize(t *testing.T) {
+	b, err := PackWithOptions(strings.Repeat("a", 100), EncoderOptions{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	if err = UnpackWithOptions(b, &s, DecoderOptions{MaxInflatedSize: 104}); err == nil {
+		t.Fatal("expected error for term larger than the maximum size")
+	}
+	if err = UnpackWithOptions(b, &s, DecoderOptions{MaxInflatedSize: 105}); err != nil || len(s) != 100 {
+		t.Fatal("unexpected result:", err)
+	}
+
+	// A small term claiming to be 4 GiB should error without inflating anything.
+	bomb := append([]byte{131, 'P', 0xff, 0xff, 0xff, 0xff}, b[6:]...)
+	if err = Unpack(bomb, &s); err == nil || !strings.Contains(err.Error(), "maximum inflated size") {
+		t.Fatal("expected error for declared size, got", err)
+	}
+}
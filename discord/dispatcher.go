@@ -0,0 +1,59 @@
+package discord
+
+import (
+	"errors"
+	"sync"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Dispatcher is used to route dispatches to the handlers for their events. Handlers are added with Handle.
+// It is safe to add handlers and dispatch payloads concurrently.
+type Dispatcher struct {
+	// Fallback is called with the event name and raw data of dispatches which have no handlers, if it is not nil.
+	Fallback func(Event string, D erlpack.RawData) error
+
+	mu       sync.RWMutex
+	handlers map[string][]func(erlpack.RawData) error
+}
+
+// Handle is used to add a handler for the event specified. The data of each dispatch for the event is cast into a new
+// value of the type specified before the handler is called.
+func Handle[T any](d *Dispatcher, Event string, Handler func(*T) error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.handlers == nil {
+		d.handlers = map[string][]func(erlpack.RawData) error{}
+	}
+	d.handlers[Event] = append(d.handlers[Event], func(r erlpack.RawData) error {
+		v, err := erlpack.CastAs[T](r)
+		if err != nil {
+			return err
+		}
+		return Handler(&v)
+	})
+}
+
+// Dispatch is used to call the handlers for the event of a dispatch. The data is cast separately for each handler, so
+// handlers can modify the value they are given. The first error returned by a handler is returned.
+func (d *Dispatcher) Dispatch(p *GatewayPayload) error {
+	if p.Op != OpDispatch {
+		return errors.New("payload is not a dispatch")
+	}
+	Event := p.Event()
+	d.mu.RLock()
+	handlers := d.handlers[Event]
+	d.mu.RUnlock()
+	if len(handlers) == 0 {
+		if d.Fallback != nil {
+			return d.Fallback(Event, p.D)
+		}
+		return nil
+	}
+	for _, h := range handlers {
+		if err := h(p.D); err != nil {
+			return err
+		}
+	}
+	return nil
+}
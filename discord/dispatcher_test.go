@@ -0,0 +1,79 @@
+package discord
+
+import (
+	"errors"
+	"testing"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// TestDispatcher is used to test routing dispatches to typed handlers.
+func TestDispatcher(t *testing.T) {
+	d := &Dispatcher{}
+	var created []Message
+	Handle(d, EventMessageCreate, func(m *Message) error {
+		created = append(created, *m)
+		m.Content = "changed"
+		return nil
+	})
+	Handle(d, EventMessageCreate, func(m *Message) error {
+		if m.Content != "hello" {
+			return errors.New("handler was given a modified message")
+		}
+		return nil
+	})
+	deleteErr := errors.New("delete failed")
+	Handle(d, EventMessageDelete, func(m *MessageDelete) error {
+		return deleteErr
+	})
+	var fallback []string
+	d.Fallback = func(Event string, D erlpack.RawData) error {
+		fallback = append(fallback, Event)
+		return nil
+	}
+
+	// Dispatch a message.
+	b := gatewayPayload(t, OpDispatch, erlpack.OrderedMap{
+		{Key: erlpack.Atom("id"), Value: uint64(1 << 60)},
+		{Key: erlpack.Atom("channel_id"), Value: uint64(1 << 59)},
+		{Key: erlpack.Atom("author"), Value: erlpack.OrderedMap{{Key: erlpack.Atom("id"), Value: 1}}},
+		{Key: erlpack.Atom("content"), Value: []byte("hello")},
+		{Key: erlpack.Atom("mentions"), Value: []interface{}{}},
+	}, 2, erlpack.Atom(EventMessageCreate))
+	p, err := ParsePayload(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Dispatch(p); err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || created[0].ID != 1<<60 || created[0].ChannelID != 1<<59 || created[0].Author.ID != 1 {
+		t.Fatalf("unexpected messages: %+v", created)
+	}
+
+	// Errors from handlers should be returned.
+	b = gatewayPayload(t, OpDispatch, erlpack.OrderedMap{}, 3, erlpack.Atom(EventMessageDelete))
+	if p, err = ParsePayload(b); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Dispatch(p); err != deleteErr {
+		t.Fatal("expected handler error, got", err)
+	}
+
+	// Events without handlers should go to the fallback.
+	b = gatewayPayload(t, OpDispatch, erlpack.OrderedMap{}, 4, erlpack.Atom(EventTypingStart))
+	if p, err = ParsePayload(b); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Dispatch(p); err != nil {
+		t.Fatal(err)
+	}
+	if len(fallback) != 1 || fallback[0] != EventTypingStart {
+		t.Fatal("unexpected fallback events:", fallback)
+	}
+
+	// Payloads which aren't dispatches should error.
+	if err = d.Dispatch(&GatewayPayload{Op: OpHello}); err == nil {
+		t.Fatal("expected error for hello")
+	}
+}
@@ -0,0 +1,268 @@
+// Package gatewaytest contains a fake gateway which runs in the same process, so code using the gateway package can
+// be tested without network access. Tests accept each connection from the server and then send and receive payloads
+// on it.
+package gatewaytest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+	"github.com/JakeMakesStuff/go-erlpack/discord"
+	"github.com/JakeMakesStuff/go-erlpack/discord/gateway"
+)
+
+// Defines how many frames can be waiting in each direction before sending blocks.
+const frameBuffer = 64
+
+// Server is used to define a fake gateway. The zero value is not usable; use NewServer.
+type Server struct {
+	// HeartbeatInterval is the heartbeat interval sent in Hello when a connection is made.
+	HeartbeatInterval time.Duration
+
+	// DisableACK is used to stop heartbeats on new connections from being acknowledged automatically.
+	DisableACK bool
+
+	conns chan *Conn
+}
+
+// NewServer is used to create a fake gateway with a heartbeat interval of 41.25 seconds.
+func NewServer() *Server {
+	return &Server{HeartbeatInterval: 41250 * time.Millisecond, conns: make(chan *Conn, frameBuffer)}
+}
+
+// Dial is used to connect to the server. This can be used as gateway.Session.Dial. Hello is sent as soon as the
+// connection is made, and frames are compressed if the URL has compress=zlib-stream.
+func (s *Server) Dial(ctx context.Context, URL string) (gateway.Transport, error) {
+	u, err := url.Parse(URL)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{
+		URL:        URL,
+		disableACK: s.DisableACK,
+		toClient:   make(chan []byte, frameBuffer),
+		payloads:   make(chan *discord.GatewayPayload, frameBuffer),
+		heartbeats: make(chan *int64, frameBuffer),
+		done:       make(chan struct{}),
+	}
+	if u.Query().Get("compress") == "zlib-stream" {
+		c.zlibBuf = &bytes.Buffer{}
+		c.zlib = zlib.NewWriter(c.zlibBuf)
+	}
+	if err = c.Send(discord.OpHello, discord.Hello{HeartbeatInterval: s.HeartbeatInterval.Milliseconds()}); err != nil {
+		return nil, err
+	}
+	select {
+	case s.conns <- c:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return (*transport)(c), nil
+}
+
+// Accept is used to wait for the next connection to the server.
+func (s *Server) Accept(ctx context.Context) (*Conn, error) {
+	select {
+	case c := <-s.conns:
+		return c, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Conn is used to define the server side of a connection to the fake gateway.
+type Conn struct {
+	// URL is the URL the client connected to.
+	URL string
+
+	disableACK bool
+	toClient   chan []byte
+	payloads   chan *discord.GatewayPayload
+	heartbeats chan *int64
+
+	sendLock sync.Mutex
+	zlib     *zlib.Writer
+	zlibBuf  *bytes.Buffer
+
+	closeOnce sync.Once
+	closeErr  error
+	done      chan struct{}
+}
+
+// Used to send a frame to the client.
+func (c *Conn) sendFrame(Frame []byte) error {
+	c.sendLock.Lock()
+	defer c.sendLock.Unlock()
+	if c.zlib != nil {
+		// Compress the frame with the shared context and split it in two, like a large payload would be.
+		if _, err := c.zlib.Write(Frame); err != nil {
+			return err
+		}
+		if err := c.zlib.Flush(); err != nil {
+			return err
+		}
+		b := append([]byte(nil), c.zlibBuf.Bytes()...)
+		c.zlibBuf.Reset()
+		half := len(b) / 2
+		if err := c.queue(b[:half]); err != nil {
+			return err
+		}
+		return c.queue(b[half:])
+	}
+	return c.queue(Frame)
+}
+
+// Used to queue a frame for the client to read.
+func (c *Conn) queue(Frame []byte) error {
+	select {
+	case <-c.done:
+		return errors.New("connection is closed")
+	default:
+	}
+	select {
+	case c.toClient <- Frame:
+		return nil
+	case <-c.done:
+		return errors.New("connection is closed")
+	}
+}
+
+// Send is used to send a payload with the opcode and data specified to the client.
+func (c *Conn) Send(Op discord.Opcode, D interface{}) error {
+	p, err := discord.NewPayload(Op, D)
+	if err != nil {
+		return err
+	}
+	b, err := p.Pack()
+	if err != nil {
+		return err
+	}
+	return c.sendFrame(b)
+}
+
+// Dispatch is used to send a event to the client. Like the real gateway, the keys and event name are atoms.
+func (c *Conn) Dispatch(Seq int64, Event string, D interface{}) error {
+	d, err := erlpack.Pack(D)
+	if err != nil {
+		return err
+	}
+	b, err := erlpack.Pack(erlpack.OrderedMap{
+		{Key: erlpack.Atom("t"), Value: erlpack.Atom(Event)},
+		{Key: erlpack.Atom("s"), Value: Seq},
+		{Key: erlpack.Atom("op"), Value: int(discord.OpDispatch)},
+		{Key: erlpack.Atom("d"), Value: erlpack.RawData(d[1:])},
+	})
+	if err != nil {
+		return err
+	}
+	return c.sendFrame(b)
+}
+
+// Receive is used to wait for the next payload from the client. Heartbeats are not included; use ReceiveHeartbeat
+// for those.
+func (c *Conn) Receive(ctx context.Context) (*discord.GatewayPayload, error) {
+	select {
+	case p := <-c.payloads:
+		return p, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, errors.New("connection is closed")
+	}
+}
+
+// ReceiveHeartbeat is used to wait for the next heartbeat from the client, returning the sequence number it contained.
+func (c *Conn) ReceiveHeartbeat(ctx context.Context) (*int64, error) {
+	select {
+	case seq := <-c.heartbeats:
+		return seq, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, errors.New("connection is closed")
+	}
+}
+
+// Close is used to close the connection with the close code specified.
+func (c *Conn) Close(Code int) {
+	c.close(&gateway.CloseError{Code: Code})
+}
+
+// Done is used to get a channel which is closed when the connection is closed by either side.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Used to close the connection with the error the client will get.
+func (c *Conn) close(err error) {
+	c.closeOnce.Do(func() {
+		c.closeErr = err
+		close(c.done)
+	})
+}
+
+// Used to define the client side of a connection.
+type transport Conn
+
+// ReadFrame is used to read the next frame sent by the server. Frames sent before the connection was closed are
+// still returned.
+func (t *transport) ReadFrame() ([]byte, error) {
+	select {
+	case f := <-t.toClient:
+		return f, nil
+	default:
+	}
+	select {
+	case f := <-t.toClient:
+		return f, nil
+	case <-t.done:
+		return nil, t.closeErr
+	}
+}
+
+// WriteFrame is used to send a frame to the server. Heartbeats are acknowledged straight away unless the server has
+// DisableACK set.
+func (t *transport) WriteFrame(Frame []byte) error {
+	c := (*Conn)(t)
+	select {
+	case <-c.done:
+		return errors.New("connection is closed")
+	default:
+	}
+	p, err := discord.ParsePayload(Frame)
+	if err != nil {
+		return err
+	}
+	if p.Op == discord.OpHeartbeat {
+		var h discord.Heartbeat
+		if err = p.Cast(&h); err != nil {
+			return err
+		}
+		select {
+		case c.heartbeats <- h.Seq:
+		default:
+		}
+		if !c.disableACK {
+			return c.Send(discord.OpHeartbeatACK, nil)
+		}
+		return nil
+	}
+	select {
+	case c.payloads <- p:
+		return nil
+	case <-c.done:
+		return errors.New("connection is closed")
+	}
+}
+
+// Close is used to close the connection from the client side.
+func (t *transport) Close() error {
+	(*Conn)(t).close(errors.New("connection is closed"))
+	return nil
+}
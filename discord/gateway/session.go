@@ -0,0 +1,370 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+	"github.com/JakeMakesStuff/go-erlpack/discord"
+)
+
+// DefaultURL is the URL of the gateway which is used if Session.URL is blank.
+const DefaultURL = "wss://gateway.discord.gg"
+
+// Session is used to define a session with the gateway. The fields should be set before Run is called, and not
+// changed after.
+type Session struct {
+	// Token is the token of the bot.
+	Token string
+
+	// Intents is the gateway intents to identify with.
+	Intents int
+
+	// Properties is the connection properties to identify with.
+	Properties discord.IdentifyProperties
+
+	// Shard is the shard ID and number of shards to identify with, if it is not nil.
+	Shard []int
+
+	// URL is the URL of the gateway. If this is blank, DefaultURL is used. The version, encoding and compression
+	// are added to the query string.
+	URL string
+
+	// Compress is used to connect with compress=zlib-stream.
+	Compress bool
+
+	// Dial is used to connect to the gateway.
+	Dial Dialer
+
+	// Dispatcher is called with every dispatch, if it is not nil. If it returns a error, Run stops and returns it.
+	Dispatcher *discord.Dispatcher
+
+	// ReconnectDelay is how long to wait before reconnecting after a connection fails or the session is invalidated.
+	// The gateway asks for a reconnect to happen straight away, so this is not used then.
+	ReconnectDelay time.Duration
+
+	// Jitter is used to get the fraction of the heartbeat interval to wait before the first heartbeat. If this is nil,
+	// a random number is used.
+	Jitter func() float64
+
+	mu        sync.Mutex
+	sessionID string
+	resumeURL string
+	seq       *int64
+}
+
+// SessionID is used to get the ID of the session, or a blank string if the session has not been identified.
+func (s *Session) SessionID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessionID
+}
+
+// Sequence is used to get the sequence number of the last dispatch received, or nil if none have been received.
+func (s *Session) Sequence() *int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seq == nil {
+		return nil
+	}
+	seq := *s.seq
+	return &seq
+}
+
+// Used to reset the session so the next connection identifies.
+func (s *Session) reset() {
+	s.mu.Lock()
+	s.sessionID, s.resumeURL, s.seq = "", "", nil
+	s.mu.Unlock()
+}
+
+// Used to define a error which should stop the session.
+type fatalError struct {
+	err error
+}
+
+// Error is used to get the error message.
+func (e fatalError) Error() string {
+	return e.err.Error()
+}
+
+// Used to make Run reconnect straight away.
+var errReconnect = errors.New("gateway asked for a reconnect")
+
+// Used to make Run reconnect after a heartbeat was not acknowledged.
+var errZombie = errors.New("heartbeat was not acknowledged")
+
+// Run is used to connect to the gateway and keep the session going until the context is cancelled or a error which
+// can't be recovered from happens. When a connection ends, the session is resumed if possible, and a new session is
+// identified otherwise.
+func (s *Session) Run(ctx context.Context) error {
+	if s.Dial == nil {
+		return errors.New("no dialer set")
+	}
+	for {
+		err := s.connect(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Check if the error is one which should stop the session.
+		var fatal fatalError
+		if errors.As(err, &fatal) {
+			return fatal.err
+		}
+		var closeErr *CloseError
+		if errors.As(err, &closeErr) {
+			if fatalCloseCodes[closeErr.Code] {
+				return err
+			}
+			if newSessionCloseCodes[closeErr.Code] {
+				s.reset()
+			}
+		}
+
+		// Wait before reconnecting unless the gateway asked for a reconnect.
+		if err == errReconnect {
+			continue
+		}
+		t := time.NewTimer(s.ReconnectDelay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Used to get the URL to connect to, with the query string added.
+func (s *Session) dialURL() (string, error) {
+	s.mu.Lock()
+	base := s.resumeURL
+	s.mu.Unlock()
+	if base == "" {
+		base = s.URL
+	}
+	if base == "" {
+		base = DefaultURL
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fatalError{err}
+	}
+	q := u.Query()
+	q.Set("v", "10")
+	q.Set("encoding", "etf")
+	if s.Compress {
+		q.Set("compress", "zlib-stream")
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Used to define a single connection within a session.
+type connection struct {
+	s     *Session
+	t     Transport
+	zlib  *discord.ZlibStream
+	write sync.Mutex
+
+	// These are set to 1 when the last heartbeat was acknowledged, and when the connection was closed because a
+	// heartbeat was not acknowledged.
+	acked  int32
+	zombie int32
+}
+
+// Used to connect to the gateway and handle the connection until it ends.
+func (s *Session) connect(ctx context.Context) error {
+	u, err := s.dialURL()
+	if err != nil {
+		return err
+	}
+	t, err := s.Dial(ctx, u)
+	if err != nil {
+		return err
+	}
+	c := &connection{s: s, t: t, acked: 1}
+	if s.Compress {
+		c.zlib = &discord.ZlibStream{}
+	}
+
+	// Close the transport when the connection ends or the context is cancelled.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+		_ = t.Close()
+	}()
+
+	// The gateway starts by sending Hello.
+	p, err := c.read()
+	if err != nil {
+		return err
+	}
+	if p.Op != discord.OpHello {
+		return errors.New("expected hello from gateway, got " + p.Op.String())
+	}
+	var hello discord.Hello
+	if err = p.Cast(&hello); err != nil {
+		return err
+	}
+	if hello.HeartbeatInterval <= 0 {
+		return errors.New("invalid heartbeat interval")
+	}
+	go c.heartbeat(time.Duration(hello.HeartbeatInterval)*time.Millisecond, stop)
+
+	// Resume the session if there is one, or identify a new one.
+	if err = c.identify(); err != nil {
+		return err
+	}
+
+	// Handle each payload.
+	for {
+		p, err := c.read()
+		if err != nil {
+			if atomic.LoadInt32(&c.zombie) == 1 {
+				return errZombie
+			}
+			return err
+		}
+		if err = c.handle(p); err != nil {
+			return err
+		}
+	}
+}
+
+// Used to read the next payload.
+func (c *connection) read() (*discord.GatewayPayload, error) {
+	for {
+		frame, err := c.t.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+		if c.zlib == nil {
+			return discord.ParsePayload(frame)
+		}
+		p, err := c.zlib.FeedPayload(frame)
+		if p != nil || err != nil {
+			return p, err
+		}
+	}
+}
+
+// Used to send a payload.
+func (c *connection) send(Op discord.Opcode, D interface{}) error {
+	p, err := discord.NewPayload(Op, D)
+	if err != nil {
+		return err
+	}
+	b, err := p.Pack()
+	if err != nil {
+		return err
+	}
+	c.write.Lock()
+	defer c.write.Unlock()
+	return c.t.WriteFrame(b)
+}
+
+// Used to send a heartbeat with the last sequence number.
+func (c *connection) sendHeartbeat() error {
+	return c.send(discord.OpHeartbeat, discord.Heartbeat{Seq: c.s.Sequence()})
+}
+
+// Used to send heartbeats until the connection ends. If a heartbeat is not acknowledged before the next one is due,
+// the connection is closed so it can be resumed.
+func (c *connection) heartbeat(interval time.Duration, stop chan struct{}) {
+	jitter := c.s.Jitter
+	if jitter == nil {
+		jitter = rand.Float64
+	}
+	t := time.NewTimer(time.Duration(float64(interval) * jitter()))
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		if !atomic.CompareAndSwapInt32(&c.acked, 1, 0) {
+			atomic.StoreInt32(&c.zombie, 1)
+			_ = c.t.Close()
+			return
+		}
+		if err := c.sendHeartbeat(); err != nil {
+			_ = c.t.Close()
+			return
+		}
+		t.Reset(interval)
+	}
+}
+
+// Used to resume the session if there is one, or identify a new one otherwise.
+func (c *connection) identify() error {
+	s := c.s
+	s.mu.Lock()
+	sessionID, seq := s.sessionID, s.seq
+	s.mu.Unlock()
+	if sessionID != "" && seq != nil {
+		return c.send(discord.OpResume, discord.Resume{Token: s.Token, SessionID: sessionID, Seq: *seq})
+	}
+	return c.send(discord.OpIdentify, discord.Identify{
+		Token:      s.Token,
+		Properties: s.Properties,
+		Shard:      s.Shard,
+		Intents:    s.Intents,
+	})
+}
+
+// Used to handle a payload after the connection has been identified.
+func (c *connection) handle(p *discord.GatewayPayload) error {
+	s := c.s
+	switch p.Op {
+	case discord.OpDispatch:
+		// Keep the sequence number, and the session if this is Ready.
+		s.mu.Lock()
+		if p.S != nil {
+			seq := *p.S
+			s.seq = &seq
+		}
+		s.mu.Unlock()
+		if p.Event() == discord.EventReady {
+			ready, err := erlpack.CastAs[discord.Ready](p.D)
+			if err != nil {
+				return err
+			}
+			s.mu.Lock()
+			s.sessionID, s.resumeURL = ready.SessionID, ready.ResumeGatewayURL
+			s.mu.Unlock()
+		}
+		if s.Dispatcher != nil {
+			if err := s.Dispatcher.Dispatch(p); err != nil {
+				return fatalError{err}
+			}
+		}
+	case discord.OpHeartbeat:
+		return c.sendHeartbeat()
+	case discord.OpHeartbeatACK:
+		atomic.StoreInt32(&c.acked, 1)
+	case discord.OpReconnect:
+		return errReconnect
+	case discord.OpInvalidSession:
+		var resumable bool
+		if err := p.Cast(&resumable); err != nil {
+			return err
+		}
+		if !resumable {
+			s.reset()
+		}
+		return errors.New("session was invalidated")
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package gateway_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JakeMakesStuff/go-erlpack/discord"
+	"github.com/JakeMakesStuff/go-erlpack/discord/gateway"
+	"github.com/JakeMakesStuff/go-erlpack/discord/gateway/gatewaytest"
+)
+
+// Used to start a session against a fake gateway. The channel gets the error Run returns.
+func runSession(t *testing.T, s *gateway.Session, srv *gatewaytest.Server) (context.Context, <-chan error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	s.Dial = srv.Dial
+	if s.Jitter == nil {
+		s.Jitter = func() float64 { return 1 }
+	}
+	errs := make(chan error, 1)
+	go func() { errs <- s.Run(ctx) }()
+	return ctx, errs
+}
+
+// Used to accept a connection and check the first payload the client sends.
+func accept(ctx context.Context, t *testing.T, srv *gatewaytest.Server, Op discord.Opcode, Ptr interface{}) *gatewaytest.Conn {
+	t.Helper()
+	c, err := srv.Accept(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := c.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Op != Op {
+		t.Fatalf("expected %s, got %s", Op, p.Op)
+	}
+	if err = p.Cast(Ptr); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// Used to send READY on a connection.
+func sendReady(t *testing.T, c *gatewaytest.Conn, Seq int64, SessionID, ResumeURL string) {
+	t.Helper()
+	err := c.Dispatch(Seq, discord.EventReady, discord.Ready{
+		V:                10,
+		User:             discord.User{ID: 1, Username: "bot"},
+		Guilds:           []discord.UnavailableGuild{},
+		SessionID:        SessionID,
+		ResumeGatewayURL: ResumeURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSessionIdentifyAndResume is used to test identifying, dispatching and resuming after the gateway asks for a
+// reconnect.
+func TestSessionIdentifyAndResume(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	d := &discord.Dispatcher{}
+	messages := make(chan discord.Message, 1)
+	discord.Handle(d, discord.EventMessageCreate, func(m *discord.Message) error {
+		messages <- *m
+		return nil
+	})
+	s := &gateway.Session{
+		Token:      "token",
+		Intents:    513,
+		Properties: discord.IdentifyProperties{OS: "linux", Browser: "test", Device: "test"},
+		URL:        "wss://gateway.example",
+		Dispatcher: d,
+	}
+	ctx, errs := runSession(t, s, srv)
+
+	// The client should identify with the fields from the session.
+	var identify discord.Identify
+	c := accept(ctx, t, srv, discord.OpIdentify, &identify)
+	if identify.Token != "token" || identify.Intents != 513 || identify.Properties.OS != "linux" {
+		t.Fatalf("unexpected identify: %+v", identify)
+	}
+	if !strings.HasPrefix(c.URL, "wss://gateway.example?") || !strings.Contains(c.URL, "encoding=etf") ||
+		!strings.Contains(c.URL, "v=10") {
+		t.Fatal("unexpected url:", c.URL)
+	}
+
+	// Dispatches should reach the dispatcher.
+	sendReady(t, c, 1, "abc", "wss://resume.example")
+	if err := c.Dispatch(2, discord.EventMessageCreate, discord.Message{ID: 5, Content: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-messages:
+		if m.ID != 5 || m.Content != "hello" {
+			t.Fatalf("unexpected message: %+v", m)
+		}
+	case <-ctx.Done():
+		t.Fatal("message was not dispatched")
+	}
+	if s.SessionID() != "abc" || *s.Sequence() != 2 {
+		t.Fatal("unexpected session state:", s.SessionID(), *s.Sequence())
+	}
+
+	// A reconnect should resume on the resume URL.
+	if err := c.Send(discord.OpReconnect, nil); err != nil {
+		t.Fatal(err)
+	}
+	var resume discord.Resume
+	c = accept(ctx, t, srv, discord.OpResume, &resume)
+	if resume.Token != "token" || resume.SessionID != "abc" || resume.Seq != 2 {
+		t.Fatalf("unexpected resume: %+v", resume)
+	}
+	if !strings.HasPrefix(c.URL, "wss://resume.example?") {
+		t.Fatal("resume url was not used:", c.URL)
+	}
+
+	// A session which can't be resumed should identify again.
+	if err := c.Send(discord.OpInvalidSession, false); err != nil {
+		t.Fatal(err)
+	}
+	accept(ctx, t, srv, discord.OpIdentify, &identify)
+	if s.SessionID() != "" || s.Sequence() != nil {
+		t.Fatal("session was not reset")
+	}
+	select {
+	case err := <-errs:
+		t.Fatal("run returned early:", err)
+	default:
+	}
+}
+
+// TestSessionHeartbeat is used to test heartbeating, including when the gateway asks for one and when they are not
+// acknowledged.
+func TestSessionHeartbeat(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	srv.HeartbeatInterval = 50 * time.Millisecond
+	s := &gateway.Session{Token: "token"}
+	ctx, _ := runSession(t, s, srv)
+
+	var identify discord.Identify
+	c := accept(ctx, t, srv, discord.OpIdentify, &identify)
+	sendReady(t, c, 7, "abc", "")
+
+	// Heartbeats should be sent on the interval with the last sequence number.
+	for i := 0; i < 3; i++ {
+		seq, err := c.ReceiveHeartbeat(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq == nil || *seq != 7 {
+			t.Fatal("unexpected heartbeat sequence:", seq)
+		}
+	}
+
+	// A heartbeat should be sent straight away when the gateway asks for one.
+	srv.HeartbeatInterval = time.Hour
+	c.Close(4000)
+	var resume discord.Resume
+	c = accept(ctx, t, srv, discord.OpResume, &resume)
+	if err := c.Send(discord.OpHeartbeat, nil); err != nil {
+		t.Fatal(err)
+	}
+	hctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if _, err := c.ReceiveHeartbeat(hctx); err != nil {
+		t.Fatal("heartbeat was not sent when asked for:", err)
+	}
+
+	// If heartbeats are not acknowledged, the client should close the connection and resume.
+	srv.HeartbeatInterval = 50 * time.Millisecond
+	srv.DisableACK = true
+	c.Close(4000)
+	c = accept(ctx, t, srv, discord.OpResume, &resume)
+	select {
+	case <-c.Done():
+	case <-ctx.Done():
+		t.Fatal("zombie connection was not closed")
+	}
+	c = accept(ctx, t, srv, discord.OpResume, &resume)
+	if resume.SessionID != "abc" || resume.Seq != 7 {
+		t.Fatalf("unexpected resume: %+v", resume)
+	}
+}
+
+// TestSessionCloseCodes is used to test how the session handles close codes.
+func TestSessionCloseCodes(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	s := &gateway.Session{Token: "token"}
+	ctx, errs := runSession(t, s, srv)
+
+	// Codes which mean the session can't be resumed should identify again.
+	var identify discord.Identify
+	c := accept(ctx, t, srv, discord.OpIdentify, &identify)
+	sendReady(t, c, 1, "abc", "")
+	c.Close(4009)
+	c = accept(ctx, t, srv, discord.OpIdentify, &identify)
+
+	// Codes which can't be recovered from should be returned.
+	c.Close(4004)
+	select {
+	case err := <-errs:
+		var closeErr *gateway.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != 4004 {
+			t.Fatal("unexpected error:", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("run did not return")
+	}
+}
+
+// TestSessionDispatcherError is used to test that a error from the dispatcher stops the session.
+func TestSessionDispatcherError(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	handlerErr := errors.New("handler failed")
+	d := &discord.Dispatcher{}
+	discord.Handle(d, discord.EventMessageCreate, func(m *discord.Message) error {
+		return handlerErr
+	})
+	s := &gateway.Session{Token: "token", Dispatcher: d}
+	ctx, errs := runSession(t, s, srv)
+
+	var identify discord.Identify
+	c := accept(ctx, t, srv, discord.OpIdentify, &identify)
+	if err := c.Dispatch(1, discord.EventMessageCreate, discord.Message{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if err != handlerErr {
+			t.Fatal("unexpected error:", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("run did not return")
+	}
+}
+
+// TestSessionCompress is used to test a session using zlib-stream compression.
+func TestSessionCompress(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	d := &discord.Dispatcher{}
+	messages := make(chan string, 10)
+	discord.Handle(d, discord.EventMessageCreate, func(m *discord.Message) error {
+		messages <- m.Content
+		return nil
+	})
+	s := &gateway.Session{Token: "token", Compress: true, Dispatcher: d}
+	ctx, _ := runSession(t, s, srv)
+
+	var identify discord.Identify
+	c := accept(ctx, t, srv, discord.OpIdentify, &identify)
+	if !strings.Contains(c.URL, "compress=zlib-stream") {
+		t.Fatal("compression was not asked for:", c.URL)
+	}
+	sendReady(t, c, 1, "abc", "")
+	for i := int64(0); i < 5; i++ {
+		if err := c.Dispatch(i+2, discord.EventMessageCreate, discord.Message{Content: "hello"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		select {
+		case m := <-messages:
+			if m != "hello" {
+				t.Fatal("unexpected message:", m)
+			}
+		case <-ctx.Done():
+			t.Fatal("message was not dispatched")
+		}
+	}
+}
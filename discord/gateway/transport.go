@@ -0,0 +1,60 @@
+// Package gateway contains a client for Discord's gateway which uses the external term format. It handles the session
+// (identifying, heartbeating, resuming and reconnecting), and leaves the websocket to a Transport so any websocket
+// library can be used. The gatewaytest package contains a fake gateway which can be used in tests.
+package gateway
+
+import (
+	"context"
+	"strconv"
+)
+
+// Transport is used to define a connection to the gateway which sends and receives binary websocket frames.
+type Transport interface {
+	// ReadFrame is used to read the next binary frame. If the gateway closed the connection with a close code, this
+	// should return a *CloseError.
+	ReadFrame() ([]byte, error)
+
+	// WriteFrame is used to write a binary frame. It is not called concurrently.
+	WriteFrame(Frame []byte) error
+
+	// Close is used to close the connection. This should make any ReadFrame call return. It can be called more than
+	// once, and at the same time as the other methods.
+	Close() error
+}
+
+// Dialer is used to connect to the gateway at the URL specified.
+type Dialer func(ctx context.Context, URL string) (Transport, error)
+
+// CloseError is used to define the error returned by a transport when the gateway closes the connection.
+type CloseError struct {
+	// Code is the websocket close code.
+	Code int
+
+	// Reason is the reason given for closing the connection.
+	Reason string
+}
+
+// Error is used to get the error message.
+func (e *CloseError) Error() string {
+	s := "gateway closed the connection with code " + strconv.Itoa(e.Code)
+	if e.Reason != "" {
+		s += ": " + e.Reason
+	}
+	return s
+}
+
+// Defines the close codes which mean the client should not reconnect.
+var fatalCloseCodes = map[int]bool{
+	4004: true, // authentication failed
+	4010: true, // invalid shard
+	4011: true, // sharding required
+	4012: true, // invalid API version
+	4013: true, // invalid intents
+	4014: true, // disallowed intents
+}
+
+// Defines the close codes which mean the session can't be resumed.
+var newSessionCloseCodes = map[int]bool{
+	4007: true, // invalid sequence
+	4009: true, // session timed out
+}
@@ -0,0 +1,77 @@
+package discord
+
+import "strconv"
+
+// Opcode is used to define the opcode of a gateway payload.
+type Opcode int
+
+const (
+	// OpDispatch is received when an event is dispatched.
+	OpDispatch Opcode = 0
+
+	// OpHeartbeat is sent to keep the connection alive. It can also be received when the gateway wants a heartbeat
+	// sent straight away.
+	OpHeartbeat Opcode = 1
+
+	// OpIdentify is sent to start a new session.
+	OpIdentify Opcode = 2
+
+	// OpPresenceUpdate is sent to update the presence of the client.
+	OpPresenceUpdate Opcode = 3
+
+	// OpVoiceStateUpdate is sent to join, leave or move between voice channels.
+	OpVoiceStateUpdate Opcode = 4
+
+	// OpResume is sent to resume a previous session.
+	OpResume Opcode = 6
+
+	// OpReconnect is received when the client should reconnect and resume.
+	OpReconnect Opcode = 7
+
+	// OpRequestGuildMembers is sent to request the members of a guild.
+	OpRequestGuildMembers Opcode = 8
+
+	// OpInvalidSession is received when the session is invalid. The data is true if the session can be resumed.
+	OpInvalidSession Opcode = 9
+
+	// OpHello is received straight after connecting, and contains the heartbeat interval.
+	OpHello Opcode = 10
+
+	// OpHeartbeatACK is received when a heartbeat is acknowledged.
+	OpHeartbeatACK Opcode = 11
+
+	// OpRequestSoundboardSounds is sent to request the soundboard sounds of guilds.
+	OpRequestSoundboardSounds Opcode = 31
+)
+
+// String is used to get the name of the opcode.
+func (o Opcode) String() string {
+	switch o {
+	case OpDispatch:
+		return "Dispatch"
+	case OpHeartbeat:
+		return "Heartbeat"
+	case OpIdentify:
+		return "Identify"
+	case OpPresenceUpdate:
+		return "Presence Update"
+	case OpVoiceStateUpdate:
+		return "Voice State Update"
+	case OpResume:
+		return "Resume"
+	case OpReconnect:
+		return "Reconnect"
+	case OpRequestGuildMembers:
+		return "Request Guild Members"
+	case OpInvalidSession:
+		return "Invalid Session"
+	case OpHello:
+		return "Hello"
+	case OpHeartbeatACK:
+		return "Heartbeat ACK"
+	case OpRequestSoundboardSounds:
+		return "Request Soundboard Sounds"
+	default:
+		return "Opcode(" + strconv.Itoa(int(o)) + ")"
+	}
+}
@@ -0,0 +1,72 @@
+// Package discord contains types for the payloads sent over Discord's gateway when it is using the external term
+// format (encoding=etf). The data of each payload is kept as raw data, so it is only unpacked once its type is known.
+package discord
+
+import (
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// GatewayPayload is used to define the envelope which every gateway payload is sent in.
+type GatewayPayload struct {
+	// Op is the opcode of the payload.
+	Op Opcode `erlpack:"op"`
+
+	// D is the data of the payload. This can be cast into the type for the opcode (or the event for dispatches).
+	D erlpack.RawData `erlpack:"d"`
+
+	// S is the sequence number of a dispatch. This is nil for other opcodes.
+	S *int64 `erlpack:"s"`
+
+	// T is the name of the event for a dispatch. This is nil for other opcodes.
+	T *string `erlpack:"t"`
+}
+
+// NewPayload is used to create a payload with the opcode and data specified. The data is packed straight away.
+func NewPayload(Op Opcode, D interface{}) (*GatewayPayload, error) {
+	return NewPayloadWithOptions(Op, D, erlpack.EncoderOptions{})
+}
+
+// NewPayloadWithOptions is used to create a payload with the data packed using the encoder options specified. Use
+// WithSnowflakeFormat to pack the snowflakes in the data as integers.
+func NewPayloadWithOptions(Op Opcode, D interface{}, Options erlpack.EncoderOptions) (*GatewayPayload, error) {
+	b, err := erlpack.PackWithOptions(D, Options)
+	if err != nil {
+		return nil, err
+	}
+	return &GatewayPayload{Op: Op, D: b[1:]}, nil
+}
+
+// ParsePayload is used to unpack a payload received from the gateway.
+func ParsePayload(Data []byte) (*GatewayPayload, error) {
+	p := &GatewayPayload{}
+	if err := erlpack.Unpack(Data, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Event is used to get the name of the event for a dispatch, or a blank string for other opcodes.
+func (p *GatewayPayload) Event() string {
+	if p.T == nil {
+		return ""
+	}
+	return *p.T
+}
+
+// Sequence is used to get the sequence number of a dispatch, or 0 for other opcodes.
+func (p *GatewayPayload) Sequence() int64 {
+	if p.S == nil {
+		return 0
+	}
+	return *p.S
+}
+
+// Cast is used to cast the data of the payload into the pointer specified.
+func (p *GatewayPayload) Cast(Ptr interface{}) error {
+	return p.D.Cast(Ptr)
+}
+
+// Pack is used to pack the payload so it can be sent to the gateway.
+func (p *GatewayPayload) Pack() ([]byte, error) {
+	return erlpack.Pack(p)
+}
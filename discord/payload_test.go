@@ -0,0 +1,160 @@
+package discord
+
+import (
+	"reflect"
+	"testing"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Used to pack a payload the way the gateway does, with atom keys and the event name as a atom.
+func gatewayPayload(t *testing.T, Op Opcode, D interface{}, S interface{}, T interface{}) []byte {
+	t.Helper()
+	b, err := erlpack.Pack(erlpack.OrderedMap{
+		{Key: erlpack.Atom("t"), Value: T},
+		{Key: erlpack.Atom("s"), Value: S},
+		{Key: erlpack.Atom("op"), Value: int(Op)},
+		{Key: erlpack.Atom("d"), Value: D},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestParsePayload is used to test parsing payloads sent by the gateway.
+func TestParsePayload(t *testing.T) {
+	// Test a payload which is not a dispatch.
+	b := gatewayPayload(t, OpHello, erlpack.OrderedMap{
+		{Key: erlpack.Atom("heartbeat_interval"), Value: 41250},
+		{Key: erlpack.Atom("_trace"), Value: []interface{}{[]byte("gateway-prd")}},
+	}, nil, nil)
+	p, err := ParsePayload(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Op != OpHello || p.S != nil || p.T != nil || p.Event() != "" {
+		t.Fatalf("unexpected payload: %+v", p)
+	}
+	var hello Hello
+	if err = p.Cast(&hello); err != nil {
+		t.Fatal(err)
+	}
+	if hello.HeartbeatInterval != 41250 {
+		t.Fatal("unexpected heartbeat interval:", hello.HeartbeatInterval)
+	}
+
+	// Test a dispatch.
+	b = gatewayPayload(t, OpDispatch, erlpack.OrderedMap{
+		{Key: erlpack.Atom("v"), Value: 10},
+		{Key: erlpack.Atom("user"), Value: erlpack.OrderedMap{
+			{Key: erlpack.Atom("id"), Value: []byte("80351110224678912")},
+			{Key: erlpack.Atom("username"), Value: []byte("Nelly")},
+			{Key: erlpack.Atom("discriminator"), Value: []byte("0")},
+			{Key: erlpack.Atom("global_name"), Value: nil},
+			{Key: erlpack.Atom("avatar"), Value: []byte("8342729096ea3675442027381ff50dfe")},
+			{Key: erlpack.Atom("bot"), Value: true},
+		}},
+		{Key: erlpack.Atom("guilds"), Value: []interface{}{
+			erlpack.OrderedMap{{Key: erlpack.Atom("unavailable"), Value: true}, {Key: erlpack.Atom("id"), Value: []byte("41771983423143937")}},
+		}},
+		{Key: erlpack.Atom("session_id"), Value: []byte("abc")},
+		{Key: erlpack.Atom("resume_gateway_url"), Value: []byte("wss://gateway.discord.gg")},
+		{Key: erlpack.Atom("application"), Value: erlpack.OrderedMap{
+			{Key: erlpack.Atom("id"), Value: uint64(80351110224678912)},
+			{Key: erlpack.Atom("flags"), Value: 0},
+		}},
+	}, 1, erlpack.Atom(EventReady))
+	if p, err = ParsePayload(b); err != nil {
+		t.Fatal(err)
+	}
+	if p.Op != OpDispatch || p.Sequence() != 1 || p.Event() != EventReady {
+		t.Fatalf("unexpected payload: %+v", p)
+	}
+	ready, err := erlpack.CastAs[Ready](p.D)
+	if err != nil {
+		t.Fatal(err)
+	}
+	avatar := "8342729096ea3675442027381ff50dfe"
+	expected := Ready{
+		V: 10,
+		User: User{
+			ID: 80351110224678912, Username: "Nelly", Discriminator: "0", Avatar: &avatar, Bot: true,
+		},
+		Guilds:           []UnavailableGuild{{ID: 41771983423143937, Unavailable: true}},
+		SessionID:        "abc",
+		ResumeGatewayURL: "wss://gateway.discord.gg",
+		Application:      Application{ID: 80351110224678912},
+	}
+	if !reflect.DeepEqual(ready, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, ready)
+	}
+}
+
+// TestNewPayload is used to test creating payloads to send to the gateway.
+func TestNewPayload(t *testing.T) {
+	p, err := NewPayload(OpIdentify, Identify{
+		Token:      "token",
+		Properties: IdentifyProperties{OS: "linux", Browser: "go-erlpack", Device: "go-erlpack"},
+		Intents:    513,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := p.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParsePayload(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identify, err := erlpack.CastAs[Identify](parsed.D)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Op != OpIdentify || identify.Token != "token" || identify.Properties.OS != "linux" || identify.Intents != 513 {
+		t.Fatalf("unexpected result: %+v %+v", parsed, identify)
+	}
+
+	// Heartbeats are the sequence number or nil.
+	for _, seq := range []*int64{nil, new(int64)} {
+		if seq != nil {
+			*seq = 300
+		}
+		p, err = NewPayload(OpHeartbeat, Heartbeat{Seq: seq})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var h Heartbeat
+		if err = p.Cast(&h); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(h.Seq, seq) {
+			t.Fatalf("expected %v, got %v", seq, h.Seq)
+		}
+	}
+
+	// A payload with no data should be packed with nil data.
+	b, err = (&GatewayPayload{Op: OpHeartbeat}).Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]interface{}
+	if err = erlpack.Unpack(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if d, ok := v["d"]; !ok || d != nil {
+		t.Fatalf("unexpected data: %#v", v)
+	}
+}
+
+// TestOpcodeString is used to test getting the names of opcodes.
+func TestOpcodeString(t *testing.T) {
+	if s := OpHeartbeatACK.String(); s != "Heartbeat ACK" {
+		t.Fatal("unexpected name:", s)
+	}
+	if s := Opcode(5).String(); s != "Opcode(5)" {
+		t.Fatal("unexpected name:", s)
+	}
+}
@@ -0,0 +1,139 @@
+package discord
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Epoch is the first millisecond of 2015, which the timestamps in snowflakes are relative to.
+const Epoch = 1420070400000
+
+// SnowflakeFormat is used to define how snowflakes are packed.
+type SnowflakeFormat int
+
+const (
+	// SnowflakesAsBinaries is used to pack snowflakes as binaries containing the decimal digits, which is how Discord
+	// sends most IDs.
+	SnowflakesAsBinaries SnowflakeFormat = iota
+
+	// SnowflakesAsIntegers is used to pack snowflakes as integers.
+	SnowflakesAsIntegers
+)
+
+// WithSnowflakeFormat is used to get a copy of the encoder options which packs snowflakes in the format specified.
+// The options are only changed for the encoder they are given to, so different encoders can use different formats.
+func WithSnowflakeFormat(Options erlpack.EncoderOptions, Format SnowflakeFormat) erlpack.EncoderOptions {
+	marshalers := make(map[reflect.Type]func(interface{}) ([]byte, error), len(Options.Marshalers)+1)
+	for t, m := range Options.Marshalers {
+		marshalers[t] = m
+	}
+	marshalers[reflect.TypeOf(Snowflake(0))] = func(v interface{}) ([]byte, error) {
+		return v.(Snowflake).pack(Format)
+	}
+	Options.Marshalers = marshalers
+	return Options
+}
+
+// Snowflake is used to define a Discord ID. It can be unpacked from a binary containing the decimal digits or from
+// a integer (including SMALL_BIG_EXT), since Discord sends both. It is packed as a binary unless the encoder options
+// are from WithSnowflakeFormat.
+type Snowflake uint64
+
+// ParseSnowflake is used to parse a snowflake from its decimal digits.
+func ParseSnowflake(s string) (Snowflake, error) {
+	u, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid snowflake: " + strconv.Quote(s))
+	}
+	return Snowflake(u), nil
+}
+
+// String is used to get the decimal digits of the snowflake.
+func (s Snowflake) String() string {
+	return strconv.FormatUint(uint64(s), 10)
+}
+
+// Timestamp is used to get the time the snowflake was created.
+func (s Snowflake) Timestamp() time.Time {
+	ms := int64(s>>22) + Epoch
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+}
+
+// Worker is used to get the ID of the worker which created the snowflake.
+func (s Snowflake) Worker() uint8 {
+	return uint8(s>>17) & 0x1f
+}
+
+// Process is used to get the ID of the process which created the snowflake.
+func (s Snowflake) Process() uint8 {
+	return uint8(s>>12) & 0x1f
+}
+
+// Increment is used to get the number of snowflakes the process had created before this one.
+func (s Snowflake) Increment() uint16 {
+	return uint16(s) & 0xfff
+}
+
+// MarshalErlpack is used to pack the snowflake as a binary containing the decimal digits.
+func (s Snowflake) MarshalErlpack() ([]byte, error) {
+	return s.pack(SnowflakesAsBinaries)
+}
+
+// Used to pack the snowflake in the format specified.
+func (s Snowflake) pack(Format SnowflakeFormat) ([]byte, error) {
+	if Format == SnowflakesAsIntegers {
+		if s > math.MaxInt64 {
+			return erlpack.AppendValue(nil, uint64(s))
+		}
+		return erlpack.AppendInt(nil, int64(s)), nil
+	}
+	return erlpack.AppendString(nil, s.String()), nil
+}
+
+// UnmarshalErlpack is used to unpack the snowflake from a binary or integer. nil is unpacked as 0.
+func (s *Snowflake) UnmarshalErlpack(r erlpack.RawData) error {
+	var i interface{}
+	if err := r.Cast(&i); err != nil {
+		return err
+	}
+	switch x := i.(type) {
+	case nil:
+		*s = 0
+	case []byte:
+		return s.parse(string(x))
+	case string:
+		return s.parse(x)
+	case uint8:
+		*s = Snowflake(x)
+	case int32:
+		if x < 0 {
+			return errors.New("snowflake can't be negative")
+		}
+		*s = Snowflake(x)
+	case int64:
+		if x < 0 {
+			return errors.New("snowflake can't be negative")
+		}
+		*s = Snowflake(x)
+	case uint64:
+		*s = Snowflake(x)
+	default:
+		return errors.New("could not de-serialize into snowflake")
+	}
+	return nil
+}
+
+// Used to set the snowflake from its decimal digits.
+func (s *Snowflake) parse(Digits string) error {
+	p, err := ParseSnowflake(Digits)
+	if err != nil {
+		return err
+	}
+	*s = p
+	return nil
+}
@@ -0,0 +1,121 @@
+package discord
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// TestSnowflakeUnpack is used to test unpacking snowflakes from each form Discord sends them in.
+func TestSnowflakeUnpack(t *testing.T) {
+	large, _ := new(big.Int).SetString("18446744073709551615", 10)
+	tests := []struct {
+		name  string
+		value interface{}
+		want  Snowflake
+		err   bool
+	}{
+		{name: "binary", value: []byte("175928847299117063"), want: 175928847299117063},
+		{name: "small int", value: 200, want: 200},
+		{name: "int32", value: 1 << 30, want: 1 << 30},
+		{name: "small big", value: int64(175928847299117063), want: 175928847299117063},
+		{name: "small big max", value: large, want: 18446744073709551615},
+		{name: "nil", value: nil, want: 0},
+		{name: "negative", value: -1, err: true},
+		{name: "invalid binary", value: []byte("abc"), err: true},
+		{name: "binary too large", value: []byte("18446744073709551616"), err: true},
+		{name: "float", value: 1.5, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := erlpack.Pack(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var s Snowflake
+			err = erlpack.Unpack(b, &s)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected error, got", s)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s != tt.want {
+				t.Fatal("unexpected snowflake:", s)
+			}
+		})
+	}
+}
+
+// TestSnowflakePack is used to test packing snowflakes in each format.
+func TestSnowflakePack(t *testing.T) {
+	s := Snowflake(175928847299117063)
+
+	// Snowflakes should be binaries by default.
+	b, err := erlpack.Pack(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, append([]byte{131}, erlpack.AppendString(nil, "175928847299117063")...)) {
+		t.Fatal("unexpected bytes:", b)
+	}
+
+	// They can be packed as integers instead, without changing how other encoders pack them.
+	opts := WithSnowflakeFormat(erlpack.EncoderOptions{}, SnowflakesAsIntegers)
+	for _, s := range []interface{}{Snowflake(5), s, Snowflake(18446744073709551615), &s} {
+		if b, err = erlpack.PackWithOptions(s, opts); err != nil {
+			t.Fatal(err)
+		}
+		var u uint64
+		if err = erlpack.Unpack(b, &u); err != nil {
+			t.Fatal(err)
+		}
+		if x, ok := s.(Snowflake); (ok && u != uint64(x)) || (!ok && u != 175928847299117063) {
+			t.Fatal("unexpected integer:", u)
+		}
+	}
+	if b, err = erlpack.Pack(s); err != nil || b[1] != 'm' {
+		t.Fatal("expected snowflake to be packed as a binary:", b, err)
+	}
+
+	// Both formats should unpack into structs.
+	for _, f := range []SnowflakeFormat{SnowflakesAsBinaries, SnowflakesAsIntegers} {
+		m := Message{ID: s, ChannelID: 1, Author: User{ID: 2}}
+		if b, err = erlpack.PackWithOptions(m, WithSnowflakeFormat(erlpack.EncoderOptions{}, f)); err != nil {
+			t.Fatal(err)
+		}
+		var out Message
+		if err = erlpack.Unpack(b, &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.ID != s || out.ChannelID != 1 || out.Author.ID != 2 {
+			t.Fatalf("unexpected message: %+v", out)
+		}
+	}
+}
+
+// TestSnowflakeFields is used to test getting the fields of a snowflake.
+func TestSnowflakeFields(t *testing.T) {
+	s, err := ParseSnowflake("175928847299117063")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Timestamp().Equal(time.Date(2016, 4, 30, 11, 18, 25, 796*int(time.Millisecond), time.UTC)) {
+		t.Fatal("unexpected timestamp:", s.Timestamp().UTC())
+	}
+	if s.Worker() != 1 || s.Process() != 0 || s.Increment() != 7 {
+		t.Fatal("unexpected fields:", s.Worker(), s.Process(), s.Increment())
+	}
+	if s.String() != "175928847299117063" {
+		t.Fatal("unexpected string:", s.String())
+	}
+	if _, err = ParseSnowflake("-1"); err == nil {
+		t.Fatal("expected error for negative snowflake")
+	}
+}
@@ -0,0 +1,180 @@
+package discord
+
+import (
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Hello is used to define the data of a Hello payload.
+type Hello struct {
+	// HeartbeatInterval is the number of milliseconds between each heartbeat.
+	HeartbeatInterval int64 `erlpack:"heartbeat_interval"`
+}
+
+// IdentifyProperties is used to define the connection properties sent with Identify.
+type IdentifyProperties struct {
+	OS      string `erlpack:"os"`
+	Browser string `erlpack:"browser"`
+	Device  string `erlpack:"device"`
+}
+
+// Identify is used to define the data of a Identify payload.
+type Identify struct {
+	Token          string             `erlpack:"token"`
+	Properties     IdentifyProperties `erlpack:"properties"`
+	Compress       bool               `erlpack:"compress,omitempty"`
+	LargeThreshold int                `erlpack:"large_threshold,omitempty"`
+	Shard          []int              `erlpack:"shard,omitempty"`
+	Presence       *PresenceUpdate    `erlpack:"presence,omitempty"`
+	Intents        int                `erlpack:"intents"`
+}
+
+// Resume is used to define the data of a Resume payload.
+type Resume struct {
+	Token     string `erlpack:"token"`
+	SessionID string `erlpack:"session_id"`
+	Seq       int64  `erlpack:"seq"`
+}
+
+// Heartbeat is used to define the data of a Heartbeat payload, which is the last sequence number received or nil.
+type Heartbeat struct {
+	// Seq is the last sequence number received, or nil if no dispatches have been received.
+	Seq *int64
+}
+
+// MarshalErlpack is used to pack the heartbeat as the sequence number or nil.
+func (h Heartbeat) MarshalErlpack() ([]byte, error) {
+	if h.Seq == nil {
+		return erlpack.AppendNil(nil), nil
+	}
+	return erlpack.AppendInt(nil, *h.Seq), nil
+}
+
+// UnmarshalErlpack is used to unpack the heartbeat from the sequence number or nil.
+func (h *Heartbeat) UnmarshalErlpack(r erlpack.RawData) error {
+	var seq *int64
+	if err := r.Cast(&seq); err != nil {
+		return err
+	}
+	h.Seq = seq
+	return nil
+}
+
+// InvalidSession is used to define the data of a Invalid Session payload.
+type InvalidSession bool
+
+// PresenceUpdate is used to define the data of a Presence Update payload.
+type PresenceUpdate struct {
+	Since      *int64     `erlpack:"since"`
+	Activities []Activity `erlpack:"activities"`
+	Status     string     `erlpack:"status"`
+	AFK        bool       `erlpack:"afk"`
+}
+
+// Activity is used to define an activity within a presence.
+type Activity struct {
+	Name  string  `erlpack:"name"`
+	Type  int     `erlpack:"type"`
+	URL   *string `erlpack:"url,omitempty"`
+	State *string `erlpack:"state,omitempty"`
+}
+
+// User is used to define a Discord user.
+type User struct {
+	ID            Snowflake `erlpack:"id"`
+	Username      string    `erlpack:"username"`
+	Discriminator string    `erlpack:"discriminator"`
+	GlobalName    *string   `erlpack:"global_name"`
+	Avatar        *string   `erlpack:"avatar"`
+	Bot           bool      `erlpack:"bot,omitempty"`
+}
+
+// UnavailableGuild is used to define a guild which has not been sent yet (or is unavailable).
+type UnavailableGuild struct {
+	ID          Snowflake `erlpack:"id"`
+	Unavailable bool      `erlpack:"unavailable"`
+}
+
+// Application is used to define the partial application sent with Ready.
+type Application struct {
+	ID    Snowflake `erlpack:"id"`
+	Flags int       `erlpack:"flags"`
+}
+
+// Ready is used to define the data of the READY event.
+type Ready struct {
+	V                int                `erlpack:"v"`
+	User             User               `erlpack:"user"`
+	Guilds           []UnavailableGuild `erlpack:"guilds"`
+	SessionID        string             `erlpack:"session_id"`
+	ResumeGatewayURL string             `erlpack:"resume_gateway_url"`
+	Shard            []int              `erlpack:"shard,omitempty"`
+	Application      Application        `erlpack:"application"`
+}
+
+// Member is used to define the member of a guild.
+type Member struct {
+	User     *User       `erlpack:"user,omitempty"`
+	Nick     *string     `erlpack:"nick"`
+	Roles    []Snowflake `erlpack:"roles"`
+	JoinedAt string      `erlpack:"joined_at"`
+}
+
+// Message is used to define the data of the MESSAGE_CREATE and MESSAGE_UPDATE events.
+type Message struct {
+	ID              Snowflake `erlpack:"id"`
+	ChannelID       Snowflake `erlpack:"channel_id"`
+	GuildID         Snowflake `erlpack:"guild_id,omitempty"`
+	Author          User      `erlpack:"author"`
+	Member          *Member   `erlpack:"member,omitempty"`
+	Content         string    `erlpack:"content"`
+	Timestamp       string    `erlpack:"timestamp"`
+	EditedTimestamp *string   `erlpack:"edited_timestamp"`
+	TTS             bool      `erlpack:"tts"`
+	MentionEveryone bool      `erlpack:"mention_everyone"`
+	Mentions        []User    `erlpack:"mentions"`
+	Pinned          bool      `erlpack:"pinned"`
+	Type            int       `erlpack:"type"`
+}
+
+// MessageDelete is used to define the data of the MESSAGE_DELETE event.
+type MessageDelete struct {
+	ID        Snowflake `erlpack:"id"`
+	ChannelID Snowflake `erlpack:"channel_id"`
+	GuildID   Snowflake `erlpack:"guild_id,omitempty"`
+}
+
+// Guild is used to define the data of the GUILD_CREATE and GUILD_UPDATE events. Only the common fields are included;
+// use the raw payload data for anything else.
+type Guild struct {
+	ID          Snowflake `erlpack:"id"`
+	Name        string    `erlpack:"name"`
+	Icon        *string   `erlpack:"icon"`
+	OwnerID     Snowflake `erlpack:"owner_id"`
+	Unavailable bool      `erlpack:"unavailable,omitempty"`
+	MemberCount int       `erlpack:"member_count,omitempty"`
+	Members     []Member  `erlpack:"members,omitempty"`
+}
+
+// GuildDelete is used to define the data of the GUILD_DELETE event.
+type GuildDelete UnavailableGuild
+
+// TypingStart is used to define the data of the TYPING_START event.
+type TypingStart struct {
+	ChannelID Snowflake `erlpack:"channel_id"`
+	GuildID   Snowflake `erlpack:"guild_id,omitempty"`
+	UserID    Snowflake `erlpack:"user_id"`
+	Timestamp int64     `erlpack:"timestamp"`
+}
+
+// Defines the names of the events with types in this package.
+const (
+	EventReady         = "READY"
+	EventResumed       = "RESUMED"
+	EventMessageCreate = "MESSAGE_CREATE"
+	EventMessageUpdate = "MESSAGE_UPDATE"
+	EventMessageDelete = "MESSAGE_DELETE"
+	EventGuildCreate   = "GUILD_CREATE"
+	EventGuildUpdate   = "GUILD_UPDATE"
+	EventGuildDelete   = "GUILD_DELETE"
+	EventTypingStart   = "TYPING_START"
+)
@@ -0,0 +1,113 @@
+package discord
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Defines the suffix of the data the gateway sends at the end of each payload (a zlib sync flush).
+var zlibSuffix = []byte{0x00, 0x00, 0xff, 0xff}
+
+// Defines the size of the deflate window.
+const windowSize = 32 * 1024
+
+// ZlibStream is used to inflate the binary frames sent by the gateway when it is connected with
+// compress=zlib-stream. The gateway uses one zlib context for the whole connection and flushes it at the end of each
+// payload, so a payload can be split across frames and can refer back to data from previous payloads. A new
+// ZlibStream should be used for each connection. ZlibStream is not safe for concurrent use.
+type ZlibStream struct {
+	// MaxPayloadSize is the largest number of bytes a payload can inflate to. If this is 0, there is no limit.
+	MaxPayloadSize int
+
+	buf     []byte
+	window  []byte
+	flate   io.ReadCloser
+	started bool
+	err     error
+}
+
+// Feed is used to add a binary frame from the gateway. If the frame completes a payload, a reader containing the
+// inflated payload is returned which can be given to erlpack.UnpackReader. Otherwise, nil is returned and more frames
+// are needed. Once an error is returned, the stream can't be used again.
+func (z *ZlibStream) Feed(Frame []byte) (io.Reader, error) {
+	if z.err != nil {
+		return nil, z.err
+	}
+
+	// Buffer the frame until the flush suffix is received.
+	z.buf = append(z.buf, Frame...)
+	if !bytes.HasSuffix(z.buf, zlibSuffix) {
+		if z.MaxPayloadSize > 0 && len(z.buf) > z.MaxPayloadSize {
+			z.err = errors.New("payload is too large")
+			return nil, z.err
+		}
+		return nil, nil
+	}
+	chunk := z.buf
+	z.buf = nil
+
+	// Inflate the chunk.
+	payload, err := z.inflate(chunk)
+	if err != nil {
+		z.err = err
+		return nil, err
+	}
+	return bytes.NewReader(payload), nil
+}
+
+// FeedPayload is used to add a binary frame from the gateway and parse the payload if the frame completes one.
+// Otherwise, nil is returned and more frames are needed.
+func (z *ZlibStream) FeedPayload(Frame []byte) (*GatewayPayload, error) {
+	r, err := z.Feed(Frame)
+	if r == nil || err != nil {
+		return nil, err
+	}
+	p := &GatewayPayload{}
+	if err = erlpack.UnpackReader(r, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Used to inflate a chunk of the stream ending with a flush.
+func (z *ZlibStream) inflate(chunk []byte) ([]byte, error) {
+	if !z.started {
+		// Check and remove the zlib header. The header can't ask for a preset dictionary.
+		if len(chunk) < 2 || chunk[0]&0x0f != 8 || (uint16(chunk[0])<<8|uint16(chunk[1]))%31 != 0 || chunk[1]&0x20 != 0 {
+			return nil, errors.New("invalid zlib header")
+		}
+		z.flate = flate.NewReader(bytes.NewReader(chunk[2:]))
+		z.started = true
+	} else {
+		// Each chunk ends on a byte boundary after a flush, so the only state the next chunk needs is the window of
+		// previous output.
+		if err := z.flate.(flate.Resetter).Reset(bytes.NewReader(chunk), z.window); err != nil {
+			return nil, err
+		}
+	}
+
+	// Read until the end of the chunk. The inflater reports the end of the chunk as a unexpected EOF since the
+	// stream itself has not ended.
+	var r io.Reader = z.flate
+	if z.MaxPayloadSize > 0 {
+		r = io.LimitReader(r, int64(z.MaxPayloadSize)+1)
+	}
+	payload, err := io.ReadAll(r)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if z.MaxPayloadSize > 0 && len(payload) > z.MaxPayloadSize {
+		return nil, errors.New("payload is too large")
+	}
+
+	// Keep the end of the output for the next chunk.
+	z.window = append(z.window, payload...)
+	if len(z.window) > windowSize {
+		z.window = append(z.window[:0], z.window[len(z.window)-windowSize:]...)
+	}
+	return payload, nil
+}
@@ -0,0 +1,126 @@
+package discord
+
+import (
+	"bytes"
+	"compress/zlib"
+	"strings"
+	"testing"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Used to compress payloads the way the gateway does, with one zlib context flushed after each payload.
+func zlibStreamChunks(t *testing.T, payloads ...[]byte) [][]byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := zlib.NewWriter(buf)
+	chunks := [][]byte{}
+	for _, p := range payloads {
+		if _, err := w.Write(p); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, append([]byte(nil), buf.Bytes()...))
+		buf.Reset()
+	}
+	return chunks
+}
+
+// TestZlibStream is used to test inflating payloads which share a zlib context and are split across frames.
+func TestZlibStream(t *testing.T) {
+	// Make payloads which repeat each other so the later ones refer back to earlier data.
+	payloads := [][]byte{}
+	for i := 0; i < 5; i++ {
+		p, err := NewPayload(OpDispatch, erlpack.OrderedMap{
+			{Key: erlpack.Atom("content"), Value: strings.Repeat("hello world ", 1000*(i+1))},
+			{Key: erlpack.Atom("i"), Value: i},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := p.Pack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		payloads = append(payloads, b)
+	}
+	chunks := zlibStreamChunks(t, payloads...)
+
+	z := &ZlibStream{}
+	for i, chunk := range chunks {
+		// Split each chunk into frames of 7 bytes.
+		got := false
+		for len(chunk) > 0 {
+			n := 7
+			if n > len(chunk) {
+				n = len(chunk)
+			}
+			reader, err := z.Feed(chunk[:n])
+			if err != nil {
+				t.Fatal(err)
+			}
+			chunk = chunk[n:]
+			if len(chunk) != 0 && reader != nil {
+				t.Fatal("payload returned before the flush")
+			}
+			if reader != nil {
+				got = true
+				var p GatewayPayload
+				if err = erlpack.UnpackReader(reader, &p); err != nil {
+					t.Fatal(err)
+				}
+				var d struct {
+					Content string `erlpack:"content"`
+					I       int    `erlpack:"i"`
+				}
+				if err = p.Cast(&d); err != nil {
+					t.Fatal(err)
+				}
+				if d.I != i || len(d.Content) != 12000*(i+1) {
+					t.Fatalf("unexpected payload %d: %d %d", i, d.I, len(d.Content))
+				}
+			}
+		}
+		if !got {
+			t.Fatal("no payload returned for chunk", i)
+		}
+	}
+}
+
+// TestZlibStreamErrors is used to test invalid streams return errors.
+func TestZlibStreamErrors(t *testing.T) {
+	z := &ZlibStream{}
+	if _, err := z.Feed([]byte{1, 2, 0, 0, 0xff, 0xff}); err == nil {
+		t.Fatal("expected error for invalid header")
+	}
+	if _, err := z.Feed([]byte{0, 0, 0xff, 0xff}); err == nil {
+		t.Fatal("expected the error to be kept")
+	}
+
+	// Payloads larger than the maximum size should error.
+	chunks := zlibStreamChunks(t, bytes.Repeat([]byte{'a'}, 1000))
+	z = &ZlibStream{MaxPayloadSize: 999}
+	if _, err := z.Feed(chunks[0]); err == nil {
+		t.Fatal("expected error for large payload")
+	}
+
+	// FeedPayload should parse payloads.
+	p, err := NewPayload(OpHello, Hello{HeartbeatInterval: 41250})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := p.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	z = &ZlibStream{}
+	parsed, err := z.FeedPayload(zlibStreamChunks(t, b)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Op != OpHello {
+		t.Fatal("unexpected opcode:", parsed.Op)
+	}
+}
@@ -0,0 +1,350 @@
+package dist
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Defines the tag of a message which is passed through without a distribution header.
+const passThrough = 'p'
+
+// Defines the most fragmented messages which can be received at the same time.
+const maxFragmentedMessages = 64
+
+// Message is used to define a message sent between nodes. The control message says what the message is (such as a
+// send to a process), and the payload is the term being sent, if there is one. Atom cache references are resolved, so
+// both can be used without the distribution header.
+type Message struct {
+	// Control is the control message, which is a tuple. ParseControl can be used to get the type of control message.
+	Control erlpack.RawData
+
+	// Payload is the message being sent, or nil if the control message does not have one.
+	Payload erlpack.RawData
+}
+
+// Conn is used to define a connection to another node after the handshake. Messages can be sent from any goroutine,
+// but only one goroutine should read messages.
+type Conn struct {
+	// PeerName is the name of the other node.
+	PeerName erlpack.Atom
+
+	// PeerFlags is the capability flags the other node sent.
+	PeerFlags Flags
+
+	// PeerCreation is the creation of the other node.
+	PeerCreation uint32
+
+	// Flags is the capability flags both nodes have.
+	Flags Flags
+
+	c            net.Conn
+	tickInterval time.Duration
+	fragmentSize int
+	fr           *erlpack.FrameReader
+	atomCache    AtomCache
+	fragments    map[uint64]*fragmentedMessage
+	buffered     int
+	maxSize      int
+
+	writeLock  sync.Mutex
+	fw         *erlpack.FrameWriter
+	written    int32
+	sequenceID uint64
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Used to define a fragmented message which is being received.
+type fragmentedMessage struct {
+	atoms []erlpack.Atom
+	next  uint64
+	data  []byte
+}
+
+// Used to create a connection after the handshake and start sending ticks.
+func newConn(n *Node, c net.Conn, PeerName erlpack.Atom, PeerFlags Flags, PeerCreation uint32) *Conn {
+	tick := n.TickInterval
+	if tick <= 0 {
+		tick = DefaultTickInterval
+	}
+	fragmentSize := n.FragmentSize
+	if fragmentSize <= 0 {
+		fragmentSize = DefaultFragmentSize
+	}
+	maxSize := n.MaxMessageSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+	conn := &Conn{
+		PeerName:     PeerName,
+		PeerFlags:    PeerFlags,
+		PeerCreation: PeerCreation,
+		Flags:        n.flags() & PeerFlags,
+		c:            c,
+		tickInterval: tick,
+		fragmentSize: fragmentSize,
+		fr:           erlpack.NewFrameReader(c, 4),
+		fw:           erlpack.NewFrameWriter(c, 4),
+		fragments:    map[uint64]*fragmentedMessage{},
+		maxSize:      maxSize,
+		done:         make(chan struct{}),
+	}
+	conn.fr.MaxFrameSize = maxSize
+	go conn.tick()
+	return conn
+}
+
+// Used to send a tick whenever nothing has been written for a tick interval.
+func (c *Conn) tick() {
+	t := time.NewTicker(c.tickInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-t.C:
+		}
+		if atomic.SwapInt32(&c.written, 0) == 1 {
+			continue
+		}
+		if err := c.writeFrames([][]byte{nil}); err != nil {
+			_ = c.Close()
+			return
+		}
+	}
+}
+
+// Used to write frames without any other frames between them. A empty frame is a tick.
+func (c *Conn) writeFrames(Frames [][]byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return c.writeFramesLocked(Frames)
+}
+
+// Used to write frames when the write lock is held.
+func (c *Conn) writeFramesLocked(Frames [][]byte) error {
+	for _, frame := range Frames {
+		if err := c.fw.WriteFrame(frame); err != nil {
+			return err
+		}
+		if len(frame) != 0 {
+			atomic.StoreInt32(&c.written, 1)
+		}
+	}
+	return nil
+}
+
+// ReadMessage is used to read the next message from the other node. Ticks are handled without being returned. If
+// nothing is received for 4 tick intervals, or a frame can't be read or parsed, the connection is closed and a error
+// is returned.
+func (c *Conn) ReadMessage() (*Message, error) {
+	for {
+		frame, err := c.readFrame()
+		if err == nil && len(frame) == 0 {
+			// This is a tick.
+			continue
+		}
+		var m *Message
+		if err == nil {
+			m, err = c.parseFrame(frame)
+		}
+		if err != nil {
+			// The state of the stream (such as the atom cache and fragmented messages) can't be trusted after this,
+			// so drop the fragmented messages and close the connection.
+			c.fragments = map[uint64]*fragmentedMessage{}
+			c.buffered = 0
+			_ = c.Close()
+			return nil, err
+		}
+		if m != nil {
+			return m, nil
+		}
+	}
+}
+
+// Used to read the next frame, closing the connection if nothing is received for 4 tick intervals.
+func (c *Conn) readFrame() ([]byte, error) {
+	if err := c.c.SetReadDeadline(time.Now().Add(4 * c.tickInterval)); err != nil {
+		return nil, err
+	}
+	frame, err := c.fr.Next()
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			_ = c.Close()
+			return nil, errors.New("node did not send anything for 4 tick intervals")
+		}
+		return nil, err
+	}
+	return frame, nil
+}
+
+// Used to parse a frame. If the frame is a fragment of a message which hasn't been fully received, nil is returned.
+func (c *Conn) parseFrame(frame []byte) (*Message, error) {
+	if frame[0] == passThrough {
+		return parseTerms(frame[1:], true, nil)
+	}
+	if len(frame) < 2 || frame[0] != versionMagic {
+		return nil, errors.New("unsupported message type")
+	}
+	switch frame[1] {
+	case distHeader:
+		h, data, err := ParseHeader(frame, &c.atomCache)
+		if err != nil {
+			return nil, err
+		}
+		return parseTerms(data, false, h.Atoms())
+	case distFragHeader:
+		h, data, err := ParseHeader(frame, &c.atomCache)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := c.fragments[h.SequenceID]; ok {
+			c.dropFragments(h.SequenceID)
+			return nil, errors.New("fragmented message was started twice")
+		}
+		if h.FragmentID == 1 {
+			return parseTerms(data, false, h.Atoms())
+		}
+		if len(c.fragments) >= maxFragmentedMessages {
+			return nil, errors.New("too many fragmented messages are being received")
+		}
+		if c.buffered+len(data) > c.maxSize {
+			return nil, errors.New("fragmented messages are larger than the maximum message size")
+		}
+		c.fragments[h.SequenceID] = &fragmentedMessage{
+			atoms: h.Atoms(), next: h.FragmentID - 1, data: append([]byte(nil), data...),
+		}
+		c.buffered += len(data)
+		return nil, nil
+	case distFragContinue:
+		sequenceID, fragmentID, data, err := parseFragmentHeader(frame)
+		if err != nil {
+			return nil, err
+		}
+		f, ok := c.fragments[sequenceID]
+		if !ok || fragmentID != f.next {
+			c.dropFragments(sequenceID)
+			return nil, errors.New("fragment was received out of order")
+		}
+		if c.buffered+len(data) > c.maxSize {
+			c.dropFragments(sequenceID)
+			return nil, errors.New("fragmented messages are larger than the maximum message size")
+		}
+		f.data = append(f.data, data...)
+		c.buffered += len(data)
+		if f.next--; f.next != 0 {
+			return nil, nil
+		}
+		c.dropFragments(sequenceID)
+		return parseTerms(f.data, false, f.atoms)
+	default:
+		return nil, errors.New("unsupported message type")
+	}
+}
+
+// Used to remove a fragmented message which is being received.
+func (c *Conn) dropFragments(SequenceID uint64) {
+	if f, ok := c.fragments[SequenceID]; ok {
+		c.buffered -= len(f.data)
+		delete(c.fragments, SequenceID)
+	}
+}
+
+// Used to parse the control message and payload. Terms after a distribution header don't have version bytes.
+func parseTerms(b []byte, Versioned bool, Atoms []erlpack.Atom) (*Message, error) {
+	r := bytes.NewReader(b)
+	opts := erlpack.DecoderOptions{AtomCacheRefs: Atoms}
+	unpack := func(Ptr *erlpack.RawData) error {
+		if Versioned {
+			return erlpack.UnpackReaderWithOptions(r, Ptr, opts)
+		}
+		return erlpack.UnpackReaderWithOptions(io.MultiReader(bytes.NewReader([]byte{versionMagic}), r), Ptr, opts)
+	}
+	m := &Message{}
+	if err := unpack(&m.Control); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		if err := unpack(&m.Payload); err != nil {
+			return nil, err
+		}
+	}
+	if r.Len() != 0 {
+		return nil, errors.New("message has data after the payload")
+	}
+	return m, nil
+}
+
+// Send is used to send a control message (such as RegSend) and a payload to the other node. If both nodes have
+// FlagDistHdrAtomCache, the message has a distribution header (without atom cache references), and large messages
+// are split into fragments if both nodes have FlagFragments.
+func (c *Conn) Send(Control, Payload interface{}) error {
+	return c.send(Control, Payload, true)
+}
+
+// SendControl is used to send a control message which does not have a payload to the other node.
+func (c *Conn) SendControl(Control interface{}) error {
+	return c.send(Control, nil, false)
+}
+
+// Used to pack and send a message.
+func (c *Conn) send(Control, Payload interface{}, HasPayload bool) error {
+	control, err := erlpack.Pack(Control)
+	if err != nil {
+		return err
+	}
+	var payload []byte
+	if HasPayload {
+		if payload, err = erlpack.Pack(Payload); err != nil {
+			return err
+		}
+	}
+	if !c.Flags.Has(FlagDistHdrAtomCache) {
+		// Pass the message through without a distribution header.
+		frame := append([]byte{passThrough}, control...)
+		return c.writeFrames([][]byte{append(frame, payload...)})
+	}
+
+	// Remove the version bytes, since terms after a distribution header don't have them.
+	data := control[1:]
+	if HasPayload {
+		data = append(data, payload[1:]...)
+	}
+	fragmentSize := 0
+	if c.Flags.Has(FlagFragments) {
+		fragmentSize = c.fragmentSize
+	}
+
+	// The fragments of a message are written together, so they are all sent before any other message.
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	c.sequenceID++
+	frames, err := Fragment(Header{SequenceID: c.sequenceID}, data, fragmentSize)
+	if err != nil {
+		return err
+	}
+	return c.writeFramesLocked(frames)
+}
+
+// Done is used to get a channel which is closed when the connection is closed.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close is used to close the connection.
+func (c *Conn) Close() error {
+	err := errors.New("connection is already closed")
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.c.Close()
+	})
+	return err
+}
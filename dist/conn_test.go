@@ -0,0 +1,116 @@
+package dist
+
+import (
+	"testing"
+	"time"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// TestConnMessages is used to test sending messages between 2 nodes.
+func TestConnMessages(t *testing.T) {
+	a := &Node{Name: "a@localhost", Cookie: "secret"}
+	b := &Node{Name: "b@localhost", Cookie: "secret"}
+	connA, connB, errA, errB := connectPair(t, a, b)
+	if errA != nil || errB != nil {
+		t.Fatal(errA, errB)
+	}
+
+	// Send a message to a registered name, like REG_SEND.
+	from := a.NewPid()
+	errs := make(chan error, 2)
+	go func() {
+		errs <- connA.Send(erlpack.Tuple{6, from, erlpack.Atom(""), erlpack.Atom("server")}, erlpack.Tuple{erlpack.Atom("hello"), []byte("world")})
+		errs <- connA.SendControl(erlpack.Tuple{1, from, b.NewPid()})
+	}()
+	m, err := connB.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var control erlpack.Tuple
+	if err = m.Control.Cast(&control); err != nil {
+		t.Fatal(err)
+	}
+	if len(control) != 4 || control[0] != uint8(6) || control[1] != from || control[3] != erlpack.Atom("server") {
+		t.Fatal("unexpected control message:", control)
+	}
+	var payload erlpack.Tuple
+	if err = m.Payload.Cast(&payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload[0] != erlpack.Atom("hello") || string(payload[1].([]byte)) != "world" {
+		t.Fatal("unexpected payload:", payload)
+	}
+
+	// Control messages without a payload should not have one.
+	if m, err = connB.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Payload != nil {
+		t.Fatal("unexpected payload:", m.Payload)
+	}
+	for i := 0; i < 2; i++ {
+		if err = <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestConnTicks is used to test that ticks keep the connection alive, and that it is closed if they stop.
+func TestConnTicks(t *testing.T) {
+	a := &Node{Name: "a@localhost", Cookie: "secret", TickInterval: 10 * time.Millisecond}
+	b := &Node{Name: "b@localhost", Cookie: "secret", TickInterval: 20 * time.Millisecond}
+	connA, connB, errA, errB := connectPair(t, a, b)
+	if errA != nil || errB != nil {
+		t.Fatal(errA, errB)
+	}
+
+	// Nothing is sent for a lot longer than the timeout, but the ticks should keep the connection open.
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		_ = connA.SendControl(erlpack.Tuple{erlpack.Atom("ping")})
+	}()
+	go func() {
+		// Read the ticks node b sends.
+		for {
+			if _, err := connA.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	m, err := connB.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var control erlpack.Tuple
+	if err = m.Control.Cast(&control); err != nil || control[0] != erlpack.Atom("ping") {
+		t.Fatal("unexpected control message:", control, err)
+	}
+
+	// If node a stops sending anything, node b should close the connection.
+	c := &Node{Name: "c@localhost", Cookie: "secret", TickInterval: time.Hour}
+	d := &Node{Name: "d@localhost", Cookie: "secret", TickInterval: 10 * time.Millisecond}
+	connC, connD, errC, errD := connectPair(t, c, d)
+	if errC != nil || errD != nil {
+		t.Fatal(errC, errD)
+	}
+	go func() {
+		for {
+			if _, err := connC.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	start := time.Now()
+	if _, err = connD.ReadMessage(); err == nil {
+		t.Fatal("expected error when no ticks are received")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("connection took too long to time out")
+	}
+	select {
+	case <-connD.Done():
+	default:
+		t.Fatal("connection was not closed")
+	}
+}
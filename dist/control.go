@@ -0,0 +1,317 @@
+package dist
+
+import (
+	"errors"
+	"math/big"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Defines the operations of the control messages.
+const (
+	opLink         = 1
+	opSend         = 2
+	opExit         = 3
+	opRegSend      = 6
+	opExit2        = 8
+	opMonitorP     = 19
+	opDemonitorP   = 20
+	opMonitorPExit = 21
+	opSendSender   = 22
+	opUnlinkID     = 35
+	opUnlinkIDAck  = 36
+)
+
+// Used to pack a control message tuple.
+func packControl(Op uint8, Items ...interface{}) ([]byte, error) {
+	b, err := erlpack.Pack(append(erlpack.Tuple{Op}, Items...))
+	if err != nil {
+		return nil, err
+	}
+	return b[1:], nil
+}
+
+// Used to unpack a control message tuple into pointers to its items, checking the operation. A nil pointer skips the
+// item.
+func unpackControl(r erlpack.RawData, Op uint8, Ptrs ...interface{}) error {
+	var t erlpack.Tuple
+	if err := r.Cast(&t); err != nil {
+		return err
+	}
+	if len(t) != len(Ptrs)+1 || t[0] != Op {
+		return errors.New("unexpected control message")
+	}
+	for i, ptr := range Ptrs {
+		item := t[i+1]
+		ok := true
+		switch x := ptr.(type) {
+		case nil:
+		case *interface{}:
+			*x = item
+		case *erlpack.Pid:
+			*x, ok = item.(erlpack.Pid)
+		case *erlpack.Atom:
+			*x, ok = item.(erlpack.Atom)
+		case *erlpack.Reference:
+			*x, ok = item.(erlpack.Reference)
+		case *uint64:
+			*x, ok = controlUint64(item)
+		}
+		if !ok {
+			return errors.New("control message has a item of the wrong type")
+		}
+	}
+	return nil
+}
+
+// Used to get a unsigned integer from a control message.
+func controlUint64(Item interface{}) (uint64, bool) {
+	switch x := Item.(type) {
+	case uint8:
+		return uint64(x), true
+	case int32:
+		return uint64(x), x >= 0
+	case int64:
+		return uint64(x), x >= 0
+	case uint64:
+		return x, true
+	case *big.Int:
+		return x.Uint64(), x.IsUint64()
+	}
+	return 0, false
+}
+
+// Link is used to define the LINK control message, which links 2 processes.
+type Link struct {
+	From, To erlpack.Pid
+}
+
+// MarshalErlpack is used to pack the control message.
+func (c Link) MarshalErlpack() ([]byte, error) {
+	return packControl(opLink, c.From, c.To)
+}
+
+// UnmarshalErlpack is used to unpack the control message.
+func (c *Link) UnmarshalErlpack(r erlpack.RawData) error {
+	return unpackControl(r, opLink, &c.From, &c.To)
+}
+
+// Send is used to define the SEND control message, which sends the payload to a process.
+type Send struct {
+	To erlpack.Pid
+}
+
+// MarshalErlpack is used to pack the control message.
+func (c Send) MarshalErlpack() ([]byte, error) {
+	return packControl(opSend, erlpack.Atom(""), c.To)
+}
+
+// UnmarshalErlpack is used to unpack the control message.
+func (c *Send) UnmarshalErlpack(r erlpack.RawData) error {
+	return unpackControl(r, opSend, nil, &c.To)
+}
+
+// Exit is used to define the EXIT control message, which is sent when a linked process exits.
+type Exit struct {
+	From, To erlpack.Pid
+
+	// Reason is the exit reason, such as erlpack.Atom("normal").
+	Reason interface{}
+}
+
+// MarshalErlpack is used to pack the control message.
+func (c Exit) MarshalErlpack() ([]byte, error) {
+	return packControl(opExit, c.From, c.To, c.Reason)
+}
+
+// UnmarshalErlpack is used to unpack the control message.
+func (c *Exit) UnmarshalErlpack(r erlpack.RawData) error {
+	return unpackControl(r, opExit, &c.From, &c.To, &c.Reason)
+}
+
+// RegSend is used to define the REG_SEND control message, which sends the payload to a registered name.
+type RegSend struct {
+	From erlpack.Pid
+	To   erlpack.Atom
+}
+
+// MarshalErlpack is used to pack the control message.
+func (c RegSend) MarshalErlpack() ([]byte, error) {
+	return packControl(opRegSend, c.From, erlpack.Atom(""), c.To)
+}
+
+// UnmarshalErlpack is used to unpack the control message.
+func (c *RegSend) UnmarshalErlpack(r erlpack.RawData) error {
+	return unpackControl(r, opRegSend, &c.From, nil, &c.To)
+}
+
+// Exit2 is used to define the EXIT2 control message, which is sent by exit/2 to make a process exit.
+type Exit2 struct {
+	From, To erlpack.Pid
+
+	// Reason is the exit reason, such as erlpack.Atom("kill").
+	Reason interface{}
+}
+
+// MarshalErlpack is used to pack the control message.
+func (c Exit2) MarshalErlpack() ([]byte, error) {
+	return packControl(opExit2, c.From, c.To, c.Reason)
+}
+
+// UnmarshalErlpack is used to unpack the control message.
+func (c *Exit2) UnmarshalErlpack(r erlpack.RawData) error {
+	return unpackControl(r, opExit2, &c.From, &c.To, &c.Reason)
+}
+
+// MonitorP is used to define the MONITOR_P control message, which starts monitoring a process.
+type MonitorP struct {
+	From erlpack.Pid
+
+	// To is the pid or registered name (an erlpack.Atom) of the process being monitored.
+	To interface{}
+
+	Ref erlpack.Reference
+}
+
+// MarshalErlpack is used to pack the control message.
+func (c MonitorP) MarshalErlpack() ([]byte, error) {
+	return packControl(opMonitorP, c.From, c.To, c.Ref)
+}
+
+// UnmarshalErlpack is used to unpack the control message.
+func (c *MonitorP) UnmarshalErlpack(r erlpack.RawData) error {
+	return unpackControl(r, opMonitorP, &c.From, &c.To, &c.Ref)
+}
+
+// DemonitorP is used to define the DEMONITOR_P control message, which stops monitoring a process.
+type DemonitorP struct {
+	From erlpack.Pid
+
+	// To is the pid or registered name (an erlpack.Atom) of the process being monitored.
+	To interface{}
+
+	Ref erlpack.Reference
+}
+
+// MarshalErlpack is used to pack the control message.
+func (c DemonitorP) MarshalErlpack() ([]byte, error) {
+	return packControl(opDemonitorP, c.From, c.To, c.Ref)
+}
+
+// UnmarshalErlpack is used to unpack the control message.
+func (c *DemonitorP) UnmarshalErlpack(r erlpack.RawData) error {
+	return unpackControl(r, opDemonitorP, &c.From, &c.To, &c.Ref)
+}
+
+// MonitorPExit is used to define the MONITOR_P_EXIT control message, which is sent when a monitored process exits.
+type MonitorPExit struct {
+	// From is the pid or registered name (an erlpack.Atom) of the process which exited.
+	From interface{}
+
+	To  erlpack.Pid
+	Ref erlpack.Reference
+
+	// Reason is the exit reason, such as erlpack.Atom("normal").
+	Reason interface{}
+}
+
+// MarshalErlpack is used to pack the control message.
+func (c MonitorPExit) MarshalErlpack() ([]byte, error) {
+	return packControl(opMonitorPExit, c.From, c.To, c.Ref, c.Reason)
+}
+
+// UnmarshalErlpack is used to unpack the control message.
+func (c *MonitorPExit) UnmarshalErlpack(r erlpack.RawData) error {
+	return unpackControl(r, opMonitorPExit, &c.From, &c.To, &c.Ref, &c.Reason)
+}
+
+// SendSender is used to define the SEND_SENDER control message, which sends the payload to a process and includes
+// the sender. This is used instead of Send if both nodes have FlagSendSender.
+type SendSender struct {
+	From, To erlpack.Pid
+}
+
+// MarshalErlpack is used to pack the control message.
+func (c SendSender) MarshalErlpack() ([]byte, error) {
+	return packControl(opSendSender, c.From, c.To)
+}
+
+// UnmarshalErlpack is used to unpack the control message.
+func (c *SendSender) UnmarshalErlpack(r erlpack.RawData) error {
+	return unpackControl(r, opSendSender, &c.From, &c.To)
+}
+
+// UnlinkID is used to define the UNLINK_ID control message, which removes a link between 2 processes.
+type UnlinkID struct {
+	ID       uint64
+	From, To erlpack.Pid
+}
+
+// MarshalErlpack is used to pack the control message.
+func (c UnlinkID) MarshalErlpack() ([]byte, error) {
+	return packControl(opUnlinkID, c.ID, c.From, c.To)
+}
+
+// UnmarshalErlpack is used to unpack the control message.
+func (c *UnlinkID) UnmarshalErlpack(r erlpack.RawData) error {
+	return unpackControl(r, opUnlinkID, &c.ID, &c.From, &c.To)
+}
+
+// UnlinkIDAck is used to define the UNLINK_ID_ACK control message, which is sent back when a UnlinkID is received.
+type UnlinkIDAck struct {
+	ID       uint64
+	From, To erlpack.Pid
+}
+
+// MarshalErlpack is used to pack the control message.
+func (c UnlinkIDAck) MarshalErlpack() ([]byte, error) {
+	return packControl(opUnlinkIDAck, c.ID, c.From, c.To)
+}
+
+// UnmarshalErlpack is used to unpack the control message.
+func (c *UnlinkIDAck) UnmarshalErlpack(r erlpack.RawData) error {
+	return unpackControl(r, opUnlinkIDAck, &c.ID, &c.From, &c.To)
+}
+
+// ParseControl is used to parse a control message into one of the control message types of this package (such as
+// *RegSend). Control messages which this package doesn't have a type for are returned as a erlpack.Tuple.
+func ParseControl(Control erlpack.RawData) (interface{}, error) {
+	var t erlpack.Tuple
+	if err := Control.Cast(&t); err != nil {
+		return nil, err
+	}
+	if len(t) == 0 {
+		return nil, errors.New("control message is empty")
+	}
+	var c erlpack.Unmarshaler
+	switch t[0] {
+	case uint8(opLink):
+		c = &Link{}
+	case uint8(opSend):
+		c = &Send{}
+	case uint8(opExit):
+		c = &Exit{}
+	case uint8(opRegSend):
+		c = &RegSend{}
+	case uint8(opExit2):
+		c = &Exit2{}
+	case uint8(opMonitorP):
+		c = &MonitorP{}
+	case uint8(opDemonitorP):
+		c = &DemonitorP{}
+	case uint8(opMonitorPExit):
+		c = &MonitorPExit{}
+	case uint8(opSendSender):
+		c = &SendSender{}
+	case uint8(opUnlinkID):
+		c = &UnlinkID{}
+	case uint8(opUnlinkIDAck):
+		c = &UnlinkIDAck{}
+	default:
+		return t, nil
+	}
+	if err := c.UnmarshalErlpack(Control); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
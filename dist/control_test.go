@@ -0,0 +1,71 @@
+package dist
+
+import (
+	"reflect"
+	"testing"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// TestControlMessages is used to test control messages are packed as Erlang expects and parsed back.
+func TestControlMessages(t *testing.T) {
+	from := erlpack.Pid{Node: "a@localhost", ID: 1, Creation: 5}
+	to := erlpack.Pid{Node: "b@localhost", ID: 2, Serial: 1, Creation: 6}
+	ref := erlpack.Reference{Node: "a@localhost", Creation: 5, ID: []uint32{1, 2, 3}}
+	tests := []struct {
+		name    string
+		control interface{}
+		tuple   erlpack.Tuple
+	}{
+		{"link", &Link{From: from, To: to}, erlpack.Tuple{1, from, to}},
+		{"send", &Send{To: to}, erlpack.Tuple{2, erlpack.Atom(""), to}},
+		{"exit", &Exit{From: from, To: to, Reason: erlpack.Atom("normal")}, erlpack.Tuple{3, from, to, erlpack.Atom("normal")}},
+		{"reg_send", &RegSend{From: from, To: "server"}, erlpack.Tuple{6, from, erlpack.Atom(""), erlpack.Atom("server")}},
+		{"exit2", &Exit2{From: from, To: to, Reason: erlpack.Atom("kill")}, erlpack.Tuple{8, from, to, erlpack.Atom("kill")}},
+		{"monitor_p", &MonitorP{From: from, To: erlpack.Atom("server"), Ref: ref}, erlpack.Tuple{19, from, erlpack.Atom("server"), ref}},
+		{"demonitor_p", &DemonitorP{From: from, To: to, Ref: ref}, erlpack.Tuple{20, from, to, ref}},
+		{"monitor_p_exit", &MonitorPExit{From: to, To: from, Ref: ref, Reason: erlpack.Atom("noproc")}, erlpack.Tuple{21, to, from, ref, erlpack.Atom("noproc")}},
+		{"send_sender", &SendSender{From: from, To: to}, erlpack.Tuple{22, from, to}},
+		{"unlink_id", &UnlinkID{ID: 1 << 40, From: from, To: to}, erlpack.Tuple{35, uint64(1 << 40), from, to}},
+		{"unlink_id_ack", &UnlinkIDAck{ID: 7, From: from, To: to}, erlpack.Tuple{36, 7, from, to}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The control message should pack the same as the tuple.
+			got, err := erlpack.Pack(tt.control)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := erlpack.Pack(tt.tuple)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("unexpected bytes:\n%v\n%v", got, want)
+			}
+
+			// Parsing the tuple should give back the control message.
+			parsed, err := ParseControl(erlpack.RawData(want[1:]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(parsed, tt.control) {
+				t.Fatalf("unexpected control message: %#v", parsed)
+			}
+		})
+	}
+
+	// Unknown control messages should be returned as a tuple, and ones with the wrong items should error.
+	b, _ := erlpack.Pack(erlpack.Tuple{29, from})
+	if c, err := ParseControl(erlpack.RawData(b[1:])); err != nil || len(c.(erlpack.Tuple)) != 2 {
+		t.Fatal("unexpected result:", c, err)
+	}
+	b, _ = erlpack.Pack(erlpack.Tuple{1, from, erlpack.Atom("server")})
+	if _, err := ParseControl(erlpack.RawData(b[1:])); err == nil {
+		t.Fatal("expected error for link to a name")
+	}
+	var link Link
+	if err := erlpack.Unpack(b, &link); err == nil {
+		t.Fatal("expected error unpacking into a link")
+	}
+}
@@ -0,0 +1,108 @@
+package dist
+
+// Flags is used to define the capability flags exchanged during the handshake.
+type Flags uint64
+
+const (
+	// FlagPublished is used to show the node should be published and part of the global namespace. Hidden nodes do
+	// not set this.
+	FlagPublished Flags = 0x1
+
+	// FlagAtomCache is used to show the node implements a atom cache (obsolete).
+	FlagAtomCache Flags = 0x2
+
+	// FlagExtendedReferences is used to show the node implements extended (3 × 32 bits) references.
+	FlagExtendedReferences Flags = 0x4
+
+	// FlagDistMonitor is used to show the node implements distributed process monitoring.
+	FlagDistMonitor Flags = 0x8
+
+	// FlagFunTags is used to show the node uses separate tags for funs in the distribution protocol.
+	FlagFunTags Flags = 0x10
+
+	// FlagDistMonitorName is used to show the node implements distributed named process monitoring.
+	FlagDistMonitorName Flags = 0x20
+
+	// FlagHiddenAtomCache is used to show the (hidden) node implements a atom cache (obsolete).
+	FlagHiddenAtomCache Flags = 0x40
+
+	// FlagNewFunTags is used to show the node understands the NEW_FUN_EXT tag.
+	FlagNewFunTags Flags = 0x80
+
+	// FlagExtendedPidsPorts is used to show the node can handle extended pids and ports.
+	FlagExtendedPidsPorts Flags = 0x100
+
+	// FlagExportPtrTag is used to show the node understands the EXPORT_EXT tag.
+	FlagExportPtrTag Flags = 0x200
+
+	// FlagBitBinaries is used to show the node understands the BIT_BINARY_EXT tag.
+	FlagBitBinaries Flags = 0x400
+
+	// FlagNewFloats is used to show the node understands the NEW_FLOAT_EXT tag.
+	FlagNewFloats Flags = 0x800
+
+	// FlagUnicodeIO is used to show the node supports unicode IO.
+	FlagUnicodeIO Flags = 0x1000
+
+	// FlagDistHdrAtomCache is used to show the node implements the atom cache in the distribution header.
+	FlagDistHdrAtomCache Flags = 0x2000
+
+	// FlagSmallAtomTags is used to show the node understands the SMALL_ATOM_EXT tag.
+	FlagSmallAtomTags Flags = 0x4000
+
+	// FlagUTF8Atoms is used to show the node understands UTF-8 atoms.
+	FlagUTF8Atoms Flags = 0x10000
+
+	// FlagMapTag is used to show the node understands the MAP_EXT tag.
+	FlagMapTag Flags = 0x20000
+
+	// FlagBigCreation is used to show the node understands 32 bit creations.
+	FlagBigCreation Flags = 0x40000
+
+	// FlagSendSender is used to show the node uses the SEND_SENDER control message instead of SEND.
+	FlagSendSender Flags = 0x80000
+
+	// FlagBigSeqTraceLabels is used to show the node understands any term as the seqtrace label.
+	FlagBigSeqTraceLabels Flags = 0x100000
+
+	// FlagExitPayload is used to show the node uses the payload versions of the exit control messages.
+	FlagExitPayload Flags = 0x400000
+
+	// FlagFragments is used to show the node understands fragmented messages.
+	FlagFragments Flags = 0x800000
+
+	// FlagHandshake23 is used to show the node supports the handshake introduced in OTP 23.
+	FlagHandshake23 Flags = 0x1000000
+
+	// FlagUnlinkID is used to show the node uses the new link protocol.
+	FlagUnlinkID Flags = 0x2000000
+
+	// FlagSpawn is used to show the node supports distributed spawn.
+	FlagSpawn Flags = 1 << 32
+
+	// FlagNameMe is used to show the node wants the other node to give it a name.
+	FlagNameMe Flags = 1 << 33
+
+	// FlagV4NC is used to show the node understands 64 bit port IDs and larger pids and references.
+	FlagV4NC Flags = 1 << 34
+
+	// FlagAlias is used to show the node supports process aliases.
+	FlagAlias Flags = 1 << 35
+
+	// FlagMandatory25Digest is used to show the node supports all the capabilities which are mandatory in OTP 25.
+	FlagMandatory25Digest Flags = 1 << 36
+)
+
+// RequiredFlags is the flags the other node has to support for a connection to be made.
+const RequiredFlags = FlagExtendedReferences | FlagExtendedPidsPorts | FlagUTF8Atoms | FlagNewFloats | FlagMapTag |
+	FlagBigCreation | FlagHandshake23
+
+// DefaultFlags is the flags used if Node.Flags is 0. This is the flags for a hidden node which only sends and
+// receives messages, plus FlagV4NC and FlagUnlinkID which OTP 26 and later require.
+const DefaultFlags = RequiredFlags | FlagFunTags | FlagNewFunTags | FlagExportPtrTag | FlagBitBinaries |
+	FlagDistHdrAtomCache | FlagSmallAtomTags | FlagFragments | FlagV4NC | FlagUnlinkID
+
+// Has is used to check if all of the flags specified are set.
+func (f Flags) Has(Flags Flags) bool {
+	return f&Flags == Flags
+}
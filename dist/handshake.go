@@ -0,0 +1,389 @@
+// Package dist is used to connect to Erlang nodes with the distribution protocol. A Node does the handshake on a
+// connection (either side of it), and the resulting Conn sends and receives messages and keeps the connection alive
+// with ticks. Go nodes are hidden by default, so they are not part of the global namespace of the cluster. Control
+// messages (such as RegSend) have a type for each operation, which can be sent with Conn.Send and parsed with
+// ParseControl.
+package dist
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// DefaultTickInterval is the tick interval used if Node.TickInterval is 0. This matches the default net_ticktime of 60
+// seconds, which Erlang divides by 4.
+const DefaultTickInterval = 15 * time.Second
+
+// DefaultFragmentSize is the fragment size used if Node.FragmentSize is 0.
+const DefaultFragmentSize = 64 * 1024
+
+// DefaultMaxMessageSize is the maximum message size used if Node.MaxMessageSize is 0.
+const DefaultMaxMessageSize = 64 << 20
+
+// Node is used to define the local node. The fields should be set before connections are made, and not changed after.
+type Node struct {
+	// Name is the full name of the node (name@host).
+	Name string
+
+	// Cookie is the magic cookie shared by the nodes of the cluster.
+	Cookie string
+
+	// Flags is the capability flags sent during the handshake. If this is 0, DefaultFlags is used. The connection uses
+	// the flags both nodes have.
+	Flags Flags
+
+	// Creation is the creation of the node, which is normally given by epmd. If this is 0, a random creation is used.
+	Creation uint32
+
+	// Published is used to make the node visible to the other nodes of the cluster. By default, it is hidden.
+	Published bool
+
+	// TickInterval is how often a tick is sent when nothing else has been. If nothing is received for 4 intervals, the
+	// connection is closed.
+	TickInterval time.Duration
+
+	// FragmentSize is the largest a message can be before it is split into fragments, if both nodes have
+	// FlagFragments. If this is 0, DefaultFragmentSize is used.
+	FragmentSize int
+
+	// MaxMessageSize is the largest message in bytes which will be received. This also limits the total size of the
+	// fragments of messages which have not been fully received. If this is 0, DefaultMaxMessageSize is used.
+	MaxMessageSize int
+
+	mu      sync.Mutex
+	lastPid uint32
+	lastRef uint64
+}
+
+// HandshakeError is used to define a error returned by the other node during the handshake.
+type HandshakeError struct {
+	// Status is the status the other node sent (such as "nok", "not_allowed" or "alive").
+	Status string
+}
+
+// Error is used to get the error message.
+func (e *HandshakeError) Error() string {
+	return "handshake failed with status " + e.Status
+}
+
+// Used to get the flags sent during the handshake.
+func (n *Node) flags() Flags {
+	f := n.Flags
+	if f == 0 {
+		f = DefaultFlags
+	}
+	if n.Published {
+		f |= FlagPublished
+	} else {
+		f &^= FlagPublished
+	}
+	return f
+}
+
+// Used to get the creation sent during the handshake, picking a random one the first time if it isn't set.
+func (n *Node) creation() uint32 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.Creation == 0 {
+		for n.Creation < 4 {
+			n.Creation = randomUint32()
+		}
+	}
+	return n.Creation
+}
+
+// NewPid is used to create a pid which belongs to this node, so Erlang processes can send messages back to it. Each
+// call returns a different pid.
+func (n *Node) NewPid() erlpack.Pid {
+	creation := n.creation()
+	n.mu.Lock()
+	n.lastPid++
+	id := n.lastPid
+	n.mu.Unlock()
+	return erlpack.Pid{Node: erlpack.Atom(n.Name), ID: id & 0x7fff, Serial: id >> 15, Creation: creation}
+}
+
+// NewReference is used to create a reference which belongs to this node, such as for a monitor. Each call returns a
+// different reference.
+func (n *Node) NewReference() erlpack.Reference {
+	creation := n.creation()
+	n.mu.Lock()
+	n.lastRef++
+	id := n.lastRef
+	n.mu.Unlock()
+	return erlpack.Reference{
+		Node:     erlpack.Atom(n.Name),
+		Creation: creation,
+		ID:       []uint32{uint32(id) & 0x3ffff, uint32(id >> 18), randomUint32()},
+	}
+}
+
+// Used to check the node has a valid name.
+func (n *Node) checkName() error {
+	if i := strings.IndexByte(n.Name, '@'); i < 1 || i == len(n.Name)-1 {
+		return errors.New("node name must be name@host")
+	}
+	return nil
+}
+
+// Used to get a random number.
+func randomUint32() uint32 {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return binary.BigEndian.Uint32(b[:])
+}
+
+// Used to append a big-endian uint16.
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// Used to append a big-endian uint32.
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// Used to append a big-endian uint64.
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
+
+// Used to get the digest of a challenge with a cookie.
+func digest(Challenge uint32, Cookie string) [16]byte {
+	return md5.Sum([]byte(Cookie + strconv.FormatUint(uint64(Challenge), 10)))
+}
+
+// Used to define the state of a handshake.
+type handshake struct {
+	node *Node
+	fr   *erlpack.FrameReader
+	fw   *erlpack.FrameWriter
+}
+
+// Used to read a handshake message, checking the tag.
+func (h *handshake) read(Tag byte) ([]byte, error) {
+	b, err := h.fr.Next()
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("handshake message is empty")
+	}
+	if b[0] == 's' && Tag != 's' {
+		// The other node sent a status instead.
+		return nil, &HandshakeError{Status: string(b[1:])}
+	}
+	if b[0] != Tag {
+		return nil, errors.New("expected handshake message " + strconv.QuoteRune(rune(Tag)) + ", got " +
+			strconv.QuoteRune(rune(b[0])))
+	}
+	return b[1:], nil
+}
+
+// Used to write the name and challenge messages, which both start with the flags and end with the node name.
+func (h *handshake) writeNamed(Challenge *uint32) error {
+	b := []byte{'N'}
+	b = appendUint64(b, uint64(h.node.flags()))
+	if Challenge != nil {
+		b = appendUint32(b, *Challenge)
+	}
+	b = appendUint32(b, h.node.creation())
+	b = appendUint16(b, uint16(len(h.node.Name)))
+	b = append(b, h.node.Name...)
+	return h.fw.WriteFrame(b)
+}
+
+// Used to parse the name and challenge messages.
+func parseNamed(b []byte, HasChallenge bool) (flags Flags, challenge, creation uint32, name string, err error) {
+	l := 14
+	if HasChallenge {
+		l += 4
+	}
+	if len(b) < l {
+		return 0, 0, 0, "", errors.New("handshake message is too short")
+	}
+	flags = Flags(binary.BigEndian.Uint64(b))
+	b = b[8:]
+	if HasChallenge {
+		challenge = binary.BigEndian.Uint32(b)
+		b = b[4:]
+	}
+	creation = binary.BigEndian.Uint32(b)
+	nameLen := int(binary.BigEndian.Uint16(b[4:]))
+	if len(b) != 6+nameLen {
+		return 0, 0, 0, "", errors.New("handshake message has the wrong length")
+	}
+	return flags, challenge, creation, string(b[6:]), nil
+}
+
+// Used to check the other node supports the flags which are needed.
+func checkFlags(f Flags) error {
+	if !f.Has(RequiredFlags) {
+		return errors.New("node does not support the required capabilities")
+	}
+	return nil
+}
+
+// Used to apply the deadline of a context to a connection during the handshake, and close the connection if the
+// context is cancelled. The returned function should be called once the handshake is done.
+func watchHandshake(ctx context.Context, c net.Conn) func() {
+	if d, ok := ctx.Deadline(); ok {
+		_ = c.SetDeadline(d)
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = c.Close()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		_ = c.SetDeadline(time.Time{})
+	}
+}
+
+// Connect is used to do the handshake on a connection to a node, as the node which started the connection. If the
+// handshake fails, the connection is closed.
+func (n *Node) Connect(ctx context.Context, c net.Conn) (*Conn, error) {
+	conn, err := n.connect(ctx, c)
+	if err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Used to do the handshake as the node which started the connection.
+func (n *Node) connect(ctx context.Context, c net.Conn) (*Conn, error) {
+	if err := n.checkName(); err != nil {
+		return nil, err
+	}
+	stop := watchHandshake(ctx, c)
+	defer stop()
+	h := &handshake{node: n, fr: erlpack.NewFrameReader(c, 2), fw: erlpack.NewFrameWriter(c, 2)}
+
+	// Send the name and get the status.
+	if err := h.writeNamed(nil); err != nil {
+		return nil, err
+	}
+	status, err := h.read('s')
+	if err != nil {
+		return nil, err
+	}
+	switch string(status) {
+	case "ok", "ok_simultaneous":
+	case "alive":
+		// There is already a connection from this node, so don't replace it.
+		_ = h.fw.WriteFrame([]byte("sfalse"))
+		return nil, &HandshakeError{Status: "alive"}
+	default:
+		return nil, &HandshakeError{Status: string(status)}
+	}
+
+	// Get the challenge from the other node.
+	b, err := h.read('N')
+	if err != nil {
+		return nil, err
+	}
+	flags, challenge, creation, name, err := parseNamed(b, true)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkFlags(flags); err != nil {
+		return nil, err
+	}
+
+	// Reply with the digest and our own challenge, and check the digest the other node sends back.
+	ours := randomUint32()
+	d := digest(challenge, n.Cookie)
+	reply := appendUint32([]byte{'r'}, ours)
+	if err = h.fw.WriteFrame(append(reply, d[:]...)); err != nil {
+		return nil, err
+	}
+	ack, err := h.read('a')
+	if err != nil {
+		return nil, err
+	}
+	want := digest(ours, n.Cookie)
+	if subtle.ConstantTimeCompare(ack, want[:]) != 1 {
+		return nil, errors.New("node sent the wrong digest")
+	}
+	return newConn(n, c, erlpack.Atom(name), flags, creation), nil
+}
+
+// Accept is used to do the handshake on a connection from a node, as the node which accepted the connection. If the
+// handshake fails, the connection is closed.
+func (n *Node) Accept(ctx context.Context, c net.Conn) (*Conn, error) {
+	conn, err := n.accept(ctx, c)
+	if err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Used to do the handshake as the node which accepted the connection.
+func (n *Node) accept(ctx context.Context, c net.Conn) (*Conn, error) {
+	if err := n.checkName(); err != nil {
+		return nil, err
+	}
+	stop := watchHandshake(ctx, c)
+	defer stop()
+	h := &handshake{node: n, fr: erlpack.NewFrameReader(c, 2), fw: erlpack.NewFrameWriter(c, 2)}
+
+	// Get the name of the other node.
+	b, err := h.read('N')
+	if err != nil {
+		return nil, err
+	}
+	flags, _, creation, name, err := parseNamed(b, false)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkFlags(flags); err != nil {
+		_ = h.fw.WriteFrame([]byte("snot_allowed"))
+		return nil, err
+	}
+
+	// Send the status and a challenge.
+	if err = h.fw.WriteFrame([]byte("sok")); err != nil {
+		return nil, err
+	}
+	ours := randomUint32()
+	if err = h.writeNamed(&ours); err != nil {
+		return nil, err
+	}
+
+	// Check the digest the other node sends, and reply with the digest of its challenge.
+	reply, err := h.read('r')
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) != 20 {
+		return nil, errors.New("challenge reply has the wrong length")
+	}
+	want := digest(ours, n.Cookie)
+	if subtle.ConstantTimeCompare(reply[4:], want[:]) != 1 {
+		return nil, errors.New("node sent the wrong digest")
+	}
+	d := digest(binary.BigEndian.Uint32(reply), n.Cookie)
+	if err = h.fw.WriteFrame(append([]byte{'a'}, d[:]...)); err != nil {
+		return nil, err
+	}
+	return newConn(n, c, erlpack.Atom(name), flags, creation), nil
+}
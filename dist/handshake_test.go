@@ -0,0 +1,148 @@
+package dist
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Used to connect 2 nodes in the same process. The first node starts the connection.
+func connectPair(t *testing.T, a, b *Node) (*Conn, *Conn, error, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ca, cb := net.Pipe()
+	type result struct {
+		conn *Conn
+		err  error
+	}
+	accepted := make(chan result, 1)
+	go func() {
+		conn, err := b.Accept(ctx, cb)
+		accepted <- result{conn, err}
+	}()
+	connA, errA := a.Connect(ctx, ca)
+	r := <-accepted
+	t.Cleanup(func() {
+		if connA != nil {
+			_ = connA.Close()
+		}
+		if r.conn != nil {
+			_ = r.conn.Close()
+		}
+	})
+	return connA, r.conn, errA, r.err
+}
+
+// TestHandshake is used to test the handshake between 2 nodes.
+func TestHandshake(t *testing.T) {
+	a := &Node{Name: "a@localhost", Cookie: "secret", Creation: 10}
+	b := &Node{Name: "b@localhost", Cookie: "secret", Flags: DefaultFlags | FlagDistMonitor, Published: true}
+	connA, connB, errA, errB := connectPair(t, a, b)
+	if errA != nil || errB != nil {
+		t.Fatal(errA, errB)
+	}
+
+	// Each node should know the name, flags and creation of the other.
+	if connA.PeerName != "b@localhost" || connB.PeerName != "a@localhost" {
+		t.Fatal("unexpected names:", connA.PeerName, connB.PeerName)
+	}
+	if connB.PeerCreation != 10 || connA.PeerCreation != b.Creation || b.Creation < 4 {
+		t.Fatal("unexpected creations:", connB.PeerCreation, connA.PeerCreation)
+	}
+	if connB.PeerFlags.Has(FlagPublished) || !connA.PeerFlags.Has(FlagPublished|FlagDistMonitor) {
+		t.Fatal("unexpected flags:", connA.PeerFlags, connB.PeerFlags)
+	}
+	if connA.Flags != DefaultFlags || connB.Flags != DefaultFlags {
+		t.Fatal("connection should use the flags both nodes have:", connA.Flags, connB.Flags)
+	}
+}
+
+// TestHandshakeErrors is used to test handshakes which should fail.
+func TestHandshakeErrors(t *testing.T) {
+	// Nodes with different cookies should not connect.
+	a := &Node{Name: "a@localhost", Cookie: "secret"}
+	_, _, errA, errB := connectPair(t, a, &Node{Name: "b@localhost", Cookie: "other"})
+	if errA == nil || errB == nil {
+		t.Fatal("expected errors for wrong cookie:", errA, errB)
+	}
+
+	// Nodes without the required flags should not be allowed.
+	old := &Node{Name: "old@localhost", Cookie: "secret", Flags: DefaultFlags &^ FlagUTF8Atoms}
+	_, _, errA, errB = connectPair(t, old, a)
+	var hsErr *HandshakeError
+	if !errors.As(errA, &hsErr) || hsErr.Status != "not_allowed" || errB == nil {
+		t.Fatal("expected not_allowed:", errA, errB)
+	}
+
+	// Invalid names should error before anything is sent.
+	ca, cb := net.Pipe()
+	defer cb.Close()
+	if _, err := (&Node{Name: "invalid"}).Connect(context.Background(), ca); err == nil {
+		t.Fatal("expected error for invalid name")
+	}
+}
+
+// TestHandshakeStatus is used to test statuses sent by the other node.
+func TestHandshakeStatus(t *testing.T) {
+	for _, status := range []string{"nok", "alive"} {
+		ca, cb := net.Pipe()
+		go func() {
+			// Read the name and send the status.
+			fr := erlpack.NewFrameReader(cb, 2)
+			if _, err := fr.Next(); err != nil {
+				return
+			}
+			_ = erlpack.NewFrameWriter(cb, 2).WriteFrame([]byte("s" + status))
+			_, _ = fr.Next()
+			_ = cb.Close()
+		}()
+		_, err := (&Node{Name: "a@localhost"}).Connect(context.Background(), ca)
+		var hsErr *HandshakeError
+		if !errors.As(err, &hsErr) || hsErr.Status != status {
+			t.Fatal("expected handshake error, got", err)
+		}
+	}
+
+	// A cancelled context should stop the handshake.
+	ca, cb := net.Pipe()
+	defer cb.Close()
+	go func() { _, _ = erlpack.NewFrameReader(cb, 2).Next() }()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := (&Node{Name: "a@localhost"}).Connect(ctx, ca); err == nil {
+		t.Fatal("expected error when the context is cancelled")
+	}
+}
+
+// TestDigest is used to test the challenge digest matches the one Erlang uses.
+func TestDigest(t *testing.T) {
+	for _, challenge := range []uint32{0, 12345, 4294967295} {
+		want := md5.Sum([]byte("cookie" + strconv.FormatUint(uint64(challenge), 10)))
+		if digest(challenge, "cookie") != want {
+			t.Fatal("unexpected digest for", challenge)
+		}
+	}
+	if digest(1, "cookie") == digest(1, "other") {
+		t.Fatal("digest should depend on the cookie")
+	}
+}
+
+// TestNodeIdentifiers is used to test creating pids and references which belong to a node.
+func TestNodeIdentifiers(t *testing.T) {
+	n := &Node{Name: "a@localhost", Creation: 5}
+	p1, p2 := n.NewPid(), n.NewPid()
+	if p1 == p2 || p1.Node != "a@localhost" || p1.Creation != 5 {
+		t.Fatal("unexpected pids:", p1, p2)
+	}
+	r1, r2 := n.NewReference(), n.NewReference()
+	if len(r1.ID) != 3 || r1.ID[0] == r2.ID[0] || r1.Node != "a@localhost" || r1.Creation != 5 {
+		t.Fatal("unexpected references:", r1, r2)
+	}
+}
@@ -0,0 +1,270 @@
+package dist
+
+import (
+	"encoding/binary"
+	"errors"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Defines the tags used at the start of messages with a distribution header.
+const (
+	versionMagic     = 131
+	distHeader       = 'D'
+	distFragHeader   = 'E'
+	distFragContinue = 'F'
+)
+
+// Defines the number of atoms in a atom cache.
+const atomCacheSize = 2048
+
+// AtomCache is used to define the atoms a node has sent in distribution headers, which later headers can refer to by
+// their index. A connection has a atom cache for each direction, which lasts for as long as the connection.
+type AtomCache struct {
+	atoms [atomCacheSize]erlpack.Atom
+	set   [atomCacheSize]bool
+}
+
+// AtomCacheRef is used to define a reference to a atom in a distribution header.
+type AtomCacheRef struct {
+	// Index is the index of the atom in the atom cache, from 0 to 2047.
+	Index uint16
+
+	// Atom is the atom the reference is to.
+	Atom erlpack.Atom
+
+	// New is used to show the atom is being added to the atom cache, so the text of the atom is sent.
+	New bool
+}
+
+// Header is used to define a distribution header. ATOM_CACHE_REF in the terms after the header refers to a atom by
+// its position in AtomCacheRefs.
+type Header struct {
+	// AtomCacheRefs is the atom cache references of the message.
+	AtomCacheRefs []AtomCacheRef
+
+	// Fragmented is used to show this is the header of the first fragment of a fragmented message.
+	Fragmented bool
+
+	// SequenceID is the ID of a fragmented message, which is the same for all of its fragments.
+	SequenceID uint64
+
+	// FragmentID is the ID of the first fragment of a fragmented message. Fragment IDs count down to 1, so this is
+	// also the number of fragments.
+	FragmentID uint64
+}
+
+// Atoms is used to get the atoms of the atom cache references, which can be used as erlpack.DecoderOptions.AtomCacheRefs
+// to unpack the terms after the header.
+func (h *Header) Atoms() []erlpack.Atom {
+	atoms := make([]erlpack.Atom, len(h.AtomCacheRefs))
+	for i, ref := range h.AtomCacheRefs {
+		atoms[i] = ref.Atom
+	}
+	return atoms
+}
+
+// Used to get the half byte of the flags for a atom cache reference.
+func headerFlag(Flags []byte, i int) byte {
+	if i%2 == 0 {
+		return Flags[i/2] & 0x0f
+	}
+	return Flags[i/2] >> 4
+}
+
+// ParseHeader is used to parse a distribution header (DIST_HEADER or DIST_FRAG_HEADER) at the start of a message.
+// New atoms are added to the cache, and the atoms of the other references are taken from it. The bytes after the
+// header are returned.
+func ParseHeader(b []byte, Cache *AtomCache) (*Header, []byte, error) {
+	tooShort := errors.New("distribution header is too short")
+	if len(b) < 3 || b[0] != versionMagic {
+		return nil, nil, errors.New("expected distribution header")
+	}
+	h := &Header{}
+	switch b[1] {
+	case distHeader:
+		b = b[2:]
+	case distFragHeader:
+		if len(b) < 19 {
+			return nil, nil, tooShort
+		}
+		h.Fragmented = true
+		h.SequenceID = binary.BigEndian.Uint64(b[2:])
+		h.FragmentID = binary.BigEndian.Uint64(b[10:])
+		if h.FragmentID == 0 {
+			return nil, nil, errors.New("fragment id can't be 0")
+		}
+		b = b[18:]
+	default:
+		return nil, nil, errors.New("expected distribution header")
+	}
+
+	// Get the flags. There is a half byte for each reference, then one for the header.
+	n := int(b[0])
+	b = b[1:]
+	if n == 0 {
+		return h, b, nil
+	}
+	flagLen := n/2 + 1
+	if len(b) < flagLen {
+		return nil, nil, tooShort
+	}
+	flags := b[:flagLen]
+	b = b[flagLen:]
+	longAtoms := headerFlag(flags, n)&0x1 != 0
+
+	// Read each reference.
+	h.AtomCacheRefs = make([]AtomCacheRef, n)
+	for i := range h.AtomCacheRefs {
+		flag := headerFlag(flags, i)
+		if len(b) < 1 {
+			return nil, nil, tooShort
+		}
+		ref := AtomCacheRef{Index: uint16(flag&0x7)<<8 | uint16(b[0]), New: flag&0x8 != 0}
+		b = b[1:]
+		if ref.New {
+			var l int
+			if longAtoms {
+				if len(b) < 2 {
+					return nil, nil, tooShort
+				}
+				l = int(binary.BigEndian.Uint16(b))
+				b = b[2:]
+			} else {
+				if len(b) < 1 {
+					return nil, nil, tooShort
+				}
+				l = int(b[0])
+				b = b[1:]
+			}
+			if len(b) < l {
+				return nil, nil, tooShort
+			}
+			ref.Atom = erlpack.Atom(b[:l])
+			b = b[l:]
+			Cache.atoms[ref.Index] = ref.Atom
+			Cache.set[ref.Index] = true
+		} else {
+			if !Cache.set[ref.Index] {
+				return nil, nil, errors.New("atom cache reference is not in the atom cache")
+			}
+			ref.Atom = Cache.atoms[ref.Index]
+		}
+		h.AtomCacheRefs[i] = ref
+	}
+	return h, b, nil
+}
+
+// Used to parse the header of a fragment after the first one. The bytes after the header are returned.
+func parseFragmentHeader(b []byte) (SequenceID, FragmentID uint64, Data []byte, err error) {
+	if len(b) < 18 || b[0] != versionMagic || b[1] != distFragContinue {
+		return 0, 0, nil, errors.New("expected fragment header")
+	}
+	return binary.BigEndian.Uint64(b[2:]), binary.BigEndian.Uint64(b[10:]), b[18:], nil
+}
+
+// AppendHeader is used to append a distribution header. If Header.Fragmented is set, DIST_FRAG_HEADER is used.
+func AppendHeader(b []byte, Header *Header) ([]byte, error) {
+	refs := Header.AtomCacheRefs
+	if len(refs) > 255 {
+		return nil, errors.New("distribution header can't have more than 255 atom cache references")
+	}
+	if Header.Fragmented {
+		b = append(b, versionMagic, distFragHeader)
+		b = appendUint64(b, Header.SequenceID)
+		b = appendUint64(b, Header.FragmentID)
+	} else {
+		b = append(b, versionMagic, distHeader)
+	}
+	b = append(b, byte(len(refs)))
+	if len(refs) == 0 {
+		return b, nil
+	}
+
+	// Write the flags, using 2 byte lengths if any of the new atoms need them.
+	flags := make([]byte, len(refs)/2+1)
+	setFlag := func(i int, flag byte) {
+		if i%2 == 0 {
+			flags[i/2] |= flag
+		} else {
+			flags[i/2] |= flag << 4
+		}
+	}
+	longAtoms := false
+	for i, ref := range refs {
+		if ref.Index >= atomCacheSize {
+			return nil, errors.New("atom cache index is too large")
+		}
+		flag := byte(ref.Index >> 8)
+		if ref.New {
+			flag |= 0x8
+			if len(ref.Atom) > 255 {
+				longAtoms = true
+			}
+			if len(ref.Atom) > 65535 {
+				return nil, errors.New("atom is too long")
+			}
+		}
+		setFlag(i, flag)
+	}
+	if longAtoms {
+		setFlag(len(refs), 0x1)
+	}
+	b = append(b, flags...)
+
+	// Write the references.
+	for _, ref := range refs {
+		b = append(b, byte(ref.Index))
+		if !ref.New {
+			continue
+		}
+		if longAtoms {
+			b = appendUint16(b, uint16(len(ref.Atom)))
+		} else {
+			b = append(b, byte(len(ref.Atom)))
+		}
+		b = append(b, ref.Atom...)
+	}
+	return b, nil
+}
+
+// Fragment is used to make the frames of a message with the header and data (the control message and payload
+// without version bytes) specified. If the data is larger than the fragment size, it is split into fragments with
+// the sequence ID of the header, and the header becomes the header of the first fragment.
+func Fragment(Header Header, Data []byte, FragmentSize int) ([][]byte, error) {
+	if FragmentSize <= 0 || len(Data) <= FragmentSize {
+		Header.Fragmented = false
+		b, err := AppendHeader(nil, &Header)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{append(b, Data...)}, nil
+	}
+
+	// Make the first fragment, which has the header.
+	count := uint64((len(Data) + FragmentSize - 1) / FragmentSize)
+	Header.Fragmented = true
+	Header.FragmentID = count
+	first, err := AppendHeader(nil, &Header)
+	if err != nil {
+		return nil, err
+	}
+	frames := make([][]byte, 0, count)
+	frames = append(frames, append(first, Data[:FragmentSize]...))
+	Data = Data[FragmentSize:]
+
+	// Make the rest of the fragments.
+	for id := count - 1; id > 0; id-- {
+		l := FragmentSize
+		if len(Data) < l {
+			l = len(Data)
+		}
+		b := make([]byte, 0, 18+l)
+		b = append(b, versionMagic, distFragContinue)
+		b = appendUint64(b, Header.SequenceID)
+		b = appendUint64(b, id)
+		frames = append(frames, append(b, Data[:l]...))
+		Data = Data[l:]
+	}
+	return frames, nil
+}
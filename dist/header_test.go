@@ -0,0 +1,271 @@
+package dist
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// TestHeader is used to test distribution headers are parsed and built the same as Erlang does.
+func TestHeader(t *testing.T) {
+	// A header with 2 new atoms (at index 261 and 3) and a reference to the atom at index 3.
+	b := []byte("\x83D\x03\x89\x00\x05\x0ba@localhost\x03\x04send\x03rest")
+	cache := &AtomCache{}
+	h, rest, err := ParseHeader(b, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []AtomCacheRef{
+		{Index: 261, Atom: "a@localhost", New: true},
+		{Index: 3, Atom: "send", New: true},
+		{Index: 3, Atom: "send"},
+	}
+	if len(h.AtomCacheRefs) != 3 || string(rest) != "rest" || h.Fragmented {
+		t.Fatalf("unexpected header: %+v %q", h, rest)
+	}
+	for i, ref := range h.AtomCacheRefs {
+		if ref != want[i] {
+			t.Fatalf("unexpected reference %d: %+v", i, ref)
+		}
+	}
+	if a := h.Atoms(); a[0] != "a@localhost" || a[2] != "send" {
+		t.Fatal("unexpected atoms:", a)
+	}
+	built, err := AppendHeader(nil, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(built, b[:len(b)-4]) {
+		t.Fatalf("unexpected header bytes: %q", built)
+	}
+
+	// Later headers can refer to atoms in the cache.
+	if h, _, err = ParseHeader([]byte("\x83D\x01\x01\x05"), cache); err != nil || h.AtomCacheRefs[0].Atom != "a@localhost" {
+		t.Fatalf("unexpected header: %+v (%v)", h, err)
+	}
+	if _, _, err = ParseHeader([]byte("\x83D\x01\x00\x07"), cache); err == nil {
+		t.Fatal("expected error for atom not in the cache")
+	}
+	if _, _, err = ParseHeader(b[:10], &AtomCache{}); err == nil {
+		t.Fatal("expected error for truncated header")
+	}
+
+	// Long atoms should use 2 byte lengths.
+	long := erlpack.Atom(strings.Repeat("a", 300))
+	h = &Header{AtomCacheRefs: []AtomCacheRef{{Index: 2047, Atom: long, New: true}}, Fragmented: true, SequenceID: 9,
+		FragmentID: 2}
+	if built, err = AppendHeader(nil, h); err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := ParseHeader(built, &AtomCache{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.AtomCacheRefs[0] != h.AtomCacheRefs[0] || !parsed.Fragmented || parsed.SequenceID != 9 ||
+		parsed.FragmentID != 2 {
+		t.Fatalf("unexpected header: %+v", parsed)
+	}
+}
+
+// TestConnHeaders is used to test messages with distribution headers and fragments are received.
+func TestConnHeaders(t *testing.T) {
+	a := &Node{Name: "a@localhost", Creation: 5}
+	local, remote := net.Pipe()
+	conn := newConn(a, local, "b@localhost", DefaultFlags, 6)
+	defer conn.Close()
+	fw := erlpack.NewFrameWriter(remote, 4)
+	go func() {
+		// {6, <b@localhost.1.0>, '', server} with the payload {hello, world}, using the atom cache.
+		header, _ := AppendHeader(nil, &Header{AtomCacheRefs: []AtomCacheRef{
+			{Index: 1, Atom: "b@localhost", New: true},
+			{Index: 2, Atom: "server", New: true},
+		}})
+		control := "h\x04a\x06XR\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x06w\x00R\x01"
+		_ = fw.WriteFrame(append(header, control+"h\x02w\x05hellow\x05world"...))
+
+		// The same message split into 3 fragments, which uses the atoms in the cache.
+		frames, _ := Fragment(Header{SequenceID: 1, AtomCacheRefs: []AtomCacheRef{{Index: 1}, {Index: 2}}},
+			[]byte(control+"h\x02w\x05hellow\x05world"), 12)
+		for _, f := range frames[:len(frames)-1] {
+			_ = fw.WriteFrame(f)
+		}
+
+		// A message without fragments can be sent between fragments.
+		_ = fw.WriteFrame([]byte("\x83D\x00h\x01a\x01"))
+		_ = fw.WriteFrame(frames[len(frames)-1])
+	}()
+
+	check := func(m *Message) {
+		t.Helper()
+		c, err := ParseControl(m.Control)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := &RegSend{From: erlpack.Pid{Node: "b@localhost", ID: 1, Creation: 6}, To: "server"}
+		if rs, ok := c.(*RegSend); !ok || *rs != *want {
+			t.Fatalf("unexpected control message: %#v", c)
+		}
+		var payload erlpack.Tuple
+		if err = m.Payload.Cast(&payload); err != nil || payload[1] != erlpack.Atom("world") {
+			t.Fatalf("unexpected payload: %v (%v)", payload, err)
+		}
+	}
+	_ = conn.c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	m, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(m)
+	if m, err = conn.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Payload != nil || !bytes.Equal(m.Control, []byte("h\x01a\x01")) {
+		t.Fatalf("unexpected message: %q %q", m.Control, m.Payload)
+	}
+	if m, err = conn.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+	check(m)
+}
+
+// TestConnFragments is used to test large messages are sent in fragments, and that nodes without distribution
+// headers are sent messages which are passed through.
+func TestConnFragments(t *testing.T) {
+	for _, flags := range []Flags{DefaultFlags, DefaultFlags &^ FlagDistHdrAtomCache, DefaultFlags &^ FlagFragments} {
+		a := &Node{Name: "a@localhost", Cookie: "secret", Flags: flags, FragmentSize: 100}
+		b := &Node{Name: "b@localhost", Cookie: "secret", FragmentSize: 100}
+		connA, connB, errA, errB := connectPair(t, a, b)
+		if errA != nil || errB != nil {
+			t.Fatal(errA, errB)
+		}
+
+		// Read the frames node a sends, so what was sent can be checked.
+		frames := make(chan []byte, 64)
+		go func() {
+			fr := erlpack.NewFrameReader(connB.c, 4)
+			for {
+				f, err := fr.Next()
+				if err != nil {
+					close(frames)
+					return
+				}
+				if len(f) != 0 {
+					frames <- append([]byte(nil), f...)
+				}
+			}
+		}()
+		payload := strings.Repeat("x", 1000)
+		go func() {
+			_ = connA.Send(RegSend{From: a.NewPid(), To: "server"}, payload)
+			_ = connA.Close()
+		}()
+		var received [][]byte
+		for f := range frames {
+			received = append(received, f)
+		}
+
+		// Check the frames and then parse them like node b would.
+		switch flags {
+		case DefaultFlags:
+			if len(received) != 11 || received[0][1] != distFragHeader || received[10][1] != distFragContinue {
+				t.Fatal("expected message in fragments, got", len(received), "frames")
+			}
+		case DefaultFlags &^ FlagDistHdrAtomCache:
+			if len(received) != 1 || received[0][0] != passThrough {
+				t.Fatal("expected message to be passed through")
+			}
+		default:
+			if len(received) != 1 || received[0][1] != distHeader {
+				t.Fatal("expected message with a distribution header")
+			}
+		}
+		var m *Message
+		for _, f := range received {
+			var err error
+			if m, err = connB.parseFrame(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var s string
+		if m == nil || m.Payload.Cast(&s) != nil || s != payload {
+			t.Fatal("unexpected message:", m)
+		}
+	}
+}
+
+// TestConnFragmentLimits is used to test the number and size of fragmented messages being received is limited, and
+// that fragments which can't be finished are dropped.
+func TestConnFragmentLimits(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	conn := newConn(&Node{Name: "a@localhost", MaxMessageSize: 90}, local, "b@localhost", DefaultFlags, 6)
+	defer conn.Close()
+	data := []byte(strings.Repeat("x", 60))
+	frames := func(SequenceID uint64) [][]byte {
+		f, err := Fragment(Header{SequenceID: SequenceID}, data, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	// Messages can be fragmented, as long as the fragments being buffered are within the maximum message size.
+	first, second := frames(1), frames(2)
+	for _, f := range [][]byte{first[0], first[1], second[0]} {
+		if m, err := conn.parseFrame(f); m != nil || err != nil {
+			t.Fatal("unexpected result:", m, err)
+		}
+	}
+	if conn.buffered != 60 {
+		t.Fatal("unexpected buffered bytes:", conn.buffered)
+	}
+	if _, err := conn.parseFrame(second[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.parseFrame(second[2]); err == nil {
+		t.Fatal("expected error for fragments over the maximum message size")
+	}
+	if _, ok := conn.fragments[2]; ok || conn.buffered != 40 {
+		t.Fatal("expected fragmented message to be dropped, buffered", conn.buffered)
+	}
+
+	// Fragments out of order should drop the message.
+	if _, err := conn.parseFrame(first[1]); err == nil {
+		t.Fatal("expected error for fragment out of order")
+	}
+	if len(conn.fragments) != 0 || conn.buffered != 0 {
+		t.Fatal("expected fragmented message to be dropped")
+	}
+
+	// Only so many messages can be fragmented at once.
+	for i := uint64(0); i < maxFragmentedMessages; i++ {
+		h, _ := AppendHeader(nil, &Header{Fragmented: true, SequenceID: i, FragmentID: 2})
+		if _, err := conn.parseFrame(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h, _ := AppendHeader(nil, &Header{Fragmented: true, SequenceID: maxFragmentedMessages, FragmentID: 2})
+	if _, err := conn.parseFrame(h); err == nil {
+		t.Fatal("expected error for too many fragmented messages")
+	}
+
+	// A fragment out of order should close the connection and drop the partial messages.
+	go func() {
+		_ = erlpack.NewFrameWriter(remote, 4).WriteFrame(frames(5)[1])
+	}()
+	if _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected error for fragment out of order")
+	}
+	if len(conn.fragments) != 0 || conn.buffered != 0 {
+		t.Fatal("expected fragmented messages to be dropped")
+	}
+	select {
+	case <-conn.Done():
+	default:
+		t.Fatal("expected connection to be closed")
+	}
+}
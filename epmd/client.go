@@ -0,0 +1,197 @@
+package epmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Defines the most data which is read from a response which ends when epmd closes the connection.
+const maxResponseSize = 1 << 20
+
+// Client is used to make requests to epmd. The zero value connects to epmd on localhost.
+type Client struct {
+	// Host is the host epmd is running on. If this is blank, localhost is used.
+	Host string
+
+	// Port is the port epmd listens on. If this is 0, ERL_EPMD_PORT or DefaultPort is used.
+	Port int
+
+	// Dialer is used to connect to epmd. If this is nil, a zero net.Dialer is used.
+	Dialer *net.Dialer
+}
+
+// Used to connect to epmd on a host, applying the deadline of the context to the connection.
+func (c *Client) dial(ctx context.Context, Host string) (net.Conn, error) {
+	if Host == "" {
+		Host = "localhost"
+	}
+	port := c.Port
+	if port == 0 {
+		port = defaultPort()
+	}
+	d := c.Dialer
+	if d == nil {
+		d = &net.Dialer{}
+	}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(Host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	return conn, nil
+}
+
+// Used to send a request to epmd on a host and read the response until epmd closes the connection.
+func (c *Client) request(ctx context.Context, Host string, Request []byte) ([]byte, error) {
+	conn, err := c.dial(ctx, Host)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err = erlpack.NewFrameWriter(conn, 2).WriteFrame(Request); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(conn, maxResponseSize))
+}
+
+// PortPlease is used to get the node registered with a name. The name is the part of the node name before the @. If
+// the node isn't registered, ErrNotFound is returned.
+func (c *Client) PortPlease(ctx context.Context, Name string) (*NodeInfo, error) {
+	return c.portPlease(ctx, c.Host, Name)
+}
+
+// Used to get a node from epmd on a host.
+func (c *Client) portPlease(ctx context.Context, Host, Name string) (*NodeInfo, error) {
+	b, err := c.request(ctx, Host, append([]byte{portPlease2Req}, Name...))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 2 || b[0] != port2Resp {
+		return nil, errors.New("epmd sent a invalid response")
+	}
+	if b[1] != 0 {
+		return nil, ErrNotFound
+	}
+	return parseNodeInfo(b[2:])
+}
+
+// Resolve is used to get the address a node listens on from its full name (name@host), asking epmd on the host of
+// the node.
+func (c *Client) Resolve(ctx context.Context, Node string) (string, *NodeInfo, error) {
+	i := strings.IndexByte(Node, '@')
+	if i < 1 || i == len(Node)-1 {
+		return "", nil, errors.New("node name must be name@host")
+	}
+	host := Node[i+1:]
+	n, err := c.portPlease(ctx, host, Node[:i])
+	if err != nil {
+		return "", nil, err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(n.Port))), n, nil
+}
+
+// Names is used to get the names and ports of the nodes registered with epmd. Only Name and Port are set.
+func (c *Client) Names(ctx context.Context) ([]NodeInfo, error) {
+	b, err := c.request(ctx, c.Host, []byte{namesReq})
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 4 {
+		return nil, errors.New("epmd sent a invalid response")
+	}
+
+	// The port of epmd is followed by a line for each node.
+	var nodes []NodeInfo
+	s := bufio.NewScanner(strings.NewReader(string(b[4:])))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 5 || fields[0] != "name" || fields[2] != "at" || fields[3] != "port" {
+			return nil, errors.New("epmd sent a invalid name: " + strconv.Quote(s.Text()))
+		}
+		port, err := strconv.ParseUint(fields[4], 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, NodeInfo{Name: fields[1], Port: uint16(port)})
+	}
+	return nodes, nil
+}
+
+// Registration is used to define a node registered with epmd. The node stays registered until Close is called.
+type Registration struct {
+	// Creation is the creation epmd gave the node. This should be used as dist.Node.Creation.
+	Creation uint32
+
+	conn      net.Conn
+	closeOnce sync.Once
+}
+
+// Register is used to register a node with epmd. The node stays registered until the registration is closed.
+func (c *Client) Register(ctx context.Context, Node NodeInfo) (*Registration, error) {
+	conn, err := c.dial(ctx, c.Host)
+	if err != nil {
+		return nil, err
+	}
+	r, err := register(conn, Node.withDefaults())
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Used to send ALIVE2_REQ and read the response.
+func register(conn net.Conn, Node NodeInfo) (*Registration, error) {
+	if err := erlpack.NewFrameWriter(conn, 2).WriteFrame(Node.append([]byte{alive2Req})); err != nil {
+		return nil, err
+	}
+	var b [6]byte
+	if _, err := io.ReadFull(conn, b[:2]); err != nil {
+		return nil, err
+	}
+	if b[0] != alive2XResp && b[0] != alive2Resp {
+		return nil, errors.New("epmd sent a invalid response")
+	}
+	if b[1] != 0 {
+		return nil, errors.New("epmd did not register the node (result " + strconv.Itoa(int(b[1])) + ")")
+	}
+
+	// ALIVE2_X_RESP has a 32 bit creation, and ALIVE2_RESP (from older versions of epmd) has a 16 bit one.
+	var creation uint32
+	if b[0] == alive2XResp {
+		if _, err := io.ReadFull(conn, b[2:]); err != nil {
+			return nil, err
+		}
+		creation = binary.BigEndian.Uint32(b[2:])
+	} else {
+		if _, err := io.ReadFull(conn, b[2:4]); err != nil {
+			return nil, err
+		}
+		creation = uint32(binary.BigEndian.Uint16(b[2:]))
+	}
+
+	// The connection is kept open for as long as the node is registered, so it shouldn't time out.
+	_ = conn.SetDeadline(time.Time{})
+	return &Registration{Creation: creation, conn: conn}, nil
+}
+
+// Close is used to unregister the node.
+func (r *Registration) Close() error {
+	err := errors.New("registration is already closed")
+	r.closeOnce.Do(func() {
+		err = r.conn.Close()
+	})
+	return err
+}
@@ -0,0 +1,139 @@
+// Package epmd is used to talk to the Erlang Port Mapper Daemon, which maps node names to the ports they listen on. The
+// Client registers nodes and looks them up, and the Server is a minimal epmd which can run in the same process, so
+// nodes can be found without an Erlang installation.
+package epmd
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"strconv"
+)
+
+// DefaultPort is the port epmd listens on if ERL_EPMD_PORT is not set.
+const DefaultPort = 4369
+
+// Defines the tags of the requests and responses.
+const (
+	alive2Req      = 'x'
+	alive2Resp     = 'y'
+	alive2XResp    = 'v'
+	portPlease2Req = 'z'
+	port2Resp      = 'w'
+	namesReq       = 'n'
+)
+
+// NodeType is used to define if a node is hidden or not.
+type NodeType uint8
+
+const (
+	// NodeTypeNormal is used for nodes which are part of the global namespace.
+	NodeTypeNormal NodeType = 77
+
+	// NodeTypeHidden is used for hidden nodes.
+	NodeTypeHidden NodeType = 72
+)
+
+// NodeInfo is used to define a node registered with epmd.
+type NodeInfo struct {
+	// Name is the name of the node, without the host.
+	Name string
+
+	// Port is the port the node listens for distribution connections on.
+	Port uint16
+
+	// Type is the type of the node. If this is 0 when registering, NodeTypeHidden is used.
+	Type NodeType
+
+	// Protocol is the protocol the node uses. 0 is TCP/IPv4.
+	Protocol uint8
+
+	// HighestVersion is the highest version of the distribution protocol the node supports. If this is 0 when
+	// registering, 6 is used, which is the version OTP 23 and later use.
+	HighestVersion uint16
+
+	// LowestVersion is the lowest version of the distribution protocol the node supports. If this is 0 when
+	// registering, 6 is used.
+	LowestVersion uint16
+
+	// Extra is extra data sent by the node, which is normally empty.
+	Extra []byte
+}
+
+// ErrNotFound is returned when a node is not registered with epmd.
+var ErrNotFound = errors.New("node is not registered with epmd")
+
+// Used to get the port epmd is on, from ERL_EPMD_PORT if it is set.
+func defaultPort() int {
+	if p, err := strconv.Atoi(os.Getenv("ERL_EPMD_PORT")); err == nil && p > 0 {
+		return p
+	}
+	return DefaultPort
+}
+
+// Used to append a big-endian uint16.
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// Used to append a big-endian uint32.
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// Used to fill in the defaults of a node being registered.
+func (n NodeInfo) withDefaults() NodeInfo {
+	if n.Type == 0 {
+		n.Type = NodeTypeHidden
+	}
+	if n.HighestVersion == 0 {
+		n.HighestVersion = 6
+	}
+	if n.LowestVersion == 0 {
+		n.LowestVersion = 6
+	}
+	return n
+}
+
+// Used to append the node info in the format used by ALIVE2_REQ and PORT2_RESP.
+func (n NodeInfo) append(b []byte) []byte {
+	b = appendUint16(b, n.Port)
+	b = append(b, byte(n.Type), n.Protocol)
+	b = appendUint16(b, n.HighestVersion)
+	b = appendUint16(b, n.LowestVersion)
+	b = appendUint16(b, uint16(len(n.Name)))
+	b = append(b, n.Name...)
+	b = appendUint16(b, uint16(len(n.Extra)))
+	return append(b, n.Extra...)
+}
+
+// Used to parse the node info in the format used by ALIVE2_REQ and PORT2_RESP.
+func parseNodeInfo(b []byte) (*NodeInfo, error) {
+	tooShort := errors.New("node info is too short")
+	if len(b) < 10 {
+		return nil, tooShort
+	}
+	n := &NodeInfo{
+		Port:           binary.BigEndian.Uint16(b),
+		Type:           NodeType(b[2]),
+		Protocol:       b[3],
+		HighestVersion: binary.BigEndian.Uint16(b[4:]),
+		LowestVersion:  binary.BigEndian.Uint16(b[6:]),
+	}
+	nameLen := int(binary.BigEndian.Uint16(b[8:]))
+	b = b[10:]
+	if len(b) < nameLen+2 {
+		return nil, tooShort
+	}
+	n.Name = string(b[:nameLen])
+	b = b[nameLen:]
+	extraLen := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	if len(b) != extraLen {
+		return nil, errors.New("node info has the wrong length")
+	}
+	if extraLen != 0 {
+		n.Extra = append([]byte(nil), b...)
+	}
+	return n, nil
+}
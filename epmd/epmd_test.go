@@ -0,0 +1,130 @@
+package epmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// Used to start a server on a random port and get a client for it.
+func startServer(t *testing.T) (*Server, *Client) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer()
+	go func() { _ = s.Serve(l) }()
+	t.Cleanup(func() { _ = s.Close() })
+	return s, &Client{Host: "127.0.0.1", Port: l.Addr().(*net.TCPAddr).Port}
+}
+
+// TestRegister is used to test registering and looking up nodes.
+func TestRegister(t *testing.T) {
+	_, c := startServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r, err := c.Register(ctx, NodeInfo{Name: "a", Port: 1234, Extra: []byte("extra")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Creation < 4 {
+		t.Fatal("unexpected creation:", r.Creation)
+	}
+
+	// The node should be found with the defaults filled in.
+	n, err := c.PortPlease(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Name != "a" || n.Port != 1234 || n.Type != NodeTypeHidden || n.HighestVersion != 6 || n.LowestVersion != 6 ||
+		string(n.Extra) != "extra" {
+		t.Fatal("unexpected node:", n)
+	}
+	addr, n, err := c.Resolve(ctx, "a@127.0.0.1")
+	if err != nil || addr != "127.0.0.1:1234" || n.Port != 1234 {
+		t.Fatal("unexpected address:", addr, err)
+	}
+
+	// The same name can't be registered twice.
+	if _, err = c.Register(ctx, NodeInfo{Name: "a", Port: 1235}); err == nil {
+		t.Fatal("expected error for duplicate name")
+	}
+	r2, err := c.Register(ctx, NodeInfo{Name: "b", Port: 1235, Type: NodeTypeNormal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Close()
+	if r2.Creation == r.Creation {
+		t.Fatal("creations should be different")
+	}
+	names, err := c.Names(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Fatal("unexpected names:", names)
+	}
+	for _, n := range names {
+		if (n.Name != "a" || n.Port != 1234) && (n.Name != "b" || n.Port != 1235) {
+			t.Fatal("unexpected name:", n)
+		}
+	}
+
+	// Closing the registration should unregister the node.
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err = c.PortPlease(ctx, "a"); errors.Is(err, ErrNotFound) {
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatal("node was not unregistered:", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestServerClose is used to test closing the server unregisters the nodes and stops it.
+func TestServerClose(t *testing.T) {
+	s, c := startServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r, err := c.Register(ctx, NodeInfo{Name: "a", Port: 1234})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.PortPlease(ctx, "a"); err == nil {
+		t.Fatal("expected error after the server is closed")
+	}
+	if _, err = r.conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("registration should be closed by the server")
+	}
+}
+
+// TestParseNodeInfo is used to test node info is encoded and decoded the same.
+func TestParseNodeInfo(t *testing.T) {
+	n := NodeInfo{Name: "node", Port: 4370, Type: NodeTypeNormal, Protocol: 0, HighestVersion: 6, LowestVersion: 5}
+	b := n.append(nil)
+	parsed, err := parseNodeInfo(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Name != n.Name || parsed.Port != n.Port || parsed.Type != n.Type || parsed.LowestVersion != 5 ||
+		parsed.Extra != nil {
+		t.Fatal("unexpected node:", parsed)
+	}
+	for i := 0; i < len(b); i++ {
+		if _, err = parseNodeInfo(b[:i]); err == nil {
+			t.Fatal("expected error for truncated node info of length", i)
+		}
+	}
+}
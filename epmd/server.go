@@ -0,0 +1,203 @@
+package epmd
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Defines how long a connection has to send its request.
+const requestTimeout = 10 * time.Second
+
+// Server is used to define a minimal epmd which runs in the same process. It handles ALIVE2_REQ, PORT_PLEASE2_REQ
+// and NAMES_REQ, which is all a node needs. The zero value is not usable; use NewServer.
+type Server struct {
+	mu        sync.Mutex
+	nodes     map[string]NodeInfo
+	creation  uint32
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+// NewServer is used to create a epmd server with no nodes registered.
+func NewServer() *Server {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return &Server{
+		nodes:     map[string]NodeInfo{},
+		creation:  binary.BigEndian.Uint32(b[:]),
+		listeners: map[net.Listener]struct{}{},
+		conns:     map[net.Conn]struct{}{},
+	}
+}
+
+// ErrServerClosed is returned by Serve after the server is closed.
+var ErrServerClosed = errors.New("epmd server is closed")
+
+// Serve is used to accept connections from the listener until it or the server is closed.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+
+	// NAMES_REQ responds with the port epmd is listening on.
+	var port uint32
+	if addr, ok := l.Addr().(*net.TCPAddr); ok {
+		port = uint32(addr.Port)
+	}
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if !s.track(c) {
+			_ = c.Close()
+			return ErrServerClosed
+		}
+		go s.handle(c, port)
+	}
+}
+
+// Used to add a connection so it is closed with the server. Returns false if the server is closed.
+func (s *Server) track(c net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[c] = struct{}{}
+	return true
+}
+
+// Used to handle a connection.
+func (s *Server) handle(c net.Conn, Port uint32) {
+	defer func() {
+		_ = c.Close()
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+	}()
+	_ = c.SetDeadline(time.Now().Add(requestTimeout))
+	req, err := erlpack.NewFrameReader(c, 2).Next()
+	if err != nil || len(req) == 0 {
+		return
+	}
+	switch req[0] {
+	case alive2Req:
+		s.alive(c, req[1:])
+	case portPlease2Req:
+		_, _ = c.Write(s.portPlease(string(req[1:])))
+	case namesReq:
+		_, _ = c.Write(s.names(Port))
+	}
+}
+
+// Used to register a node for as long as the connection is open.
+func (s *Server) alive(c net.Conn, Req []byte) {
+	n, err := parseNodeInfo(Req)
+	tag := byte(alive2XResp)
+	if err == nil && n.HighestVersion < 6 {
+		tag = alive2Resp
+	}
+	if err != nil || n.Name == "" {
+		_, _ = c.Write([]byte{tag, 1})
+		return
+	}
+
+	// Register the node if the name isn't being used.
+	s.mu.Lock()
+	if _, ok := s.nodes[n.Name]; ok {
+		s.mu.Unlock()
+		_, _ = c.Write([]byte{tag, 1})
+		return
+	}
+	s.nodes[n.Name] = *n
+	s.creation++
+	if s.creation < 4 {
+		// 0 is not a valid creation, and 1 to 3 are used by nodes older than OTP 23.
+		s.creation = 4
+	}
+	creation := s.creation
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.nodes, n.Name)
+		s.mu.Unlock()
+	}()
+
+	var resp []byte
+	if tag == alive2XResp {
+		resp = appendUint32([]byte{tag, 0}, creation)
+	} else {
+		resp = appendUint16([]byte{tag, 0}, uint16(creation%3+1))
+	}
+	if _, err = c.Write(resp); err != nil {
+		return
+	}
+
+	// The node is unregistered when the connection is closed.
+	_ = c.SetDeadline(time.Time{})
+	_, _ = io.Copy(io.Discard, c)
+}
+
+// Used to get the response to PORT_PLEASE2_REQ.
+func (s *Server) portPlease(Name string) []byte {
+	s.mu.Lock()
+	n, ok := s.nodes[Name]
+	s.mu.Unlock()
+	if !ok {
+		return []byte{port2Resp, 1}
+	}
+	return n.append([]byte{port2Resp, 0})
+}
+
+// Used to get the response to NAMES_REQ.
+func (s *Server) names(Port uint32) []byte {
+	b := appendUint32(nil, Port)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, n := range s.nodes {
+		b = append(b, "name "+n.Name+" at port "+strconv.Itoa(int(n.Port))+"\n"...)
+	}
+	return b
+}
+
+// Close is used to close the listeners and connections of the server, which unregisters all of the nodes.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrServerClosed
+	}
+	s.closed = true
+	for l := range s.listeners {
+		_ = l.Close()
+	}
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package erlpack
+
+import (
+	"fmt"
+	"math"
+)
+
+// NonFiniteFloatError is returned when packing a float which Erlang can not represent (NaN or an infinity).
+type NonFiniteFloatError struct {
+	Value float64
+}
+
+// Error is used to get the error message.
+func (e *NonFiniteFloatError) Error() string {
+	return fmt.Sprintf("cannot pack non-finite float %v", e.Value)
+}
+
+// Used to get the atom for a non-finite float.
+func nonFiniteFloatAtom(Data float64) Atom {
+	if math.IsNaN(Data) {
+		return "nan"
+	} else if Data > 0 {
+		return "infinity"
+	}
+	return "neg_infinity"
+}
+
+// Used to get the non-finite float for a atom.
+func nonFiniteFloat(Data Atom) (float64, bool) {
+	switch Data {
+	case "nan":
+		return math.NaN(), true
+	case "infinity":
+		return math.Inf(1), true
+	case "neg_infinity":
+		return math.Inf(-1), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,62 @@
+package erlpack
+
+import (
+	"math"
+	"testing"
+)
+
+// TestPackNonFiniteFloat is used to test that non-finite floats return a error by default.
+func TestPackNonFiniteFloat(t *testing.T) {
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		_, err := Pack([]interface{}{1, f})
+		if _, ok := err.(*NonFiniteFloatError); !ok {
+			t.Fatal("expected *NonFiniteFloatError, got", err)
+		}
+	}
+	_, err := Pack(float32(math.Inf(1)))
+	if _, ok := err.(*NonFiniteFloatError); !ok {
+		t.Fatal("expected *NonFiniteFloatError, got", err)
+	}
+}
+
+// TestNonFiniteFloatAtoms is used to test packing non-finite floats as atoms and unpacking them again.
+func TestNonFiniteFloatAtoms(t *testing.T) {
+	b, err := PackWithOptions([]float64{math.NaN(), math.Inf(1), math.Inf(-1), 1.5}, EncoderOptions{NonFiniteFloatsAsAtoms: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = assertBytes([]byte("\x83l\x00\x00\x00\x04w\x03nanw\x08infinityw\x0cneg_infinityF\x3f\xf8\x00\x00\x00\x00\x00\x00j"), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the option, the atoms should not be turned into floats.
+	var a []float64
+	if err = Unpack(b, &a); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// With the option, they should be.
+	err = UnpackWithOptions(b, &a, DecoderOptions{AtomsAsNonFiniteFloats: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 4 || !math.IsNaN(a[0]) || !math.IsInf(a[1], 1) || !math.IsInf(a[2], -1) || a[3] != 1.5 {
+		t.Fatal("unexpected result:", a)
+	}
+
+	// The option should also be used when casting a uncasted result.
+	var u UncastedResult
+	err = UnpackWithOptions(b, &u, DecoderOptions{AtomsAsNonFiniteFloats: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f []float32
+	err = u.Cast(&f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f) != 4 || !math.IsInf(float64(f[2]), -1) {
+		t.Fatal("unexpected result:", f)
+	}
+}
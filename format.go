@@ -0,0 +1,632 @@
+package erlpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Syntax is used to define the syntax terms are formatted with.
+type Syntax int
+
+const (
+	// ErlangSyntax formats terms the way Erlang prints them ({ok,<<"abc">>,#{a => 1}}).
+	ErlangSyntax Syntax = iota
+
+	// ElixirSyntax formats terms the way Elixir inspects them ({:ok, "abc", %{a: 1}}).
+	ElixirSyntax
+)
+
+// FormatOptions is used to define the options for formatting a term.
+type FormatOptions struct {
+	// Syntax is the syntax the term is formatted with.
+	Syntax Syntax
+
+	// Indent is written once for each level of nesting when it is set. Each item within a list, tuple or map is then
+	// written on its own line.
+	Indent string
+
+	// MaxWidth is the most characters a line can have when it is set. Longer lines are cut and end with "...".
+	MaxWidth int
+}
+
+// Format is used to format a term with Erlang or Elixir syntax. This is mainly meant for logging unpacked terms.
+// Values which were not unpacked (such as structs) are formatted as the term they would be packed as.
+func Format(v interface{}, opts FormatOptions) string {
+	f := &termFormatter{opts: opts}
+	if err := f.term(v); err != nil {
+		return fmt.Sprintf("%%!(erlpack: %s)", err.Error())
+	}
+	s := f.buf.String()
+	if opts.MaxWidth > 0 {
+		s = truncateLines(s, opts.MaxWidth)
+	}
+	return s
+}
+
+// Used to cut each line which is longer than the width specified.
+func truncateLines(s string, width int) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if utf8.RuneCountInString(line) <= width {
+			continue
+		}
+		keep := width - 3
+		if keep < 0 {
+			keep = 0
+		}
+		runes := []rune(line)
+		lines[i] = string(runes[:keep]) + "..."
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Used to format a term.
+type termFormatter struct {
+	buf   bytes.Buffer
+	opts  FormatOptions
+	depth int
+}
+
+// Used to check if the syntax is Elixir.
+func (f *termFormatter) elixir() bool {
+	return f.opts.Syntax == ElixirSyntax
+}
+
+// Used to write a term.
+func (f *termFormatter) term(v interface{}) error {
+	switch x := v.(type) {
+	case nil:
+		f.buf.WriteString("nil")
+	case bool:
+		f.buf.WriteString(strconv.FormatBool(x))
+	case Atom:
+		f.atom(x)
+	case string:
+		// Strings are packed and unpacked as map keys as binaries.
+		f.binary([]byte(x))
+	case []byte:
+		f.binary(x)
+	case uint8:
+		f.buf.WriteString(strconv.FormatUint(uint64(x), 10))
+	case int32:
+		f.buf.WriteString(strconv.FormatInt(int64(x), 10))
+	case int64:
+		f.buf.WriteString(strconv.FormatInt(x, 10))
+	case uint64:
+		f.buf.WriteString(strconv.FormatUint(x, 10))
+	case int:
+		f.buf.WriteString(strconv.Itoa(x))
+	case *big.Int:
+		f.buf.WriteString(x.String())
+	case float64:
+		f.float(x)
+	case float32:
+		f.float(float64(x))
+	case []interface{}:
+		return f.items("[", "]", x)
+	case Tuple:
+		return f.items("{", "}", x)
+	case ImproperList:
+		return f.improperList(x)
+	case OrderedMap:
+		return f.mapItems(x)
+	case map[interface{}]interface{}:
+		return f.goMap(x)
+	case MapKey:
+		t, err := x.Term()
+		if err != nil {
+			return err
+		}
+		return f.term(t)
+	case Export:
+		f.export(x)
+	case Fun:
+		f.fun(x)
+	case Pid, Port, Reference:
+		f.identifier(x)
+	case RawData:
+		return f.raw(x)
+	case rawDataGetter:
+		if v := reflect.ValueOf(x); v.Kind() == reflect.Ptr && v.IsNil() {
+			return f.term(nil)
+		}
+		return f.raw(x.rawData())
+	case UncastedResult:
+		return f.term(x.item)
+	case *UncastedResult:
+		return f.term(x.item)
+	default:
+		// Format the term this would be packed as.
+		b, err := Pack(v)
+		if err != nil {
+			return err
+		}
+		return f.raw(b[1:])
+	}
+	return nil
+}
+
+// Used to write raw data.
+func (f *termFormatter) raw(r RawData) error {
+	if len(r) == 0 {
+		return nil
+	}
+	var x interface{}
+	if err := r.Cast(&x); err != nil {
+		return err
+	}
+	return f.term(x)
+}
+
+// Used to write a float. Floats always have a decimal point, and non-finite floats are written as atoms.
+func (f *termFormatter) float(x float64) {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		f.atom(nonFiniteFloatAtom(x))
+		return
+	}
+	mantissa, exponent, hasExponent := strings.Cut(strconv.FormatFloat(x, 'g', -1, 64), "e")
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	f.buf.WriteString(mantissa)
+	if hasExponent {
+		negative := strings.HasPrefix(exponent, "-")
+		exponent = strings.TrimLeft(exponent, "+-0")
+		f.buf.WriteByte('e')
+		if negative {
+			f.buf.WriteByte('-')
+		}
+		f.buf.WriteString(exponent)
+	}
+}
+
+// Defines the words which must be quoted when used as a atom in Erlang.
+var erlangReservedWords = map[string]bool{
+	"after": true, "and": true, "andalso": true, "band": true, "begin": true, "bnot": true, "bor": true, "bsl": true,
+	"bsr": true, "bxor": true, "case": true, "catch": true, "cond": true, "div": true, "else": true, "end": true,
+	"fun": true, "if": true, "let": true, "maybe": true, "not": true, "of": true, "or": true, "orelse": true,
+	"receive": true, "rem": true, "try": true, "when": true, "xor": true,
+}
+
+// Used to check if a atom can be written without quotes in Erlang.
+func erlangBareAtom(a string) bool {
+	if a == "" || a[0] < 'a' || a[0] > 'z' || erlangReservedWords[a] {
+		return false
+	}
+	for _, c := range []byte(a) {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '@') {
+			return false
+		}
+	}
+	return true
+}
+
+// Used to check if the string is a identifier in Elixir (such as a atom which can be written as :name or name:).
+func elixirIdentifier(a string) bool {
+	if a == "" {
+		return false
+	}
+	for i, c := range a {
+		switch {
+		case c == '_' || unicode.IsLetter(c):
+		case i != 0 && (unicode.IsDigit(c) || c == '@'):
+		case i != 0 && i == len(a)-1 && (c == '?' || c == '!'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Used to check if the atom is a Elixir module alias (such as Elixir.Enum), which is written without the prefix.
+func elixirAlias(a string) (string, bool) {
+	if !strings.HasPrefix(a, "Elixir.") {
+		return "", false
+	}
+	alias := strings.TrimPrefix(a, "Elixir.")
+	for _, part := range strings.Split(alias, ".") {
+		if part == "" || part[0] < 'A' || part[0] > 'Z' || !elixirIdentifier(part) {
+			return "", false
+		}
+	}
+	return alias, true
+}
+
+// Used to write a atom.
+func (f *termFormatter) atom(a Atom) {
+	s := string(a)
+	if !f.elixir() {
+		if erlangBareAtom(s) {
+			f.buf.WriteString(s)
+		} else {
+			f.quoted(s, '\'')
+		}
+		return
+	}
+	switch s {
+	case "nil", "true", "false":
+		f.buf.WriteString(s)
+		return
+	}
+	if alias, ok := elixirAlias(s); ok {
+		f.buf.WriteString(alias)
+		return
+	}
+	f.buf.WriteByte(':')
+	if elixirIdentifier(s) {
+		f.buf.WriteString(s)
+	} else {
+		f.quoted(s, '"')
+	}
+}
+
+// Used to write a quoted string with the characters escaped.
+func (f *termFormatter) quoted(s string, quote byte) {
+	f.buf.WriteByte(quote)
+	for i, c := range s {
+		switch c {
+		case '\\':
+			f.buf.WriteString(`\\`)
+		case '\n':
+			f.buf.WriteString(`\n`)
+		case '\r':
+			f.buf.WriteString(`\r`)
+		case '\t':
+			f.buf.WriteString(`\t`)
+		case rune(quote):
+			f.buf.WriteByte('\\')
+			f.buf.WriteByte(quote)
+		case '#':
+			// Stop Elixir from treating this as interpolation.
+			if f.elixir() && quote == '"' && i+1 < len(s) && s[i+1] == '{' {
+				f.buf.WriteByte('\\')
+			}
+			f.buf.WriteByte('#')
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&f.buf, `\x{%X}`, c)
+			} else {
+				f.buf.WriteRune(c)
+			}
+		}
+	}
+	f.buf.WriteByte(quote)
+}
+
+// Used to check if a binary is printable text, and if it is only ASCII.
+func printable(b []byte) (ok bool, ascii bool) {
+	if !utf8.Valid(b) {
+		return false, false
+	}
+	ascii = true
+	for _, c := range string(b) {
+		if c >= utf8.RuneSelf {
+			ascii = false
+		}
+		if !unicode.IsPrint(c) && c != '\n' && c != '\r' && c != '\t' {
+			return false, false
+		}
+	}
+	return true, ascii
+}
+
+// Used to write a binary.
+func (f *termFormatter) binary(b []byte) {
+	ok, ascii := printable(b)
+	if f.elixir() {
+		if ok {
+			f.quoted(string(b), '"')
+			return
+		}
+		f.buf.WriteString("<<")
+		for i, c := range b {
+			if i != 0 {
+				f.buf.WriteString(", ")
+			}
+			f.buf.WriteString(strconv.Itoa(int(c)))
+		}
+		f.buf.WriteString(">>")
+		return
+	}
+
+	f.buf.WriteString("<<")
+	if ok && len(b) != 0 {
+		f.quoted(string(b), '"')
+		if !ascii {
+			f.buf.WriteString("/utf8")
+		}
+	} else {
+		for i, c := range b {
+			if i != 0 {
+				f.buf.WriteByte(',')
+			}
+			f.buf.WriteString(strconv.Itoa(int(c)))
+		}
+	}
+	f.buf.WriteString(">>")
+}
+
+// Used to write a external function.
+func (f *termFormatter) export(e Export) {
+	if f.elixir() {
+		f.buf.WriteByte('&')
+		f.atom(e.Module)
+		f.buf.WriteByte('.')
+		if elixirIdentifier(string(e.Function)) {
+			f.buf.WriteString(string(e.Function))
+		} else {
+			f.quoted(string(e.Function), '"')
+		}
+	} else {
+		f.buf.WriteString("fun ")
+		f.atom(e.Module)
+		f.buf.WriteByte(':')
+		f.atom(e.Function)
+	}
+	f.buf.WriteString("/" + strconv.Itoa(int(e.Arity)))
+}
+
+// Used to write a closure. Like Erlang, this is only a description of the fun.
+func (f *termFormatter) fun(x Fun) {
+	// Get the module, index and unique number of the fun.
+	var module Atom
+	var uniq interface{}
+	index := uint32(0)
+	if raw := x.Bytes(); len(raw) > 30 {
+		index = binary.BigEndian.Uint32(raw[22:26])
+		r := bytes.NewReader(raw[30:])
+		// Atom cache references are replaced when the fun is unpacked, so none are left in the bytes.
+		module, _ = readAtom(r, nil)
+		for i := 0; i < 2; i++ {
+			if DataType, err := r.ReadByte(); err == nil {
+				uniq, _ = processScalar(DataType, r)
+			}
+		}
+	}
+	if uniq == nil {
+		uniq = 0
+	}
+
+	if f.elixir() {
+		fmt.Fprintf(&f.buf, "#Function<%d.%v/%d in ", index, uniq, x.Arity())
+		f.atom(module)
+		f.buf.WriteByte('>')
+		return
+	}
+	f.buf.WriteString("#Fun<")
+	f.atom(module)
+	fmt.Fprintf(&f.buf, ".%d.%v>", index, uniq)
+}
+
+// Used to write a pid, port or reference. Like Erlang, the node is not included.
+func (f *termFormatter) identifier(x interface{}) {
+	switch v := x.(type) {
+	case Pid:
+		if f.elixir() {
+			f.buf.WriteString("#PID")
+		}
+		fmt.Fprintf(&f.buf, "<0.%d.%d>", v.ID, v.Serial)
+	case Port:
+		fmt.Fprintf(&f.buf, "#Port<0.%d>", v.ID)
+	case Reference:
+		if f.elixir() {
+			f.buf.WriteString("#Reference<0")
+		} else {
+			f.buf.WriteString("#Ref<0")
+		}
+		for i := len(v.ID) - 1; i >= 0; i-- {
+			fmt.Fprintf(&f.buf, ".%d", v.ID[i])
+		}
+		f.buf.WriteByte('>')
+	}
+}
+
+// Used to write the separator before a item within a container.
+func (f *termFormatter) separator(first bool) {
+	if !first {
+		f.buf.WriteByte(',')
+		if f.elixir() && f.opts.Indent == "" {
+			f.buf.WriteByte(' ')
+		}
+	}
+	if f.opts.Indent != "" {
+		f.newline(f.depth)
+	}
+}
+
+// Used to start a new line at the depth specified.
+func (f *termFormatter) newline(depth int) {
+	f.buf.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		f.buf.WriteString(f.opts.Indent)
+	}
+}
+
+// Used to write a list or tuple.
+func (f *termFormatter) items(open, close string, items []interface{}) error {
+	f.buf.WriteString(open)
+	f.depth++
+	for i, v := range items {
+		f.separator(i == 0)
+		if err := f.term(v); err != nil {
+			return err
+		}
+	}
+	f.depth--
+	if f.opts.Indent != "" && len(items) != 0 {
+		f.newline(f.depth)
+	}
+	f.buf.WriteString(close)
+	return nil
+}
+
+// Used to write a improper list.
+func (f *termFormatter) improperList(l ImproperList) error {
+	f.buf.WriteByte('[')
+	f.depth++
+	for i, v := range l.Items {
+		f.separator(i == 0)
+		if err := f.term(v); err != nil {
+			return err
+		}
+	}
+	if f.opts.Indent != "" {
+		f.newline(f.depth)
+		f.buf.WriteString("| ")
+	} else if f.elixir() {
+		f.buf.WriteString(" | ")
+	} else {
+		f.buf.WriteByte('|')
+	}
+	if err := f.term(l.Tail); err != nil {
+		return err
+	}
+	f.depth--
+	if f.opts.Indent != "" {
+		f.newline(f.depth)
+	}
+	f.buf.WriteByte(']')
+	return nil
+}
+
+// Used to write a Go map in term order.
+func (f *termFormatter) goMap(m map[interface{}]interface{}) error {
+	items := make(OrderedMap, 0, len(m))
+	encoded := make([][]byte, 0, len(m))
+	for k, v := range m {
+		key, err := canonicalKey(k)
+		if err != nil {
+			return err
+		}
+		items = append(items, MapItem{Key: k, Value: v})
+		encoded = append(encoded, []byte(key))
+	}
+	order, err := sortEncodedKeys(encoded)
+	if err != nil {
+		return err
+	}
+	sorted := make(OrderedMap, len(items))
+	for i, index := range order {
+		sorted[i] = items[index]
+	}
+	return f.mapItems(sorted)
+}
+
+// Used to get the text of a atom key if the map can be written with Elixir's keyword syntax.
+func elixirKeywordKey(k interface{}) (string, bool) {
+	switch x := k.(type) {
+	case Atom:
+		return string(x), elixirIdentifier(string(x))
+	case bool, nil:
+		return atomText(x), true
+	default:
+		return "", false
+	}
+}
+
+// Used to write the items of a map.
+func (f *termFormatter) mapItems(o OrderedMap) error {
+	// Check if the keys can be written with Elixir's keyword syntax (%{a: 1}).
+	keyword := f.elixir()
+	for _, item := range o {
+		if _, ok := elixirKeywordKey(item.Key); !ok {
+			keyword = false
+		}
+	}
+
+	if f.elixir() {
+		f.buf.WriteString("%{")
+	} else {
+		f.buf.WriteString("#{")
+	}
+	f.depth++
+	for i, item := range o {
+		f.separator(i == 0)
+		if keyword {
+			key, _ := elixirKeywordKey(item.Key)
+			f.buf.WriteString(key + ": ")
+		} else {
+			if err := f.term(item.Key); err != nil {
+				return err
+			}
+			f.buf.WriteString(" => ")
+		}
+		if err := f.term(item.Value); err != nil {
+			return err
+		}
+	}
+	f.depth--
+	if f.opts.Indent != "" && len(o) != 0 {
+		f.newline(f.depth)
+	}
+	f.buf.WriteByte('}')
+	return nil
+}
+
+// Used to format a term for the fmt package. %v uses Erlang syntax and %+v uses Elixir syntax. The width is used to
+// pad the result like a string.
+func formatVerb(s fmt.State, v interface{}) {
+	opts := FormatOptions{}
+	if s.Flag('+') {
+		opts.Syntax = ElixirSyntax
+	}
+	fmt.Fprintf(s, directive(s, 's'), Format(v, opts))
+}
+
+// Used to get the directive for the verb with the flags, width and precision from the state.
+func directive(s fmt.State, verb rune) string {
+	d := "%"
+	for _, flag := range "+-# 0" {
+		if s.Flag(int(flag)) {
+			d += string(flag)
+		}
+	}
+	if w, ok := s.Width(); ok {
+		d += strconv.Itoa(w)
+	}
+	if p, ok := s.Precision(); ok {
+		d += "." + strconv.Itoa(p)
+	}
+	return d + string(verb)
+}
+
+// Format is used to implement fmt.Formatter. %v formats the atom with Erlang syntax and %+v formats it with Elixir
+// syntax. Other verbs format the text of the atom like a string.
+func (a Atom) Format(s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('#'):
+		fmt.Fprintf(s, "erlpack.Atom(%q)", string(a))
+	case verb == 'v':
+		formatVerb(s, a)
+	default:
+		fmt.Fprintf(s, directive(s, verb), string(a))
+	}
+}
+
+// Format is used to implement fmt.Formatter. %v formats the term with Erlang syntax and %+v formats it with Elixir
+// syntax. Other verbs format the bytes like a []byte.
+func (r RawData) Format(s fmt.State, verb rune) {
+	if verb == 'v' && !s.Flag('#') {
+		formatVerb(s, r)
+		return
+	}
+	fmt.Fprintf(s, directive(s, verb), []byte(r))
+}
+
+// Format is used to implement fmt.Formatter. %v formats the result with Erlang syntax and %+v formats it with Elixir
+// syntax.
+func (u UncastedResult) Format(s fmt.State, verb rune) {
+	if verb != 'v' {
+		fmt.Fprintf(s, "%%!%c(erlpack.UncastedResult=%v)", verb, u)
+		return
+	}
+	formatVerb(s, u)
+}
@@ -0,0 +1,108 @@
+package erlpack
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// TestFormat is used to test formatting terms with Erlang and Elixir syntax.
+func TestFormat(t *testing.T) {
+	var fun Fun
+	if err := Unpack([]byte("\x83"+testFun), &fun); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		term   interface{}
+		erlang string
+		elixir string
+	}{
+		{"atom", Atom("ok"), "ok", ":ok"},
+		{"quoted atom", Atom("Hello world"), "'Hello world'", `:"Hello world"`},
+		{"reserved atom", Atom("receive"), "'receive'", ":receive"},
+		{"alias", Atom("Elixir.Enum"), "'Elixir.Enum'", "Enum"},
+		{"nil", nil, "nil", "nil"},
+		{"bool", true, "true", "true"},
+		{"binary", []byte("abc"), `<<"abc">>`, `"abc"`},
+		{"escaped binary", []byte("a\"b\n#{c}"), `<<"a\"b\n#{c}">>`, `"a\"b\n\#{c}"`},
+		{"utf8 binary", []byte("héllo"), `<<"héllo"/utf8>>`, `"héllo"`},
+		{"bytes", []byte{1, 2, 255}, "<<1,2,255>>", "<<1, 2, 255>>"},
+		{"empty binary", []byte{}, "<<>>", `""`},
+		{"integers", []interface{}{uint8(1), int32(-2), int64(1) << 40, uint64(math.MaxUint64)},
+			"[1,-2,1099511627776,18446744073709551615]", "[1, -2, 1099511627776, 18446744073709551615]"},
+		{"floats", []interface{}{1.0, 0.5, 1e21, 1.5e-7, math.Inf(1)},
+			"[1.0,0.5,1.0e21,1.5e-7,infinity]", "[1.0, 0.5, 1.0e21, 1.5e-7, :infinity]"},
+		{"tuple", Tuple{Atom("ok"), []byte("abc"), []interface{}{1, 2}},
+			`{ok,<<"abc">>,[1,2]}`, `{:ok, "abc", [1, 2]}`},
+		{"map", map[interface{}]interface{}{"a": 1, Atom("b"): Tuple{}, 2: nil},
+			`#{2 => nil,b => {},<<"a">> => 1}`, `%{2 => nil, :b => {}, "a" => 1}`},
+		{"keyword map", OrderedMap{{Key: Atom("b"), Value: 1}, {Key: Atom("a"), Value: 2}},
+			"#{b => 1,a => 2}", "%{b: 1, a: 2}"},
+		{"export", Export{Module: "lists", Function: "map", Arity: 2}, "fun lists:map/2", "&:lists.map/2"},
+		{"fun", fun, "#Fun<erl_eval.0.100000000>", "#Function<0.100000000/1 in :erl_eval>"},
+		{"raw data", RawData("h\x02s\x02oka\x01"), "{ok,1}", "{:ok, 1}"},
+		{"struct", struct {
+			A int `erlpack:"a"`
+		}{A: 1}, `#{<<"a">> => 1}`, `%{"a" => 1}`},
+	}
+	for _, tt := range tests {
+		if s := Format(tt.term, FormatOptions{}); s != tt.erlang {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.erlang, s)
+		}
+		if s := Format(tt.term, FormatOptions{Syntax: ElixirSyntax}); s != tt.elixir {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.elixir, s)
+		}
+	}
+}
+
+// TestFormatIndent is used to test formatting terms over multiple lines.
+func TestFormatIndent(t *testing.T) {
+	term := Tuple{Atom("ok"), []interface{}{}, OrderedMap{{Key: Atom("a"), Value: []interface{}{1, 2}}}}
+	expected := "{\n  ok,\n  [],\n  #{\n    a => [\n      1,\n      2\n    ]\n  }\n}"
+	if s := Format(term, FormatOptions{Indent: "  "}); s != expected {
+		t.Fatalf("expected %q, got %q", expected, s)
+	}
+	expected = "{\n  :ok,\n  [],\n  %{\n    a: [\n      1,\n      2\n    ]\n  }\n}"
+	if s := Format(term, FormatOptions{Syntax: ElixirSyntax, Indent: "  "}); s != expected {
+		t.Fatalf("expected %q, got %q", expected, s)
+	}
+}
+
+// TestFormatMaxWidth is used to test cutting long lines.
+func TestFormatMaxWidth(t *testing.T) {
+	term := []interface{}{[]byte("hello world"), []byte("hi")}
+	if s := Format(term, FormatOptions{MaxWidth: 12}); s != `[<<"hello...` {
+		t.Fatal("unexpected result:", s)
+	}
+	expected := "[\n  <<\"he...\n  <<\"hi\">>\n]"
+	if s := Format(term, FormatOptions{Indent: "  ", MaxWidth: 10}); s != expected {
+		t.Fatalf("expected %q, got %q", expected, s)
+	}
+}
+
+// TestFormatter is used to test formatting with the fmt package.
+func TestFormatter(t *testing.T) {
+	var u UncastedResult
+	if err := Unpack([]byte("\x83h\x02s\x02okm\x00\x00\x00\x03abc"), &u); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		fmt.Sprintf("%v", Atom("Hi")):               "'Hi'",
+		fmt.Sprintf("%+v", Atom("Hi")):              `:Hi`,
+		fmt.Sprintf("%s", Atom("Hi")):               "Hi",
+		fmt.Sprintf("%q", Atom("Hi")):               `"Hi"`,
+		fmt.Sprintf("%#v", Atom("Hi")):              `erlpack.Atom("Hi")`,
+		fmt.Sprintf("%6v|", Atom("ok")):             "    ok|",
+		fmt.Sprintf("%v", RawData("a\x01")):         "1",
+		fmt.Sprintf("%x", RawData("a\x01")):         "6101",
+		fmt.Sprintf("%v", u):                        `{ok,<<"abc">>}`,
+		fmt.Sprintf("%+v", &u):                      `{:ok, "abc"}`,
+		fmt.Sprintf("%v", []interface{}{Atom("a")}): "[a]",
+	}
+	for got, expected := range tests {
+		if got != expected {
+			t.Errorf("expected %s, got %s", expected, got)
+		}
+	}
+}
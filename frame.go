@@ -0,0 +1,160 @@
+package erlpack
+
+import (
+	"errors"
+	"io"
+	"strconv"
+)
+
+// Defines the most bytes of a frame which are read at once.
+const frameChunkSize = 65536
+
+// ErrFrameTooLarge is returned when a frame is larger than the maximum frame size, or too large for the length prefix.
+var ErrFrameTooLarge = errors.New("frame is too large")
+
+// Used to check the size of a length prefix is 1, 2 or 4.
+func checkPrefixSize(PrefixSize int) error {
+	switch PrefixSize {
+	case 1, 2, 4:
+		return nil
+	default:
+		return errors.New("invalid frame prefix size " + strconv.Itoa(PrefixSize))
+	}
+}
+
+// FrameReader is used to read terms which each have a big-endian length before them, like the {packet, N} option
+// for gen_tcp and ports. The buffer used to read each frame is reused. FrameReader is not safe for concurrent use.
+type FrameReader struct {
+	// MaxFrameSize is the largest frame in bytes which will be read. If this is 0, there is no limit other than the
+	// one set by the prefix size. Either way, memory is only allocated as the bytes of a frame arrive.
+	MaxFrameSize int
+
+	// Options is the decoder options used by Decode.
+	Options DecoderOptions
+
+	r      io.Reader
+	size   int
+	buf    []byte
+	header [4]byte
+}
+
+// NewFrameReader is used to create a FrameReader which reads from the reader specified. The prefix size is the size
+// of the length in bytes, and must be 1, 2 or 4.
+func NewFrameReader(r io.Reader, PrefixSize int) *FrameReader {
+	return &FrameReader{r: r, size: PrefixSize}
+}
+
+// Next is used to read the bytes of the next frame. The bytes are only valid until the next frame is read. io.EOF is
+// returned if the reader ended between frames, and io.ErrUnexpectedEOF is returned if it ended part of the way
+// through one.
+func (f *FrameReader) Next() ([]byte, error) {
+	if err := checkPrefixSize(f.size); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(f.r, f.header[:f.size]); err != nil {
+		return nil, err
+	}
+	var l uint64
+	for _, b := range f.header[:f.size] {
+		l = l<<8 | uint64(b)
+	}
+	if f.MaxFrameSize > 0 && l > uint64(f.MaxFrameSize) {
+		return nil, ErrFrameTooLarge
+	}
+
+	// Read the frame in chunks, so the buffer only grows as the bytes arrive rather than to whatever the length says.
+	f.buf = f.buf[:0]
+	for uint64(len(f.buf)) < l {
+		n := l - uint64(len(f.buf))
+		if n > frameChunkSize {
+			n = frameChunkSize
+		}
+		start := len(f.buf)
+		f.buf = append(f.buf, make([]byte, n)...)
+		if _, err := io.ReadFull(f.r, f.buf[start:]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+	return f.buf, nil
+}
+
+// ReadFrame is used to read the next frame as the raw data of the term it contains, so it can be cast later.
+// Compressed terms are inflated. Otherwise, the raw data shares the reader's buffer and is only valid until the next
+// frame is read, so it should be copied if it needs to be kept.
+func (f *FrameReader) ReadFrame() (RawData, error) {
+	frame, err := f.Next()
+	if err != nil {
+		return nil, err
+	}
+	if len(frame) < 2 || frame[0] != 131 {
+		return nil, errors.New("invalid erlpack bytes")
+	}
+	if frame[1] == 'P' {
+		var raw RawData
+		if err = UnpackWithOptions(frame, &raw, f.Options); err != nil {
+			return nil, err
+		}
+		return raw, nil
+	}
+	return RawData(frame[1:]), nil
+}
+
+// Decode is used to read the next frame and unpack it into a pointer.
+func (f *FrameReader) Decode(Ptr interface{}) error {
+	frame, err := f.Next()
+	if err != nil {
+		return err
+	}
+	return UnpackWithOptions(frame, Ptr, f.Options)
+}
+
+// FrameWriter is used to write terms which each have a big-endian length before them. It is the reverse of
+// FrameReader. FrameWriter is not safe for concurrent use.
+type FrameWriter struct {
+	// MaxFrameSize is the largest frame in bytes which will be written. If this is 0, there is no limit other than the
+	// one set by the prefix size.
+	MaxFrameSize int
+
+	// Options is the encoder options used by Encode.
+	Options EncoderOptions
+
+	w    io.Writer
+	size int
+	buf  []byte
+}
+
+// NewFrameWriter is used to create a FrameWriter which writes to the writer specified. The prefix size is the size of
+// the length in bytes, and must be 1, 2 or 4.
+func NewFrameWriter(w io.Writer, PrefixSize int) *FrameWriter {
+	return &FrameWriter{w: w, size: PrefixSize}
+}
+
+// WriteFrame is used to write the bytes of a frame, which should be a packed term including the version byte. The
+// length and the bytes are written with a single call to the writer.
+func (f *FrameWriter) WriteFrame(Frame []byte) error {
+	if err := checkPrefixSize(f.size); err != nil {
+		return err
+	}
+	if uint64(len(Frame)) >= 1<<(uint(f.size)*8) || (f.MaxFrameSize > 0 && len(Frame) > f.MaxFrameSize) {
+		return ErrFrameTooLarge
+	}
+	f.buf = f.buf[:0]
+	for i := f.size - 1; i >= 0; i-- {
+		f.buf = append(f.buf, byte(len(Frame)>>(uint(i)*8)))
+	}
+	f.buf = append(f.buf, Frame...)
+	_, err := f.w.Write(f.buf)
+	return err
+}
+
+// Encode is used to pack a value and write it as a frame.
+func (f *FrameWriter) Encode(Data interface{}) error {
+	b, err := PackWithOptions(Data, f.Options)
+	if err != nil {
+		return err
+	}
+	return f.WriteFrame(b)
+}
@@ -0,0 +1,149 @@
+package erlpack
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestFrameRoundTrip is used to test writing and reading frames with each prefix size.
+func TestFrameRoundTrip(t *testing.T) {
+	for _, size := range []int{1, 2, 4} {
+		var buf bytes.Buffer
+		w := NewFrameWriter(&buf, size)
+		values := []interface{}{Atom("ok"), []byte("hello"), Tuple{1, 2, 3}, nil}
+		for _, v := range values {
+			if err := w.Encode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// The length should be big-endian and the size specified.
+		b, _ := Pack(Atom("ok"))
+		prefix := append(make([]byte, size-1), byte(len(b)))
+		if !bytes.HasPrefix(buf.Bytes(), append(prefix, b...)) {
+			t.Fatal("unexpected bytes:", buf.Bytes())
+		}
+
+		// Each frame should read back as raw data.
+		r := NewFrameReader(&buf, size)
+		for _, v := range values {
+			raw, err := r.ReadFrame()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := Pack(v)
+			if !bytes.Equal(raw, want[1:]) {
+				t.Fatalf("unexpected raw data for %v: %v", v, raw)
+			}
+		}
+		if _, err := r.ReadFrame(); err != io.EOF {
+			t.Fatal("expected io.EOF, got", err)
+		}
+	}
+}
+
+// TestFrameReaderDecode is used to test decoding frames and reusing the buffer.
+func TestFrameReaderDecode(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewFrameWriter(&buf, 4)
+	_ = w.Encode([]byte(strings.Repeat("a", 100)))
+	_ = w.Encode(Tuple{Atom("x"), 5})
+	r := NewFrameReader(&buf, 4)
+
+	var s string
+	if err := r.Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s != strings.Repeat("a", 100) {
+		t.Fatal("unexpected string:", s)
+	}
+	first := &r.buf[0]
+	var tuple Tuple
+	if err := r.Decode(&tuple); err != nil {
+		t.Fatal(err)
+	}
+	if len(tuple) != 2 || tuple[0] != Atom("x") || tuple[1] != uint8(5) {
+		t.Fatal("unexpected tuple:", tuple)
+	}
+	if &r.buf[0] != first {
+		t.Fatal("buffer was not reused")
+	}
+}
+
+// TestFrameCompressed is used to test that compressed frames are inflated.
+func TestFrameCompressed(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewFrameWriter(&buf, 4)
+	w.Options = EncoderOptions{Compress: true}
+	value := []byte(strings.Repeat("a", 1000))
+	if err := w.Encode(value); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 500 {
+		t.Fatal("frame was not compressed")
+	}
+	raw, err := NewFrameReader(&buf, 4).ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []byte
+	if err = raw.Cast(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, value) {
+		t.Fatal("unexpected value")
+	}
+}
+
+// TestFrameErrors is used to test the errors returned when reading and writing frames.
+func TestFrameErrors(t *testing.T) {
+	// Frames which are too large should error.
+	if err := NewFrameWriter(io.Discard, 1).WriteFrame(make([]byte, 256)); err != ErrFrameTooLarge {
+		t.Fatal("expected ErrFrameTooLarge for frame too large for prefix, got", err)
+	}
+	w := NewFrameWriter(io.Discard, 4)
+	w.MaxFrameSize = 10
+	if err := w.WriteFrame(make([]byte, 11)); err != ErrFrameTooLarge {
+		t.Fatal("expected ErrFrameTooLarge for frame over maximum, got", err)
+	}
+	r := NewFrameReader(bytes.NewReader([]byte{0, 11}), 2)
+	r.MaxFrameSize = 10
+	if _, err := r.Next(); err != ErrFrameTooLarge {
+		t.Fatal("expected ErrFrameTooLarge when reading, got", err)
+	}
+
+	// Readers which end part of the way through a frame should return io.ErrUnexpectedEOF.
+	for _, b := range [][]byte{{0, 0}, {0, 0, 0, 3, 131}} {
+		if _, err := NewFrameReader(bytes.NewReader(b), 4).Next(); err != io.ErrUnexpectedEOF {
+			t.Fatal("expected io.ErrUnexpectedEOF, got", err)
+		}
+	}
+
+	// Frames are read in chunks, and a large length shouldn't allocate more than the bytes which arrived.
+	large := append([]byte{0, 3, 0, 0}, bytes.Repeat([]byte{1}, 3<<16)...)
+	if b, err := NewFrameReader(bytes.NewReader(large), 4).Next(); err != nil || !bytes.Equal(b, large[4:]) {
+		t.Fatal("frame larger than a chunk did not read back:", err)
+	}
+	r = NewFrameReader(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 131}), 4)
+	if _, err := r.Next(); err != io.ErrUnexpectedEOF {
+		t.Fatal("expected io.ErrUnexpectedEOF, got", err)
+	}
+	if cap(r.buf) > frameChunkSize {
+		t.Fatal("buffer grew to", cap(r.buf), "bytes")
+	}
+
+	// Frames which aren't terms should error.
+	if _, err := NewFrameReader(bytes.NewReader([]byte{2, 130, 97}), 1).ReadFrame(); err == nil {
+		t.Fatal("expected error for invalid version")
+	}
+
+	// Invalid prefix sizes should error.
+	if _, err := NewFrameReader(bytes.NewReader(nil), 3).Next(); err == nil {
+		t.Fatal("expected error for invalid prefix size")
+	}
+	if err := NewFrameWriter(io.Discard, 0).WriteFrame(nil); err == nil {
+		t.Fatal("expected error for invalid prefix size")
+	}
+}
@@ -0,0 +1,176 @@
+package erlpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+)
+
+// Export is used to define an exported function reference (fun Module:Function/Arity) within the codebase.
+type Export struct {
+	Module   Atom
+	Function Atom
+	Arity    uint8
+}
+
+// Fun is used to define a closure which was within an Erlpack array.
+// The bytes (including any free variables) are kept as they were unpacked so the fun can be packed and sent back to
+// Erlang unchanged, apart from atom cache references which are replaced with the atoms. There is no way to call it
+// from Go.
+type Fun struct {
+	raw []byte
+}
+
+// Bytes is used to get the raw external term format bytes of the fun (including the tag).
+func (f Fun) Bytes() []byte {
+	return f.raw
+}
+
+// Arity is used to get the number of arguments the fun takes.
+func (f Fun) Arity() uint8 {
+	if len(f.raw) < 6 {
+		return 0
+	}
+	return f.raw[5]
+}
+
+// Used to read the atom data for any of the atom data types.
+func readAtomData(DataType byte, r unpackReader) ([]byte, error) {
+	// Get the length of the atom.
+	var Len int
+	switch DataType {
+	case 's', 'w': // small atom
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		Len = int(b)
+	case 'd', 'v': // atom
+		lengthBytes := make([]byte, 2)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return nil, errors.New("not enough bytes for atom length")
+		}
+		Len = int(binary.BigEndian.Uint16(lengthBytes))
+	default:
+		return nil, errors.New("expected atom")
+	}
+
+	// Read the atom.
+	Data := make([]byte, Len)
+	if _, err := io.ReadFull(r, Data); err != nil {
+		return nil, errors.New("atom size larger than remainder of array")
+	}
+	return Data, nil
+}
+
+// Used to read the tag and data of an atom which is part of a larger data type, resolving atom cache references.
+func readAtomTerm(r unpackReader, opts *DecoderOptions) (byte, []byte, error) {
+	DataType, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, errors.New("not long enough to include data type")
+	}
+	if DataType == 'R' {
+		if DataType, r, err = resolveAtomCacheRef(r, opts); err != nil {
+			return 0, nil, err
+		}
+	}
+	Data, err := readAtomData(DataType, r)
+	return DataType, Data, err
+}
+
+// Used to read an atom which is part of a larger data type.
+func readAtom(r unpackReader, opts *DecoderOptions) (Atom, error) {
+	_, Data, err := readAtomTerm(r, opts)
+	if err != nil {
+		return "", err
+	}
+	return Atom(Data), nil
+}
+
+// Used to process an export during unpacking.
+func processExport(r unpackReader, opts *DecoderOptions) (Export, error) {
+	Module, err := readAtom(r, opts)
+	if err != nil {
+		return Export{}, err
+	}
+	Function, err := readAtom(r, opts)
+	if err != nil {
+		return Export{}, err
+	}
+	DataType, err := r.ReadByte()
+	if err != nil {
+		return Export{}, errors.New("not long enough to include data type")
+	}
+	if DataType != 'a' {
+		return Export{}, errors.New("export arity must be a small int")
+	}
+	Arity, err := r.ReadByte()
+	if err != nil {
+		return Export{}, errors.New("failed to read export arity")
+	}
+	return Export{Module: Module, Function: Function, Arity: Arity}, nil
+}
+
+// Used to process a fun during unpacking. The size includes the 4 bytes of the size itself.
+func processFun(r unpackReader, opts *DecoderOptions) (Fun, error) {
+	lengthBytes := make([]byte, 4)
+	if _, err := io.ReadFull(r, lengthBytes); err != nil {
+		return Fun{}, errors.New("not enough bytes for fun size")
+	}
+	l := binary.BigEndian.Uint32(lengthBytes)
+	if 4 > l {
+		return Fun{}, errors.New("fun size is too small")
+	}
+	raw := make([]byte, 5)
+	raw[0] = 'p'
+	copy(raw[1:], lengthBytes)
+	if _, err := io.CopyN(sliceWriter{&raw}, r, int64(l-4)); err != nil {
+		return Fun{}, errors.New("fun size larger than remainder of array")
+	}
+	if len(raw) < 30 {
+		return Fun{}, errors.New("fun size is too small")
+	}
+
+	// Read the module, old index, old unique number, pid and free variables as raw data. This replaces any atom cache
+	// references with the atoms, so the fun can be packed again without the distribution header.
+	numFree := binary.BigEndian.Uint32(raw[26:30])
+	br := bytes.NewReader(raw[30:])
+	fixed := append(make([]byte, 0, len(raw)), raw[:30]...)
+	for i := uint64(0); i < 4+uint64(numFree); i++ {
+		DataType, err := br.ReadByte()
+		if err != nil {
+			return Fun{}, errors.New("fun is missing terms")
+		}
+		var term RawData
+		if err = processRawData(DataType, &pointerSetter{ptr: reflect.ValueOf(&term)}, br, false, opts); err != nil {
+			return Fun{}, err
+		}
+		fixed = append(fixed, term...)
+	}
+	if br.Len() != 0 {
+		return Fun{}, errors.New("fun has bytes after the free variables")
+	}
+	binary.BigEndian.PutUint32(fixed[1:5], uint32(len(fixed)-1))
+	return Fun{raw: fixed}, nil
+}
+
+// Used to append to a byte slice with io.Copy.
+type sliceWriter struct {
+	b *[]byte
+}
+
+// Write is used to append the bytes to the slice.
+func (w sliceWriter) Write(p []byte) (int, error) {
+	*w.b = append(*w.b, p...)
+	return len(p), nil
+}
+
+// packExport is used to pack a export.
+func packExport(Data Export, pad *scratchpad) {
+	pad.endAppend('q')
+	packAtom(Data.Module, pad)
+	packAtom(Data.Function, pad)
+	pad.endAppend('a', Data.Arity)
+}
@@ -0,0 +1,128 @@
+package erlpack
+
+import (
+	"testing"
+)
+
+// testFun is a closure from erl_eval with a single free variable (42).
+const testFun = "p\x00\x00\x00\x4c\x01\x9c\x1e\x5a\x6b\x3f\x7c\x1a\x8d\x4e\x2f\x12\x33\x44\x55\x66\x77\x00\x00\x00\x00\x00\x00\x00\x01" +
+	"s\x08erl_evala\x00b\x05\xf5\xe1\x00X" + "s\x0dnonode@nohost\x00\x00\x00\x4f\x00\x00\x00\x00\x00\x00\x00\x00" + "a\x2a"
+
+// TestUnpackExport is used to test unpacking a export.
+func TestUnpackExport(t *testing.T) {
+	var e Export
+	err := Unpack([]byte("\x83qw\x05listsw\x03mapa\x02"), &e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Module != "lists" || e.Function != "map" || e.Arity != 2 {
+		t.Fatal("unexpected result:", e)
+	}
+}
+
+// TestPackExport is used to test packing a export.
+func TestPackExport(t *testing.T) {
+	b, err := Pack(Export{Module: "lists", Function: "map", Arity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = assertBytes([]byte("\x83qw\x05listsw\x03mapa\x02"), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestFunRoundTrip is used to test that a fun is packed exactly how it was unpacked.
+func TestFunRoundTrip(t *testing.T) {
+	packed := []byte("\x83l\x00\x00\x00\x02" + testFun + "w\x02okj")
+	var a []interface{}
+	err := Unpack(packed, &a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, ok := a[0].(Fun)
+	if !ok {
+		t.Fatal("expected fun, got", a[0])
+	}
+	if f.Arity() != 1 {
+		t.Fatal("unexpected arity:", f.Arity())
+	}
+	err = bytesAssert([]byte(testFun), f.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Pack(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bytesAssert(packed, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestFunRawData is used to test that a fun is skipped properly as RawData.
+func TestFunRawData(t *testing.T) {
+	var r RawData
+	err := Unpack([]byte("\x83"+testFun), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bytesAssert([]byte(testFun), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f Fun
+	err = r.Cast(&f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bytesAssert([]byte(testFun), f.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestFunAtomCacheRefs is used to test that atom cache references in a fun are replaced with the atoms.
+func TestFunAtomCacheRefs(t *testing.T) {
+	// testFun with the module and the node of the pid as references.
+	body := testFun[5:30] + "R\x00a\x00b\x05\xf5\xe1\x00X" + "R\x01\x00\x00\x00\x4f\x00\x00\x00\x00\x00\x00\x00\x00" + "a\x2a"
+	b := []byte("\x83p\x00\x00\x00" + string([]byte{byte(len(body) + 4)}) + body)
+	opts := DecoderOptions{AtomCacheRefs: []Atom{"erl_eval", "nonode@nohost"}}
+	var f Fun
+	if err := UnpackWithOptions(b, &f, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := "p\x00\x00\x00\x4c" + testFun[5:30] + "w\x08erl_evala\x00b\x05\xf5\xe1\x00X" +
+		"w\x0dnonode@nohost\x00\x00\x00\x4f\x00\x00\x00\x00\x00\x00\x00\x00" + "a\x2a"
+	if err := bytesAssert([]byte(want), f.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	var r RawData
+	if err := UnpackWithOptions(b, &r, opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := bytesAssert([]byte(want), r); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the atoms, the fun should error rather than keeping the references.
+	if err := Unpack(b, &f); err == nil {
+		t.Fatal("expected error without atom cache refs")
+	}
+}
+
+// TestDisallowFuns is used to test that funs are refused when the option is set.
+func TestDisallowFuns(t *testing.T) {
+	opts := DecoderOptions{DisallowFuns: true}
+	for _, packed := range []string{"\x83" + testFun, "\x83qw\x05listsw\x03mapa\x02"} {
+		var x interface{}
+		if err := UnpackWithOptions([]byte(packed), &x, opts); err == nil {
+			t.Fatal("expected error")
+		}
+		var r RawData
+		if err := UnpackWithOptions([]byte(packed), &r, opts); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
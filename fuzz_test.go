@@ -0,0 +1,139 @@
+package erlpack
+
+import (
+	"bytes"
+	"testing"
+	"testing/iotest"
+)
+
+// fuzzVectors are extra test vectors used to seed the fuzzers.
+var fuzzVectors = []string{
+	"\x83s\x04true",
+	"\x83m\x00\x00\x00\x0bhello world",
+	"\x83l\x00\x00\x00\x05a\x01m\x00\x00\x00\x03twoF\x40\x08\xcc\xcc\xcc\xcc\xcc\xcdm\x00\x00\x00\x04fourl\x00\x00\x00\x01m\x00\x00\x00\x04fivejj",
+	"\x83t\x00\x00\x00\x01m\x00\x00\x00\x01aa\x01",
+	"\x83c3.10000000000000008882e+00\x00\x00\x00\x00\x00",
+	"\x83qs\x05listss\x03mapa\x02",
+	"\x83" + testFun,
+	mapWithComplexKeys,
+}
+
+// fuzzStruct is used to test unpacking fuzzed data into a struct.
+type fuzzStruct struct {
+	A int               `erlpack:"a"`
+	B *string           `erlpack:"b"`
+	C []float64         `erlpack:"c"`
+	D map[string]uint8  `erlpack:"d"`
+	E [2]int            `erlpack:"e"`
+	F Tuple             `erlpack:"f"`
+	G *fuzzStruct       `erlpack:"g"`
+	H map[Atom]RawData  `erlpack:"h"`
+	I []map[int]float32 `erlpack:"i"`
+}
+
+// addFuzzSeeds is used to seed a fuzzer with the golden fixtures and test vectors.
+func addFuzzSeeds(f *testing.F) {
+	for _, fixture := range loadGoldenFixtures(f) {
+		f.Add(fixture.expected)
+	}
+	for _, v := range fuzzVectors {
+		f.Add([]byte(v))
+	}
+}
+
+// unpackFuzzTargets is used to unpack data into a number of different targets. This should never panic.
+func unpackFuzzTargets(unpack func(Ptr interface{}) error) {
+	var x interface{}
+	_ = unpack(&x)
+	var r RawData
+	_ = unpack(&r)
+	var s fuzzStruct
+	_ = unpack(&s)
+	var m map[interface{}]interface{}
+	_ = unpack(&m)
+	var o OrderedMap
+	_ = unpack(&o)
+	var a [3]string
+	_ = unpack(&a)
+	var i *int
+	_ = unpack(&i)
+	var u UncastedResult
+	if unpack(&u) == nil {
+		_ = u.Cast(&s)
+		_ = u.Cast(&o)
+		_ = u.Cast(&a)
+	}
+}
+
+// FuzzUnpack is used to fuzz Unpack.
+func FuzzUnpack(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		unpackFuzzTargets(func(Ptr interface{}) error {
+			return Unpack(data, Ptr)
+		})
+	})
+}
+
+// FuzzUnpackReader is used to fuzz UnpackReader with a reader which is not a io.ByteReader.
+func FuzzUnpackReader(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		unpackFuzzTargets(func(Ptr interface{}) error {
+			return UnpackReader(iotest.OneByteReader(bytes.NewReader(data)), Ptr)
+		})
+	})
+}
+
+// FuzzRawDataCast is used to fuzz RawData.Cast.
+func FuzzRawDataCast(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) != 0 && data[0] == 131 {
+			data = data[1:]
+		}
+		unpackFuzzTargets(func(Ptr interface{}) error {
+			return RawData(data).Cast(Ptr)
+		})
+	})
+}
+
+// roundTrip is used to unpack data and pack it again.
+func roundTrip(data []byte) ([]byte, error) {
+	var x interface{}
+	if err := Unpack(data, &x); err != nil {
+		return nil, err
+	}
+	return PackWithOptions(x, EncoderOptions{Deterministic: true})
+}
+
+// FuzzRoundTrip is used to fuzz that anything which can be unpacked can be packed, and that packing is stable.
+// The data is packed twice first since malformed data (such as a map with the same key encoded 2 different ways) is
+// normalised by the first round trip.
+func FuzzRoundTrip(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var x interface{}
+		if Unpack(data, &x) != nil {
+			return
+		}
+		first, err := PackWithOptions(x, EncoderOptions{Deterministic: true})
+		if err != nil {
+			if _, ok := err.(*NonFiniteFloatError); ok {
+				return
+			}
+			t.Fatal(err)
+		}
+		second, err := roundTrip(first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		third, err := roundTrip(second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(second, third) {
+			t.Fatalf("packing is not stable: %v != %v", second, third)
+		}
+	})
+}
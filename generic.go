@@ -0,0 +1,57 @@
+package erlpack
+
+// UnpackAs is used to unpack the data into a new value of the type specified.
+func UnpackAs[T any](Data []byte) (T, error) {
+	var v T
+	err := Unpack(Data, &v)
+	return v, err
+}
+
+// CastAs is used to cast the raw data into a new value of the type specified.
+func CastAs[T any](r RawData) (T, error) {
+	var v T
+	err := r.Cast(&v)
+	return v, err
+}
+
+// Implemented by types which hold raw data which should be packed as-is (such as Lazy).
+type rawDataGetter interface {
+	rawData() RawData
+}
+
+// Lazy is used to hold a term which is only unpacked into the type specified when Get is first called.
+// The result is cached after this, so further calls to Get do not unpack the data again. Lazy is not safe for
+// concurrent use.
+type Lazy[T any] struct {
+	// Raw is the raw data of the term.
+	Raw RawData
+
+	opts    *DecoderOptions
+	value   T
+	err     error
+	decoded bool
+}
+
+// Get is used to get the unpacked value. The data is unpacked on the first call and cached.
+// The decoder options the enclosing value was unpacked with are used, or the defaults if Raw was set directly.
+func (l *Lazy[T]) Get() (T, error) {
+	if !l.decoded {
+		opts := DecoderOptions{}
+		if l.opts != nil {
+			opts = *l.opts
+		}
+		l.err = l.Raw.CastWithOptions(&l.value, opts)
+		l.decoded = true
+	}
+	return l.value, l.err
+}
+
+// Used to set the raw data and decoder options when the term is unpacked.
+func (l *Lazy[T]) setRawData(r RawData, opts *DecoderOptions) {
+	*l = Lazy[T]{Raw: r, opts: opts}
+}
+
+// Used to get the raw data when the term is packed.
+func (l Lazy[T]) rawData() RawData {
+	return l.Raw
+}
@@ -0,0 +1,128 @@
+package erlpack
+
+import (
+	"testing"
+)
+
+// TestUnpackAs is used to test unpacking into a type.
+func TestUnpackAs(t *testing.T) {
+	s, err := UnpackAs[string]([]byte("\x83m\x00\x00\x00\x05hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "hello" {
+		t.Fatal("unexpected result:", s)
+	}
+	if _, err = UnpackAs[int]([]byte("\x83m\x00\x00\x00\x05hello")); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// TestCastAs is used to test casting raw data into a type.
+func TestCastAs(t *testing.T) {
+	l, err := CastAs[[]int](RawData("l\x00\x00\x00\x02a\x01a\x02j"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 2 || l[0] != 1 || l[1] != 2 {
+		t.Fatal("unexpected result:", l)
+	}
+}
+
+// TestLazy is used to test lazily unpacking a struct field.
+func TestLazy(t *testing.T) {
+	type test struct {
+		Op int             `erlpack:"op"`
+		D  Lazy[[]string]  `erlpack:"d"`
+		R  RawData         `erlpack:"r"`
+		U  map[string]Atom `erlpack:"-"`
+	}
+	data := []byte("\x83t\x00\x00\x00\x04m\x00\x00\x00\x02opa\x01m\x00\x00\x00\x01dl\x00\x00\x00\x01m\x00\x00\x00\x01aj" +
+		"m\x00\x00\x00\x01rs\x02okm\x00\x00\x00\x07unknowna\x02")
+	x, err := UnpackAs[test](data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.Op != 1 {
+		t.Fatal("unexpected op:", x.Op)
+	}
+	if err = bytesAssert([]byte("l\x00\x00\x00\x01m\x00\x00\x00\x01aj"), x.D.Raw); err != nil {
+		t.Fatal(err)
+	}
+	if err = bytesAssert([]byte("s\x02ok"), x.R); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		d, err := x.D.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(d) != 1 || d[0] != "a" {
+			t.Fatal("unexpected result:", d)
+		}
+	}
+
+	// Packing the struct should write the raw data back.
+	b, err := Pack(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var y test
+	if err = Unpack(b, &y); err != nil {
+		t.Fatal(err)
+	}
+	if err = bytesAssert(x.D.Raw, y.D.Raw); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestLazyUncastedResult is used to test casting a uncasted result into a lazy value.
+func TestLazyUncastedResult(t *testing.T) {
+	var u UncastedResult
+	if err := Unpack([]byte("\x83a\x05"), &u); err != nil {
+		t.Fatal(err)
+	}
+	var l Lazy[int]
+	if err := u.Cast(&l); err != nil {
+		t.Fatal(err)
+	}
+	v, err := l.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 5 {
+		t.Fatal("unexpected result:", v)
+	}
+}
+
+// TestLazyOptions is used to test that a lazy value is unpacked with the options the enclosing value was.
+func TestLazyOptions(t *testing.T) {
+	var x struct {
+		L Lazy[interface{}] `erlpack:"l"`
+	}
+	data := []byte("\x83t\x00\x00\x00\x01m\x00\x00\x00\x01la\x05")
+	if err := UnpackWithOptions(data, &x, DecoderOptions{IntegersAsInt64: true}); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := x.L.Get(); err != nil || v != int64(5) {
+		t.Fatalf("unexpected result: %#v (%v)", v, err)
+	}
+}
+
+// TestPackNilLazy is used to test that a nil lazy pointer packs as nil.
+func TestPackNilLazy(t *testing.T) {
+	b, err := Pack(struct{ L *Lazy[int] }{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var x struct{ L interface{} }
+	if err = Unpack(b, &x); err != nil {
+		t.Fatal(err)
+	}
+	if x.L != nil {
+		t.Fatal("unexpected result:", x.L)
+	}
+	if s := Format((*Lazy[int])(nil), FormatOptions{}); s != "nil" {
+		t.Fatal("unexpected format:", s)
+	}
+}
@@ -1,5 +1,5 @@
 module github.com/JakeMakesStuff/go-erlpack
 
-go 1.14
+go 1.18
 
 require github.com/jakemakesstuff/structs v0.0.0-20200620173721-9ab34a601bc6
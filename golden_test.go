@@ -0,0 +1,186 @@
+package erlpack
+
+import (
+	"bufio"
+	"encoding/hex"
+	"math"
+	"math/big"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// goldenStruct is used to test packing a struct against the golden fixtures.
+type goldenStruct struct {
+	A int    `erlpack:"a"`
+	B string `erlpack:"b"`
+}
+
+// goldenValues are the Go values for each fixture in testdata/golden.txt.
+var goldenValues = map[string]interface{}{
+	"small_int_zero":              0,
+	"small_int_one":               1,
+	"small_int_max":               255,
+	"int_small_int_max_plus_one":  256,
+	"int_negative_one":            -1,
+	"int_max":                     math.MaxInt32,
+	"int_min":                     math.MinInt32,
+	"small_big_int_max_plus_one":  int64(math.MaxInt32 + 1),
+	"small_big_int_min_minus_one": int64(math.MinInt32 - 1),
+	"small_big_int64_max":         int64(math.MaxInt64),
+	"small_big_int64_min":         int64(math.MinInt64),
+	"small_big_uint64_max":        uint64(math.MaxUint64),
+	"int64_small":                 int64(5),
+	"int8_min":                    int8(math.MinInt8),
+	"uint16_max":                  uint16(math.MaxUint16),
+	"float_zero":                  0.0,
+	"float_one_and_a_half":        1.5,
+	"float_negative":              -2.5,
+	"float32":                     float32(0.5),
+	"atom":                        Atom("ok"),
+	"atom_empty":                  Atom(""),
+	"atom_true":                   true,
+	"atom_false":                  false,
+	"atom_nil":                    nil,
+	"binary_empty":                "",
+	"binary_string":               "hello",
+	"binary_bytes":                []byte{1, 2, 3},
+	"list_empty":                  []int{},
+	"list":                        []interface{}{1, "a"},
+	"list_nested":                 [][]int{{1}},
+	"tuple_empty":                 Tuple{},
+	"tuple":                       Tuple{Atom("ok"), 1},
+	"tuple_large":                 make(Tuple, 256),
+	"map_empty":                   map[string]int{},
+	"map":                         map[string]int{"a": 1},
+	"map_atom_key":                map[Atom][]int{"ok": {1}},
+	"struct":                      goldenStruct{A: 1, B: "c"},
+	"export":                      Export{Module: "lists", Function: "map", Arity: 2},
+	"float_legacy":                3.1,
+	"atom_utf8":                   Atom("héllo"),
+	"atom_utf8_long":              Atom(strings.Repeat("é", 128)),
+	"atom_latin1":                 Atom("ok"),
+	"atom_latin1_small":           Atom("ok"),
+	"large_big":                   new(big.Int).Lsh(big.NewInt(1), 2040),
+	"large_big_negative":          new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 2040)),
+	"improper_list":               ImproperList{Items: []interface{}{1}, Tail: 2},
+	"pid":                         Pid{Node: "a@localhost", ID: 1, Creation: 5},
+	"compressed":                  strings.Repeat("a", 100),
+}
+
+// goldenFixture is a single fixture from testdata/golden.txt.
+type goldenFixture struct {
+	name       string
+	term       string
+	expected   []byte
+	opts       EncoderOptions
+	unpackOnly bool
+}
+
+// loadGoldenFixtures is used to load the fixtures from testdata/golden.txt.
+func loadGoldenFixtures(t testing.TB) []goldenFixture {
+	f, err := os.Open("testdata/golden.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fixtures := []goldenFixture{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Split(line, "\t")
+		if len(parts) != 3 && len(parts) != 4 {
+			t.Fatal("invalid fixture:", line)
+		}
+		expected, err := hex.DecodeString(parts[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		fixture := goldenFixture{name: parts[0], term: parts[1], expected: expected}
+		if len(parts) == 4 {
+			for _, opt := range strings.Split(parts[3], ",") {
+				switch opt {
+				case "legacy_floats":
+					fixture.opts.LegacyFloats = true
+				case "unpack_only":
+					fixture.unpackOnly = true
+				default:
+					t.Fatal("invalid fixture option:", opt)
+				}
+			}
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	if err = scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return fixtures
+}
+
+// Used to fill the large tuple with zeros.
+func init() {
+	t := goldenValues["tuple_large"].(Tuple)
+	for i := range t {
+		t[i] = 0
+	}
+}
+
+// TestPackGolden is used to test that packing matches the golden fixtures byte for byte.
+func TestPackGolden(t *testing.T) {
+	fixtures := loadGoldenFixtures(t)
+	if len(fixtures) != len(goldenValues) {
+		t.Fatal("expected", len(goldenValues), "fixtures, got", len(fixtures))
+	}
+	for _, fixture := range fixtures {
+		v, ok := goldenValues[fixture.name]
+		if !ok {
+			t.Fatal("no Go value for", fixture.name)
+		}
+		if fixture.unpackOnly {
+			continue
+		}
+		b, err := PackWithOptions(v, fixture.opts)
+		if err != nil {
+			t.Fatal(fixture.name, err)
+		}
+		if err = assertBytes(fixture.expected, b); err != nil {
+			t.Error(fixture.name, fixture.term, err)
+		}
+	}
+}
+
+// TestUnpackGolden is used to test that unpacking the golden fixtures and packing them again gives the same bytes.
+// Fixtures which are only unpacked are checked against their Go value instead.
+func TestUnpackGolden(t *testing.T) {
+	for _, fixture := range loadGoldenFixtures(t) {
+		if fixture.unpackOnly {
+			want := goldenValues[fixture.name]
+			ptr := reflect.New(reflect.TypeOf(want))
+			if err := Unpack(fixture.expected, ptr.Interface()); err != nil {
+				t.Fatal(fixture.name, err)
+			}
+			if !reflect.DeepEqual(ptr.Elem().Interface(), want) {
+				t.Error(fixture.name, fixture.term, "unpacked as", ptr.Elem().Interface())
+			}
+			continue
+		}
+		var x interface{}
+		err := Unpack(fixture.expected, &x)
+		if err != nil {
+			t.Fatal(fixture.name, err)
+		}
+		opts := fixture.opts
+		opts.Deterministic = true
+		b, err := PackWithOptions(x, opts)
+		if err != nil {
+			t.Fatal(fixture.name, err)
+		}
+		if err = assertBytes(fixture.expected, b); err != nil {
+			t.Error(fixture.name, fixture.term, err)
+		}
+	}
+}
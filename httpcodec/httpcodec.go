@@ -0,0 +1,189 @@
+// Package httpcodec contains helpers for HTTP APIs which accept and return both JSON and the external term format.
+// Request bodies are unpacked if they are sent as application/x-erlang-binary (or start with the version byte), and
+// decoded as JSON otherwise. Responses are packed or encoded as JSON depending on the Accept header of the request.
+package httpcodec
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// ContentType is the media type of the external term format.
+const ContentType = "application/x-erlang-binary"
+
+// JSONContentType is the media type of JSON.
+const JSONContentType = "application/json"
+
+// ErrBodyTooLarge is returned when a request body is larger than Options.MaxBodySize.
+var ErrBodyTooLarge = errors.New("request body is too large")
+
+// Options is used to define options which change how requests and responses are handled.
+// The zero value is the default behaviour used by ReadRequest and WriteResponse.
+type Options struct {
+	// MaxBodySize is the largest request body in bytes which will be read. If this is 0, there is no limit.
+	MaxBodySize int64
+
+	// Decoder is the options used when unpacking request bodies.
+	Decoder erlpack.DecoderOptions
+
+	// Encoder is the options used when packing responses.
+	Encoder erlpack.EncoderOptions
+}
+
+// ReadRequest is used to read the body of a request into a pointer. The body is unpacked if the Content-Type is
+// application/x-erlang-binary or the body starts with the version byte, and is decoded as JSON otherwise.
+func ReadRequest(r *http.Request, Ptr interface{}) error {
+	return ReadRequestWithOptions(r, Ptr, Options{})
+}
+
+// ReadRequestWithOptions is used to read the body of a request into a pointer with the options specified.
+func ReadRequestWithOptions(r *http.Request, Ptr interface{}, Options Options) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return io.ErrUnexpectedEOF
+	}
+	var body io.Reader = r.Body
+	if Options.MaxBodySize > 0 {
+		body = &limitedReader{r: body, n: Options.MaxBodySize}
+	}
+
+	// Use the content type if it says the body is a term, and otherwise check for the version byte. JSON can't start
+	// with the version byte since it is not valid UTF-8.
+	br := bufio.NewReader(body)
+	etf := IsTermContentType(r.Header.Get("Content-Type"))
+	if !etf {
+		b, err := br.Peek(1)
+		if err != nil && len(b) == 0 {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		etf = b[0] == 131
+	}
+	if etf {
+		return erlpack.UnpackReaderWithOptions(br, Ptr, Options.Decoder)
+	}
+	return json.NewDecoder(br).Decode(Ptr)
+}
+
+// IsTermContentType is used to check if a Content-Type header is application/x-erlang-binary. Parameters are ignored.
+func IsTermContentType(Header string) bool {
+	t, _, err := mime.ParseMediaType(Header)
+	return err == nil && t == ContentType
+}
+
+// Negotiate is used to pick the media type of a response from the Accept header of a request. ContentType is returned
+// if the header prefers it to JSON, and JSONContentType is returned otherwise (including when the header is blank or
+// accepts neither).
+func Negotiate(Accept string) string {
+	ranges := parseAccept(Accept)
+	if termQ := quality(ranges, ContentType); termQ > 0 && termQ > quality(ranges, JSONContentType) {
+		return ContentType
+	}
+	return JSONContentType
+}
+
+// Used to define a media range from a Accept header.
+type mediaRange struct {
+	t string
+	q float64
+}
+
+// Used to parse the media ranges in a Accept header. Invalid ranges are skipped.
+func parseAccept(Accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(Accept, ",") {
+		t, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{t: t, q: q})
+	}
+	return ranges
+}
+
+// Used to get the quality of a media type from the most specific range which matches it, or -1 if none do.
+func quality(ranges []mediaRange, t string) float64 {
+	q, best := -1.0, 0
+	for _, r := range ranges {
+		specific := 0
+		switch {
+		case r.t == t:
+			specific = 3
+		case r.t == t[:strings.IndexByte(t, '/')]+"/*":
+			specific = 2
+		case r.t == "*/*":
+			specific = 1
+		}
+		if specific > best {
+			q, best = r.q, specific
+		}
+	}
+	return q
+}
+
+// WriteResponse is used to write a response with the status code and data specified, packed or encoded as JSON
+// depending on the Accept header of the request. If the data can't be encoded, the error is returned and nothing is
+// written.
+func WriteResponse(w http.ResponseWriter, r *http.Request, Status int, Data interface{}) error {
+	return WriteResponseWithOptions(w, r, Status, Data, Options{})
+}
+
+// WriteResponseWithOptions is used to write a response with the options specified.
+func WriteResponseWithOptions(w http.ResponseWriter, r *http.Request, Status int, Data interface{}, Options Options) error {
+	t := Negotiate(r.Header.Get("Accept"))
+	var b []byte
+	var err error
+	if t == ContentType {
+		b, err = erlpack.PackWithOptions(Data, Options.Encoder)
+	} else {
+		b, err = json.Marshal(Data)
+		t += "; charset=utf-8"
+	}
+	if err != nil {
+		return err
+	}
+	h := w.Header()
+	h.Set("Content-Type", t)
+	h.Set("Content-Length", strconv.Itoa(len(b)))
+	h.Add("Vary", "Accept")
+	w.WriteHeader(Status)
+	_, err = w.Write(b)
+	return err
+}
+
+// Used to return ErrBodyTooLarge if more than n bytes are read.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read is used to read from the underlying reader.
+func (l *limitedReader) Read(b []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	if int64(len(b)) > l.n+1 {
+		b = b[:l.n+1]
+	}
+	n, err := l.r.Read(b)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	return n, err
+}
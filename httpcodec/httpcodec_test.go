@@ -0,0 +1,186 @@
+package httpcodec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Used to define the body used in the tests.
+type testBody struct {
+	Name  string `erlpack:"name" json:"name"`
+	Count int    `erlpack:"count" json:"count"`
+}
+
+// Used to pack a value or fail the test.
+func mustPack(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	b, err := erlpack.Pack(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestReadRequest is used to test reading request bodies in each format.
+func TestReadRequest(t *testing.T) {
+	term := mustPack(t, map[string]interface{}{"name": "a", "count": 2})
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		err         bool
+	}{
+		{name: "etf", contentType: ContentType, body: term},
+		{name: "etf with parameters", contentType: ContentType + "; charset=binary", body: term},
+		{name: "sniffed etf", contentType: "application/octet-stream", body: term},
+		{name: "sniffed etf without content type", body: term},
+		{name: "json", contentType: "application/json", body: []byte(`{"name":"a","count":2}`)},
+		{name: "json without content type", body: []byte(`{"name":"a","count":2}`)},
+		{name: "etf content type with json", contentType: ContentType, body: []byte(`{"name":"a","count":2}`), err: true},
+		{name: "empty", contentType: ContentType, err: true},
+		{name: "truncated etf", body: term[:len(term)-1], err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			var body testBody
+			err := ReadRequest(r, &body)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body.Name != "a" || body.Count != 2 {
+				t.Fatalf("unexpected body: %+v", body)
+			}
+		})
+	}
+}
+
+// TestReadRequestMaxBodySize is used to test that bodies over the maximum size are refused.
+func TestReadRequestMaxBodySize(t *testing.T) {
+	term := mustPack(t, map[string]interface{}{"name": strings.Repeat("a", 100), "count": 2})
+	for _, body := range [][]byte{term, []byte(`{"name":"` + strings.Repeat("a", 100) + `"}`)} {
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		var out testBody
+		if err := ReadRequestWithOptions(r, &out, Options{MaxBodySize: 50}); !errors.Is(err, ErrBodyTooLarge) {
+			t.Fatal("expected ErrBodyTooLarge, got", err)
+		}
+
+		// The body should be read if it is exactly the maximum size.
+		r = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		if err := ReadRequestWithOptions(r, &out, Options{MaxBodySize: int64(len(body))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestNegotiate is used to test picking the media type of a response.
+func TestNegotiate(t *testing.T) {
+	tests := map[string]string{
+		"":                                       JSONContentType,
+		"*/*":                                    JSONContentType,
+		"application/*":                          JSONContentType,
+		"text/html":                              JSONContentType,
+		ContentType:                              ContentType,
+		"application/json, " + ContentType:       JSONContentType,
+		ContentType + ", application/json":       JSONContentType,
+		ContentType + ", */*;q=0.5":              ContentType,
+		"application/json;q=0.5, " + ContentType: ContentType,
+		ContentType + ";q=0, */*":                JSONContentType,
+		"application/*;q=0.2, " + ContentType + ";q=0.9": ContentType,
+		"invalid;;, " + ContentType:                      ContentType,
+	}
+	for accept, want := range tests {
+		if got := Negotiate(accept); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
+
+// TestHandler is used to test a handler which reads and writes bodies in both formats.
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body testBody
+		if err := ReadRequest(r, &body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body.Count++
+		if err := WriteResponse(w, r, http.StatusCreated, body); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	for _, accept := range []string{ContentType, "application/json"} {
+		req, err := http.NewRequest(http.MethodPost, srv.URL, bytes.NewReader(mustPack(t, testBody{Name: "a", Count: 1})))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", ContentType)
+		req.Header.Set("Accept", accept)
+		res, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusCreated {
+			t.Fatal("unexpected status:", res.StatusCode, string(b))
+		}
+		if res.Header.Get("Vary") != "Accept" {
+			t.Fatal("unexpected vary header:", res.Header.Get("Vary"))
+		}
+
+		var body testBody
+		if accept == ContentType {
+			if res.Header.Get("Content-Type") != ContentType {
+				t.Fatal("unexpected content type:", res.Header.Get("Content-Type"))
+			}
+			err = erlpack.Unpack(b, &body)
+		} else {
+			if !strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+				t.Fatal("unexpected content type:", res.Header.Get("Content-Type"))
+			}
+			err = json.Unmarshal(b, &body)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body.Name != "a" || body.Count != 2 {
+			t.Fatalf("unexpected body: %+v", body)
+		}
+	}
+}
+
+// TestWriteResponseError is used to test that nothing is written if the data can't be encoded.
+func TestWriteResponseError(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", ContentType)
+	w := httptest.NewRecorder()
+	if err := WriteResponse(w, r, http.StatusOK, make(chan int)); err == nil {
+		t.Fatal("expected error")
+	}
+	if w.Body.Len() != 0 || w.Header().Get("Content-Type") != "" {
+		t.Fatal("response was written")
+	}
+}
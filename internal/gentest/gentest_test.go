@@ -0,0 +1,163 @@
+package gentest
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// These have the same fields as the generated types but no methods, so they are packed and unpacked with reflection.
+type (
+	reflectiveUser     User
+	reflectiveMessage  Message
+	reflectivePresence Presence
+)
+
+func testUsers() []User {
+	flags := 64
+	return []User{
+		{Extra: erlpack.RawData("j")},
+		{
+			ID: 1, Name: "jake", Bot: true, Avatar: []byte{1, 2, 3}, Flags: &flags, Status: "online", Score: 1.5,
+			Password: "hunter2", Roles: []string{"admin", "mod"}, Extra: erlpack.RawData("a\x01"), Nickname: "j",
+			internal: 1,
+		},
+		{ID: -1, Name: "x", Avatar: []byte{}, Roles: []string{}, Status: "true", Extra: erlpack.RawData("w\x03nil")},
+		{ID: 1 << 40, Score: -2.25, Extra: erlpack.RawData("m\x00\x00\x00\x01a")},
+		{ID: 2, Name: "empty"},
+	}
+}
+
+func testMessages() []Message {
+	users := testUsers()
+	return []Message{
+		{Author: users[0]},
+		{ID: 1 << 40, Content: "hello", Author: users[1], Mentions: users, Embeds: map[string]string{"a": "b"}},
+	}
+}
+
+// TestMarshalMatchesPack is used to test that the generated code packs the same bytes as Pack.
+func TestMarshalMatchesPack(t *testing.T) {
+	check := func(generated erlpack.Marshaler, reflective interface{}) {
+		t.Helper()
+		b, err := generated.MarshalErlpack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := erlpack.Pack(reflective)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(append([]byte{131}, b...), expected) {
+			t.Fatalf("generated %v, reflection %v", b, expected[1:])
+		}
+
+		// Pack should use the generated code.
+		packed, err := erlpack.Pack(generated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(packed, expected) {
+			t.Fatalf("pack %v, reflection %v", packed, expected)
+		}
+	}
+	for _, u := range testUsers() {
+		check(u, reflectiveUser(u))
+	}
+	for _, m := range testMessages() {
+		check(m, reflectiveMessage(m))
+	}
+	game := "chess"
+	for _, p := range []Presence{{}, {UserID: 1, Kind: 1, Game: &game}} {
+		check(p, reflectivePresence(p))
+	}
+}
+
+// TestUnmarshalMatchesUnpack is used to test that the generated code unpacks the same values as Unpack.
+func TestUnmarshalMatchesUnpack(t *testing.T) {
+	inputs := [][]byte{
+		[]byte("\x83s\x03nil"),
+		[]byte("\x83a\x01"),
+		[]byte("\x83t\x00\x00\x00\x01s\x02ida\x01"),
+		[]byte("\x83t\x00\x00\x00\x02m\x00\x00\x00\x07unknownl\x00\x00\x00\x01a\x01jm\x00\x00\x00\x02ida\x02"),
+		[]byte("\x83t\x00\x00\x00\x01m\x00\x00\x00\x02idm\x00\x00\x00\x01a"),
+		[]byte("\x83t\x00\x00\x00\x01m\x00\x00\x00\x08usernames\x03nil"),
+	}
+	for _, u := range testUsers() {
+		b, err := erlpack.Pack(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, b)
+		b, err = erlpack.PackWithOptions(u, erlpack.EncoderOptions{Compress: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, b)
+	}
+	for _, m := range testMessages() {
+		b, err := erlpack.Pack(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, b)
+	}
+
+	for _, input := range inputs {
+		var generated User
+		var reflective reflectiveUser
+		generatedErr := erlpack.Unpack(input, &generated)
+		reflectiveErr := erlpack.Unpack(input, &reflective)
+		if (generatedErr == nil) != (reflectiveErr == nil) {
+			t.Fatalf("%q: generated error %v, reflection error %v", input, generatedErr, reflectiveErr)
+		}
+		if generatedErr == nil && !reflect.DeepEqual(generated, User(reflective)) {
+			t.Fatalf("%q: generated %#v, reflection %#v", input, generated, reflective)
+		}
+
+		var generatedMessage Message
+		var reflectiveMessage reflectiveMessage
+		generatedErr = erlpack.Unpack(input, &generatedMessage)
+		reflectiveErr = erlpack.Unpack(input, &reflectiveMessage)
+		if (generatedErr == nil) != (reflectiveErr == nil) {
+			t.Fatalf("%q: generated error %v, reflection error %v", input, generatedErr, reflectiveErr)
+		}
+		if generatedErr == nil && !reflect.DeepEqual(generatedMessage, Message(reflectiveMessage)) {
+			t.Fatalf("%q: generated %#v, reflection %#v", input, generatedMessage, reflectiveMessage)
+		}
+	}
+}
+
+// TestRoundTrip is used to test that values survive being packed and unpacked with the generated code.
+func TestRoundTrip(t *testing.T) {
+	for _, m := range testMessages() {
+		b, err := m.MarshalErlpack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result Message
+		if err = result.UnmarshalErlpack(b); err != nil {
+			t.Fatal(err)
+		}
+
+		// Fields which are not packed are lost, nil slices come back empty and empty raw data comes back as nil.
+		clean := func(u *User) {
+			u.Password, u.internal = "", 0
+			if u.Roles == nil {
+				u.Roles = []string{}
+			}
+			if len(u.Extra) == 0 {
+				u.Extra = erlpack.RawData("w\x03nil")
+			}
+		}
+		clean(&m.Author)
+		for i := range m.Mentions {
+			clean(&m.Mentions[i])
+		}
+		if !reflect.DeepEqual(m, result) {
+			t.Fatalf("expected %#v, got %#v", m, result)
+		}
+	}
+}
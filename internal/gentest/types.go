@@ -0,0 +1,56 @@
+// Package gentest contains types with methods generated by erlpackgen. It is used to test that the generated code
+// behaves the same as Pack and Unpack.
+package gentest
+
+import (
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+//go:generate go run ../../cmd/erlpackgen -type User,Message,Presence
+
+// Kind is used to define the kind of a user.
+type Kind int
+
+// String is used to get the name of the kind.
+func (k Kind) String() string {
+	switch k {
+	case 1:
+		return "bot"
+	case 2:
+		return "system"
+	default:
+		return "user"
+	}
+}
+
+// User is used to test a struct with each kind of field.
+type User struct {
+	ID       int             `erlpack:"id"`
+	Name     string          `erlpack:"username"`
+	Bot      bool            `erlpack:"bot,omitempty"`
+	Avatar   []byte          `erlpack:"avatar,omitempty"`
+	Flags    *int            `erlpack:"flags"`
+	Status   erlpack.Atom    `erlpack:"status"`
+	Score    float64         `erlpack:"score,omitempty"`
+	Password string          `erlpack:"-"`
+	Roles    []string        `erlpack:"roles"`
+	Extra    erlpack.RawData `erlpack:"extra"`
+	Nickname string
+	internal int
+}
+
+// Message is used to test a struct containing other structs with generated methods.
+type Message struct {
+	ID       uint64            `erlpack:"id"`
+	Content  string            `erlpack:"content"`
+	Author   User              `erlpack:"author"`
+	Mentions []User            `erlpack:"mentions,omitempty"`
+	Embeds   map[string]string `erlpack:"embeds,omitempty"`
+}
+
+// Presence is used to test the "string" option, which is only used while packing.
+type Presence struct {
+	UserID int     `erlpack:"user_id"`
+	Kind   Kind    `erlpack:"kind,string"`
+	Game   *string `erlpack:"game,omitempty"`
+}
@@ -0,0 +1,278 @@
+// Code generated by erlpackgen. DO NOT EDIT.
+
+package gentest
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// MarshalErlpack is used to pack User. Fields which aren't basic types, atoms or raw data use reflection.
+// Encoder options (including Marshalers) aren't given to this method, so they don't apply to the fields.
+func (x User) MarshalErlpack() ([]byte, error) {
+	var err error
+	b := erlpack.AppendMapHeader(make([]byte, 0, 64), 0)
+	n := uint32(0)
+
+	// ID
+	b = erlpack.AppendString(b, "id")
+	b = erlpack.AppendInt(b, int64(x.ID))
+	n++
+
+	// Name
+	b = erlpack.AppendString(b, "username")
+	b = erlpack.AppendString(b, x.Name)
+	n++
+
+	// Bot
+	if x.Bot {
+		b = erlpack.AppendString(b, "bot")
+		b = erlpack.AppendBool(b, x.Bot)
+		n++
+	}
+
+	// Avatar
+	if x.Avatar != nil {
+		b = erlpack.AppendString(b, "avatar")
+		b = erlpack.AppendBinary(b, x.Avatar)
+		n++
+	}
+
+	// Flags
+	b = erlpack.AppendString(b, "flags")
+	if b, err = erlpack.AppendValue(b, x.Flags); err != nil {
+		return nil, err
+	}
+	n++
+
+	// Status
+	b = erlpack.AppendString(b, "status")
+	b = erlpack.AppendAtom(b, x.Status)
+	n++
+
+	// Score
+	if x.Score != 0 {
+		b = erlpack.AppendString(b, "score")
+		if b, err = erlpack.AppendFloat(b, x.Score); err != nil {
+			return nil, err
+		}
+		n++
+	}
+
+	// Roles
+	b = erlpack.AppendString(b, "roles")
+	if b, err = erlpack.AppendValue(b, x.Roles); err != nil {
+		return nil, err
+	}
+	n++
+
+	// Extra
+	b = erlpack.AppendString(b, "extra")
+	if len(x.Extra) == 0 {
+		b = erlpack.AppendNil(b)
+	} else {
+		b = append(b, x.Extra...)
+	}
+	n++
+
+	// Nickname
+	b = erlpack.AppendString(b, "Nickname")
+	b = erlpack.AppendString(b, x.Nickname)
+	n++
+
+	binary.BigEndian.PutUint32(b[1:5], n)
+	return b, nil
+}
+
+// UnmarshalErlpack is used to unpack User. Fields which aren't basic types, atoms or raw data use reflection.
+// Decoder options aren't given to this method, so the fields are always unpacked with the defaults.
+func (x *User) UnmarshalErlpack(data erlpack.RawData) error {
+	*x = User{}
+	r := erlpack.NewReader(data)
+	l, err := r.ReadMapHeader()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < l; i++ {
+		key, err := r.ReadKey()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "id":
+			x.ID, err = r.ReadInt()
+		case "username":
+			x.Name, err = r.ReadString()
+		case "bot":
+			x.Bot, err = r.ReadBool()
+		case "avatar":
+			x.Avatar, err = r.ReadBinary()
+		case "flags":
+			err = r.ReadValue(&x.Flags)
+		case "status":
+			x.Status, err = r.ReadAtom()
+		case "score":
+			x.Score, err = r.ReadFloat()
+		case "roles":
+			err = r.ReadValue(&x.Roles)
+		case "extra":
+			x.Extra, err = r.ReadRaw()
+		case "Nickname":
+			x.Nickname, err = r.ReadString()
+		default:
+			err = r.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalErlpack is used to pack Message. Fields which aren't basic types, atoms or raw data use reflection.
+// Encoder options (including Marshalers) aren't given to this method, so they don't apply to the fields.
+func (x Message) MarshalErlpack() ([]byte, error) {
+	var err error
+	b := erlpack.AppendMapHeader(make([]byte, 0, 64), 0)
+	n := uint32(0)
+
+	// ID
+	b = erlpack.AppendString(b, "id")
+	if b, err = erlpack.AppendValue(b, x.ID); err != nil {
+		return nil, err
+	}
+	n++
+
+	// Content
+	b = erlpack.AppendString(b, "content")
+	b = erlpack.AppendString(b, x.Content)
+	n++
+
+	// Author
+	b = erlpack.AppendString(b, "author")
+	if b, err = erlpack.AppendValue(b, x.Author); err != nil {
+		return nil, err
+	}
+	n++
+
+	// Mentions
+	if !erlpack.IsZero(x.Mentions) {
+		b = erlpack.AppendString(b, "mentions")
+		if b, err = erlpack.AppendValue(b, x.Mentions); err != nil {
+			return nil, err
+		}
+		n++
+	}
+
+	// Embeds
+	if !erlpack.IsZero(x.Embeds) {
+		b = erlpack.AppendString(b, "embeds")
+		if b, err = erlpack.AppendValue(b, x.Embeds); err != nil {
+			return nil, err
+		}
+		n++
+	}
+
+	binary.BigEndian.PutUint32(b[1:5], n)
+	return b, nil
+}
+
+// UnmarshalErlpack is used to unpack Message. Fields which aren't basic types, atoms or raw data use reflection.
+// Decoder options aren't given to this method, so the fields are always unpacked with the defaults.
+func (x *Message) UnmarshalErlpack(data erlpack.RawData) error {
+	*x = Message{}
+	r := erlpack.NewReader(data)
+	l, err := r.ReadMapHeader()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < l; i++ {
+		key, err := r.ReadKey()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "id":
+			err = r.ReadValue(&x.ID)
+		case "content":
+			x.Content, err = r.ReadString()
+		case "author":
+			err = r.ReadValue(&x.Author)
+		case "mentions":
+			err = r.ReadValue(&x.Mentions)
+		case "embeds":
+			err = r.ReadValue(&x.Embeds)
+		default:
+			err = r.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalErlpack is used to pack Presence. Fields which aren't basic types, atoms or raw data use reflection.
+// Encoder options (including Marshalers) aren't given to this method, so they don't apply to the fields.
+func (x Presence) MarshalErlpack() ([]byte, error) {
+	var err error
+	b := erlpack.AppendMapHeader(make([]byte, 0, 64), 0)
+	n := uint32(0)
+
+	// UserID
+	b = erlpack.AppendString(b, "user_id")
+	b = erlpack.AppendInt(b, int64(x.UserID))
+	n++
+
+	// Kind
+	if s, ok := interface{}(x.Kind).(fmt.Stringer); ok {
+		b = erlpack.AppendString(b, "kind")
+		b = erlpack.AppendString(b, s.String())
+		n++
+	}
+
+	// Game
+	if !erlpack.IsZero(x.Game) {
+		b = erlpack.AppendString(b, "game")
+		if b, err = erlpack.AppendValue(b, x.Game); err != nil {
+			return nil, err
+		}
+		n++
+	}
+
+	binary.BigEndian.PutUint32(b[1:5], n)
+	return b, nil
+}
+
+// UnmarshalErlpack is used to unpack Presence. Fields which aren't basic types, atoms or raw data use reflection.
+// Decoder options aren't given to this method, so the fields are always unpacked with the defaults.
+func (x *Presence) UnmarshalErlpack(data erlpack.RawData) error {
+	*x = Presence{}
+	r := erlpack.NewReader(data)
+	l, err := r.ReadMapHeader()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < l; i++ {
+		key, err := r.ReadKey()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "user_id":
+			x.UserID, err = r.ReadInt()
+		case "kind":
+			err = r.ReadValue(&x.Kind)
+		case "game":
+			err = r.ReadValue(&x.Game)
+		default:
+			err = r.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
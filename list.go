@@ -0,0 +1,30 @@
+package erlpack
+
+// ImproperList is used to define a list which does not end with a blank list, such as [1, 2 | 3].
+type ImproperList struct {
+	// Items is the items within the list.
+	Items []interface{}
+
+	// Tail is the term at the end of the list.
+	Tail interface{}
+}
+
+// Used to get the term for a list with the tail specified. A list tail adds its items to the list.
+func listWithTail(items []interface{}, tail interface{}) interface{} {
+	switch x := tail.(type) {
+	case []interface{}:
+		return append(items, x...)
+	case ImproperList:
+		return ImproperList{Items: append(items, x.Items...), Tail: x.Tail}
+	default:
+		return ImproperList{Items: items, Tail: tail}
+	}
+}
+
+// Used to get the items and tail of a proper or improper list.
+func listCells(Term interface{}) ([]interface{}, interface{}) {
+	if x, ok := Term.(ImproperList); ok {
+		return x.Items, x.Tail
+	}
+	return Term.([]interface{}), []interface{}{}
+}
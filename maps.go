@@ -0,0 +1,136 @@
+package erlpack
+
+import (
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+// MapKey is used to define a map key which can not be used as a Go map key (such as a list or tuple).
+// It contains the canonical encoding of the original key term, so equal terms will always result in the same MapKey.
+type MapKey string
+
+// Term is used to get the original key term.
+func (k MapKey) Term() (interface{}, error) {
+	var x interface{}
+	err := RawData(k).Cast(&x)
+	return x, err
+}
+
+// HashableKey is used to get the key a term will have when a map is unpacked into a map[interface{}]interface{}.
+// Binaries are turned into strings, and any term which can not be used as a Go map key is turned into a MapKey.
+func HashableKey(Term interface{}) (interface{}, error) {
+	switch x := Term.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return string(x), nil
+	case *big.Int:
+		return canonicalKey(x)
+	}
+	if reflect.TypeOf(Term).Comparable() {
+		return Term, nil
+	}
+	return canonicalKey(Term)
+}
+
+// Used to get the canonical encoding of a term.
+func canonicalKey(Term interface{}) (MapKey, error) {
+	b, err := PackWithOptions(Term, EncoderOptions{Deterministic: true})
+	if err != nil {
+		return "", err
+	}
+	return MapKey(b[1:]), nil
+}
+
+// MapItem is used to define a key and value within a OrderedMap.
+type MapItem struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// OrderedMap is used to define a map which keeps the original key terms in the order they were unpacked.
+// Unlike a Go map, any term can be used as a key.
+type OrderedMap []MapItem
+
+// Get is used to get the value for the key specified.
+// Keys are compared by their canonical encoding, so a key will match any equal term (for example, uint8(1) matches 1).
+func (o OrderedMap) Get(Key interface{}) (interface{}, bool) {
+	k, err := canonicalKey(Key)
+	if err != nil {
+		return nil, false
+	}
+	for _, v := range o {
+		if x, err := canonicalKey(v.Key); err == nil && x == k {
+			return v.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Used to turn a generic map into a ordered map. Since a Go map has no order, the items are sorted by their canonical key.
+func orderedMapFromMap(m map[interface{}]interface{}) (OrderedMap, error) {
+	o := make(OrderedMap, 0, len(m))
+	keys := make([]MapKey, 0, len(m))
+	for k, v := range m {
+		// Turn the key back into the original term.
+		if x, ok := k.(MapKey); ok {
+			term, err := x.Term()
+			if err != nil {
+				return nil, err
+			}
+			k = term
+		}
+		canonical, err := canonicalKey(k)
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, MapItem{Key: k, Value: v})
+		keys = append(keys, canonical)
+	}
+	sort.Sort(&orderedMapSorter{o: o, keys: keys})
+	return o, nil
+}
+
+// Used to sort a ordered map by the canonical keys.
+type orderedMapSorter struct {
+	o    OrderedMap
+	keys []MapKey
+}
+
+func (s *orderedMapSorter) Len() int           { return len(s.o) }
+func (s *orderedMapSorter) Less(i, j int) bool { return s.keys[i] < s.keys[j] }
+func (s *orderedMapSorter) Swap(i, j int) {
+	s.o[i], s.o[j] = s.o[j], s.o[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+// Used to pack the items of a map sorted by their keys.
+func packSortedMap(rt reflect.Value, keys []reflect.Value, pad *scratchpad, opts *EncoderOptions, handler func(interface{}) error) error {
+	// Encode each key.
+	keyOpts := *opts
+	keyOpts.Compress = false
+	encoded := make([][]byte, len(keys))
+	for i, k := range keys {
+		b, err := PackWithOptions(k.Interface(), keyOpts)
+		if err != nil {
+			return err
+		}
+		encoded[i] = b[1:]
+	}
+
+	// Sort the keys.
+	order, err := sortEncodedKeys(encoded)
+	if err != nil {
+		return err
+	}
+
+	// Pack each item.
+	for _, i := range order {
+		pad.endAppend(encoded[i]...)
+		if err := handler(rt.MapIndex(keys[i]).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package erlpack
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// mapWithComplexKeys is #{{a, 1} => x, [1, 2] => y, <<"b">> => z}.
+const mapWithComplexKeys = "\x83t\x00\x00\x00\x03" +
+	"h\x02w\x01aa\x01" + "w\x01x" +
+	"l\x00\x00\x00\x02a\x01a\x02j" + "w\x01y" +
+	"m\x00\x00\x00\x01b" + "w\x01z"
+
+// TestUnpackComplexMapKeys is used to test unpacking a map with keys which are not hashable in Go.
+func TestUnpackComplexMapKeys(t *testing.T) {
+	var m map[interface{}]interface{}
+	err := Unpack([]byte(mapWithComplexKeys), &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 3 {
+		t.Fatal("unexpected length:", len(m))
+	}
+	for term, expected := range map[string]interface{}{"x": Tuple{Atom("a"), 1}, "y": []int{1, 2}, "z": []byte("b")} {
+		k, err := HashableKey(expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m[k] != Atom(term) {
+			t.Fatal("unexpected result for key", expected, ":", m[k])
+		}
+	}
+
+	// Make sure the original key term can be retrieved.
+	k, err := HashableKey(Tuple{Atom("a"), 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	term, err := k.(MapKey).Term()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(term, Tuple{Atom("a"), uint8(1)}) {
+		t.Fatal("unexpected term:", term)
+	}
+}
+
+// Used to make a map nested within the key of another map the number of times specified.
+func nestedMapKeys(n int) []byte {
+	b := []byte{131}
+	for i := 0; i < n; i++ {
+		b = append(b, 't', 0, 0, 0, 1, 'h', 1)
+	}
+	b = append(b, 'j')
+	for i := 0; i < n; i++ {
+		b = append(b, 'a', 1)
+	}
+	return b
+}
+
+// TestUnpackNestedMapKeys is used to test that map keys nested within map keys are limited.
+func TestUnpackNestedMapKeys(t *testing.T) {
+	var x interface{}
+	if err := Unpack(nestedMapKeys(maxKeyDepth), &x); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unpack(nestedMapKeys(maxKeyDepth+1), &x); err == nil {
+		t.Fatal("expected error for keys nested too deeply")
+	}
+
+	// Each key is packed again, so this used to take tens of seconds.
+	start := time.Now()
+	if err := Unpack(nestedMapKeys(maxDepth/2-1), &x); err == nil {
+		t.Fatal("expected error for keys nested too deeply")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatal("unpacking took", d)
+	}
+}
+
+// TestUnpackOrderedMap is used to test unpacking a map into a OrderedMap.
+func TestUnpackOrderedMap(t *testing.T) {
+	var o OrderedMap
+	err := Unpack([]byte(mapWithComplexKeys), &o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := OrderedMap{
+		{Key: Tuple{Atom("a"), uint8(1)}, Value: Atom("x")},
+		{Key: []interface{}{uint8(1), uint8(2)}, Value: Atom("y")},
+		{Key: []byte("b"), Value: Atom("z")},
+	}
+	if !reflect.DeepEqual(o, expected) {
+		t.Fatal("unexpected result:", o)
+	}
+	v, ok := o.Get([]int{1, 2})
+	if !ok || v != Atom("y") {
+		t.Fatal("unexpected result:", v)
+	}
+
+	// Packing the ordered map should give the same bytes.
+	b, err := Pack(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bytesAssert([]byte(mapWithComplexKeys), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestUncastedResultOrderedMap is used to test casting a generic map into a OrderedMap.
+func TestUncastedResultOrderedMap(t *testing.T) {
+	var u UncastedResult
+	err := Unpack([]byte(mapWithComplexKeys), &u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var o OrderedMap
+	err = u.Cast(&o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o) != 3 {
+		t.Fatal("unexpected length:", len(o))
+	}
+	v, ok := o.Get(Tuple{Atom("a"), 1})
+	if !ok || v != Atom("x") {
+		t.Fatal("unexpected result:", v)
+	}
+}
+
+// TestUnpackNestedList is used to test that list tails are consumed within a map.
+func TestUnpackNestedList(t *testing.T) {
+	var m map[string][]int
+	err := Unpack([]byte("\x83t\x00\x00\x00\x02m\x00\x00\x00\x01al\x00\x00\x00\x01a\x01jm\x00\x00\x00\x01bj"), &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m["a"]) != 1 || m["a"][0] != 1 || len(m["b"]) != 0 {
+		t.Fatal("unexpected result:", m)
+	}
+}
@@ -0,0 +1,112 @@
+package erlpack
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+)
+
+// Marshaler is used to define a type which can pack itself. MarshalErlpack should return a single term without the
+// version byte. The bytes are written as-is, so the encoder options are not applied to them.
+type Marshaler interface {
+	MarshalErlpack() ([]byte, error)
+}
+
+// Unmarshaler is used to define a type which can unpack itself. UnmarshalErlpack is given a single term without the
+// version byte (compressed terms are inflated first).
+type Unmarshaler interface {
+	UnmarshalErlpack(RawData) error
+}
+
+// AppendNil is used to append a nil atom to the bytes.
+func AppendNil(b []byte) []byte {
+	return append(b, 'w', 3, 'n', 'i', 'l')
+}
+
+// AppendBool is used to append a boolean atom to the bytes.
+func AppendBool(b []byte, Data bool) []byte {
+	if Data {
+		return append(b, 'w', 4, 't', 'r', 'u', 'e')
+	}
+	return append(b, 'w', 5, 'f', 'a', 'l', 's', 'e')
+}
+
+// AppendAtom is used to append a atom to the bytes.
+func AppendAtom(b []byte, Data Atom) []byte {
+	if len(Data) > 255 {
+		b = append(b, 'v', byte(len(Data)>>8), byte(len(Data)))
+	} else {
+		b = append(b, 'w', byte(len(Data)))
+	}
+	return append(b, Data...)
+}
+
+// AppendString is used to append a string to the bytes as a binary.
+func AppendString(b []byte, Data string) []byte {
+	b = AppendBinaryHeader(b, uint32(len(Data)))
+	return append(b, Data...)
+}
+
+// AppendBinary is used to append a binary to the bytes.
+func AppendBinary(b []byte, Data []byte) []byte {
+	b = AppendBinaryHeader(b, uint32(len(Data)))
+	return append(b, Data...)
+}
+
+// AppendBinaryHeader is used to append the header of a binary with the length specified to the bytes.
+func AppendBinaryHeader(b []byte, l uint32) []byte {
+	b = append(b, 'm', 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[len(b)-4:], l)
+	return b
+}
+
+// AppendMapHeader is used to append the header of a map with the number of pairs specified to the bytes.
+func AppendMapHeader(b []byte, l uint32) []byte {
+	b = append(b, 't', 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[len(b)-4:], l)
+	return b
+}
+
+// AppendInt is used to append a integer to the bytes using the smallest encoding which fits it.
+func AppendInt(b []byte, Data int64) []byte {
+	if Data >= 0 && Data <= 255 {
+		return append(b, 'a', byte(Data))
+	}
+	if Data >= math.MinInt32 && Data <= math.MaxInt32 {
+		b = append(b, 'b', 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(b[len(b)-4:], uint32(Data))
+		return b
+	}
+	pad := newScratchpad(11)
+	packInt64(Data, pad)
+	return append(b, pad.bytes()...)
+}
+
+// AppendFloat is used to append a float to the bytes. Like Pack, this returns a *NonFiniteFloatError for NaN and
+// infinity.
+func AppendFloat(b []byte, Data float64) ([]byte, error) {
+	if math.IsNaN(Data) || math.IsInf(Data, 0) {
+		return b, &NonFiniteFloatError{Value: Data}
+	}
+	b = append(b, 'F', 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(b[len(b)-8:], math.Float64bits(Data))
+	return b, nil
+}
+
+// AppendValue is used to pack any value and append it to the bytes.
+func AppendValue(b []byte, Data interface{}) ([]byte, error) {
+	packed, err := Pack(Data)
+	if err != nil {
+		return b, err
+	}
+	return append(b, packed[1:]...), nil
+}
+
+// IsZero is used to check if a value is empty in the same way as the "omitempty" option.
+func IsZero(Data interface{}) bool {
+	v := reflect.ValueOf(Data)
+	if !v.IsValid() {
+		return true
+	}
+	return reflect.DeepEqual(Data, reflect.Zero(v.Type()).Interface())
+}
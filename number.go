@@ -0,0 +1,82 @@
+package erlpack
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Number is used to define a integer or float which was unpacked into a interface{} with DecoderOptions.UseNumber set.
+// It contains the number in decimal, and floats always contain a decimal point or exponent so they can be told apart
+// from integers. Like json.Number, this is written as a number when it is marshalled to JSON.
+type Number string
+
+// Used to make a Number from a unpacked integer or float.
+func numberFromTerm(Term interface{}) Number {
+	if f, ok := Term.(float64); ok {
+		s := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eEn") {
+			// Add a decimal point so the number is still a float (the n is from NaN and infinities).
+			s += ".0"
+		}
+		return Number(s)
+	}
+	return Number(integerText(Term))
+}
+
+// String is used to get the number as a string.
+func (n Number) String() string {
+	return string(n)
+}
+
+// IsFloat is used to check if the number is a float.
+func (n Number) IsFloat() bool {
+	return strings.ContainsAny(string(n), ".eEn")
+}
+
+// Int64 is used to get the number as a int64.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// Uint64 is used to get the number as a uint64.
+func (n Number) Uint64() (uint64, error) {
+	return strconv.ParseUint(string(n), 10, 64)
+}
+
+// Float64 is used to get the number as a float64.
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// BigInt is used to get the number as a *big.Int.
+func (n Number) BigInt() (*big.Int, error) {
+	b, ok := new(big.Int).SetString(string(n), 10)
+	if !ok {
+		return nil, errors.New("number is not an integer")
+	}
+	return b, nil
+}
+
+// MarshalJSON is used to write the number as a JSON number.
+func (n Number) MarshalJSON() ([]byte, error) {
+	if n == "" {
+		return []byte("0"), nil
+	}
+	return []byte(n), nil
+}
+
+// Used to get the term the number was unpacked from. Integers are returned as the same types Unpack uses.
+func (n Number) term() (interface{}, error) {
+	if n.IsFloat() {
+		return n.Float64()
+	}
+	if i, err := n.Int64(); err == nil {
+		return i, nil
+	}
+	if u, err := n.Uint64(); err == nil {
+		return u, nil
+	}
+	return n.BigInt()
+}
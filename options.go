@@ -0,0 +1,86 @@
+package erlpack
+
+import "reflect"
+
+// EncoderOptions is used to define options which change how data is packed.
+// The zero value is the default behaviour used by Pack.
+type EncoderOptions struct {
+	// LegacyFloats is used to pack floats as FLOAT_EXT (a 31 byte string) rather than NEW_FLOAT_EXT.
+	// This is required for peers which only understand minor_version 0.
+	LegacyFloats bool
+
+	// NonFiniteFloatsAsAtoms is used to pack NaN and infinities as the atoms nan, infinity and neg_infinity.
+	// By default, a *NonFiniteFloatError is returned since Erlang can not represent them.
+	NonFiniteFloatsAsAtoms bool
+
+	// Compress is used to compress terms with zlib (the same as term_to_binary(Term, [compressed])).
+	// The term is only compressed if it is at least CompressionThreshold bytes and compressing makes it smaller.
+	Compress bool
+
+	// CompressionLevel is the zlib compression level to use. If this is 0, zlib.DefaultCompression is used.
+	CompressionLevel int
+
+	// CompressionThreshold is the minimum size in bytes an uncompressed term has to be before it is compressed.
+	CompressionThreshold int
+
+	// Deterministic is used to make sure the same value always packs to the same bytes by sorting map keys using the
+	// Erlang map key order (the term order, but with all integers before all floats). This matches
+	// term_to_binary(Term, [deterministic]) for everything but lists of small integers, which are not packed as
+	// STRING_EXT.
+	Deterministic bool
+
+	// Marshalers is used to change how values of the types specified (and pointers to them) are packed by this encoder.
+	// The function is given the value and returns its term without the version byte. This is used instead of Marshaler
+	// if a type has both.
+	Marshalers map[reflect.Type]func(interface{}) ([]byte, error)
+}
+
+// DecoderOptions is used to define options which change how data is unpacked.
+// The zero value is the default behaviour used by Unpack and UnpackReader.
+type DecoderOptions struct {
+	// DisallowFuns is used to refuse any funs (NEW_FUN_EXT and EXPORT_EXT) with an error.
+	// This should be set when unpacking data from an untrusted source.
+	DisallowFuns bool
+
+	// AtomsAsNonFiniteFloats is used to unpack the atoms nan, infinity and neg_infinity into float targets as NaN and
+	// infinities. This is the reverse of EncoderOptions.NonFiniteFloatsAsAtoms.
+	AtomsAsNonFiniteFloats bool
+
+	// BinariesAsStrings is used to unpack binaries into a interface{} as a string rather than a []byte.
+	BinariesAsStrings bool
+
+	// AtomsAsStrings is used to unpack atoms into a interface{} as a string rather than a Atom. The atoms true, false
+	// and nil are still unpacked as a bool or nil.
+	AtomsAsStrings bool
+
+	// MapsAsStringKeyed is used to unpack maps into a interface{} as a map[string]interface{} rather than a
+	// map[interface{}]interface{}. Binary keys are turned into strings (as are atom keys if AtomsAsStrings is set), and
+	// any other key returns an error.
+	MapsAsStringKeyed bool
+
+	// IntegersAsInt64 is used to unpack integers into a interface{} as a int64, no matter how they were packed.
+	// Integers which don't fit in a int64 are still unpacked as a uint64 or *big.Int.
+	IntegersAsInt64 bool
+
+	// UseNumber is used to unpack integers and floats into a interface{} as a Number. This takes priority over
+	// IntegersAsInt64.
+	UseNumber bool
+
+	// MaxInflatedSize is the largest a compressed term can be once it is inflated. The uncompressed size is checked
+	// before anything is inflated. If this is 0, DefaultMaxInflatedSize is used.
+	MaxInflatedSize int
+
+	// AtomCacheRefs is used to resolve ATOM_CACHE_REF, which refers to a atom by its index in this list. This is only
+	// used by the distribution protocol, where the list comes from the distribution header of the message. Atoms are
+	// resolved everywhere, including inside funs and raw data.
+	AtomCacheRefs []Atom
+
+	// Used internally to unpack all maps as a OrderedMap when comparing terms.
+	orderedMaps bool
+
+	// Used internally to track how deeply nested the data being unpacked is.
+	depth int
+
+	// Used internally to track how many map keys the data being unpacked is within.
+	keyDepth int
+}
@@ -0,0 +1,318 @@
+package erlpack
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Defines the order of each type of term (number < atom < reference < fun < port < pid < tuple < map < nil < list < bitstring).
+const (
+	orderNumber = iota
+	orderAtom
+	orderReference
+	orderFun
+	orderPort
+	orderPid
+	orderTuple
+	orderMap
+	orderNil
+	orderList
+	orderBinary
+)
+
+// Used to get the order of the type of a unpacked term.
+func termOrder(Term interface{}) int {
+	switch x := Term.(type) {
+	case uint8, int32, int64, uint64, float64, *big.Int:
+		return orderNumber
+	case Atom, bool, nil:
+		return orderAtom
+	case Reference:
+		return orderReference
+	case Fun, Export:
+		return orderFun
+	case Port:
+		return orderPort
+	case Pid:
+		return orderPid
+	case Tuple:
+		return orderTuple
+	case OrderedMap:
+		return orderMap
+	case []interface{}:
+		if len(x) == 0 {
+			return orderNil
+		}
+		return orderList
+	case ImproperList:
+		return orderList
+	default:
+		return orderBinary
+	}
+}
+
+// Used to get the text of a atom.
+func atomText(Term interface{}) string {
+	switch x := Term.(type) {
+	case Atom:
+		return string(x)
+	case bool:
+		if x {
+			return "true"
+		}
+		return "false"
+	default:
+		return "nil"
+	}
+}
+
+// Used to compare 2 numbers. If they are equal, integers are ordered before floats. With the map key order, all
+// integers are ordered before all floats.
+func compareNumbers(a, b interface{}, KeyOrder bool) int {
+	toFloat := func(x interface{}) (float64, bool) {
+		switch n := x.(type) {
+		case uint8:
+			return float64(n), false
+		case int32:
+			return float64(n), false
+		case int64:
+			return float64(n), false
+		case uint64:
+			return float64(n), false
+		case *big.Int:
+			f, _ := new(big.Float).SetInt(n).Float64()
+			return f, false
+		default:
+			return n.(float64), true
+		}
+	}
+	toInt := func(x interface{}) *big.Int {
+		switch n := x.(type) {
+		case uint8:
+			return big.NewInt(int64(n))
+		case int32:
+			return big.NewInt(int64(n))
+		case uint64:
+			return new(big.Int).SetUint64(n)
+		case *big.Int:
+			return n
+		default:
+			return big.NewInt(x.(int64))
+		}
+	}
+	af, aFloat := toFloat(a)
+	bf, bFloat := toFloat(b)
+	if KeyOrder && aFloat != bFloat {
+		if aFloat {
+			return 1
+		}
+		return -1
+	}
+	if !aFloat && !bFloat {
+		// Compare as integers so large values are exact.
+		return toInt(a).Cmp(toInt(b))
+	}
+	if af < bf {
+		return -1
+	} else if af > bf {
+		return 1
+	}
+	if aFloat == bFloat {
+		return 0
+	}
+	if aFloat {
+		return 1
+	}
+	return -1
+}
+
+// Used to compare 2 unpacked terms using the Erlang term order.
+func compareTerms(a, b interface{}) int {
+	return compare(a, b, false)
+}
+
+// Used to compare 2 unpacked map keys using the map key order, which is the term order apart from all integers being
+// ordered before all floats. This is the order term_to_binary(Term, [deterministic]) packs map keys in.
+func compareKeys(a, b interface{}) int {
+	return compare(a, b, true)
+}
+
+// Used to compare 2 unpacked terms using the term order or the map key order.
+func compare(a, b interface{}, KeyOrder bool) int {
+	// Compare the type order first.
+	ao, bo := termOrder(a), termOrder(b)
+	if ao != bo {
+		if ao < bo {
+			return -1
+		}
+		return 1
+	}
+
+	// Compare the values.
+	switch ao {
+	case orderNumber:
+		return compareNumbers(a, b, KeyOrder)
+	case orderAtom:
+		return strings.Compare(atomText(a), atomText(b))
+	case orderTuple:
+		x, y := a.(Tuple), b.(Tuple)
+		if len(x) != len(y) {
+			return compareLengths(len(x), len(y))
+		}
+		return compareSlices(x, y, KeyOrder)
+	case orderMap:
+		// Maps are compared by size, then by their keys in map key order, and then by the values of those keys.
+		x, y := a.(OrderedMap), b.(OrderedMap)
+		if len(x) != len(y) {
+			return compareLengths(len(x), len(y))
+		}
+		x, y = sortedMapItems(x), sortedMapItems(y)
+		for i := range x {
+			if c := compareKeys(x[i].Key, y[i].Key); c != 0 {
+				return c
+			}
+		}
+		for i := range x {
+			if c := compare(x[i].Value, y[i].Value, KeyOrder); c != 0 {
+				return c
+			}
+		}
+		return 0
+	case orderNil:
+		return 0
+	case orderList:
+		// Lists are compared one cell at a time, so the tail of a improper list is compared with the rest of the other list.
+		x, xTail := listCells(a)
+		y, yTail := listCells(b)
+		if c := compareSlices(x, y, KeyOrder); c != 0 {
+			return c
+		}
+		rest := func(items []interface{}, tail interface{}, i int) interface{} {
+			if i == len(items) {
+				return tail
+			}
+			return listWithTail(append([]interface{}{}, items[i:]...), tail)
+		}
+		l := len(x)
+		if len(y) < l {
+			l = len(y)
+		}
+		return compare(rest(x, xTail, l), rest(y, yTail, l), KeyOrder)
+	case orderFun:
+		return bytes.Compare(funBytes(a), funBytes(b))
+	case orderReference, orderPort, orderPid:
+		return compareIdentifiers(a, b)
+	default:
+		return bytes.Compare(binaryBytes(a), binaryBytes(b))
+	}
+}
+
+// Used to compare 2 lengths.
+func compareLengths(a, b int) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// Used to compare the items 2 slices have in common.
+func compareSlices(a, b []interface{}, KeyOrder bool) int {
+	l := len(a)
+	if len(b) < l {
+		l = len(b)
+	}
+	for i := 0; i < l; i++ {
+		if c := compare(a[i], b[i], KeyOrder); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// Used to get the items of a map sorted by their keys in map key order.
+func sortedMapItems(m OrderedMap) OrderedMap {
+	sorted := append(OrderedMap(nil), m...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return compareKeys(sorted[i].Key, sorted[j].Key) < 0
+	})
+	return sorted
+}
+
+// Used to get the bytes of a fun for comparison.
+func funBytes(Term interface{}) []byte {
+	if f, ok := Term.(Fun); ok {
+		return f.raw
+	}
+	e := Term.(Export)
+	return []byte(fmt.Sprintf("%s:%s/%d", e.Module, e.Function, e.Arity))
+}
+
+// Used to compare 2 identifiers of the same type by node, and then by their numbers.
+func compareIdentifiers(a, b interface{}) int {
+	var nodes [2]Atom
+	var numbers [2][]uint64
+	for i, x := range [2]interface{}{a, b} {
+		switch v := x.(type) {
+		case Pid:
+			nodes[i], numbers[i] = v.Node, []uint64{uint64(v.Creation), uint64(v.Serial), uint64(v.ID)}
+		case Port:
+			nodes[i], numbers[i] = v.Node, []uint64{uint64(v.Creation), v.ID}
+		case Reference:
+			nodes[i], numbers[i] = v.Node, []uint64{uint64(v.Creation), uint64(len(v.ID))}
+			for j := len(v.ID) - 1; j >= 0; j-- {
+				numbers[i] = append(numbers[i], uint64(v.ID[j]))
+			}
+		}
+	}
+	if c := strings.Compare(string(nodes[0]), string(nodes[1])); c != 0 {
+		return c
+	}
+	for i := 0; i < len(numbers[0]) && i < len(numbers[1]); i++ {
+		if numbers[0][i] != numbers[1][i] {
+			if numbers[0][i] < numbers[1][i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return compareLengths(len(numbers[0]), len(numbers[1]))
+}
+
+// Used to get the bytes of a binary for comparison.
+func binaryBytes(Term interface{}) []byte {
+	switch x := Term.(type) {
+	case []byte:
+		return x
+	case string:
+		return []byte(x)
+	default:
+		return nil
+	}
+}
+
+// Used to sort encoded map keys (without the version byte) using the map key order.
+// The result is the indexes of the keys in order.
+func sortEncodedKeys(encoded [][]byte) ([]int, error) {
+	// Unpack each term.
+	terms := make([]interface{}, len(encoded))
+	for i, b := range encoded {
+		if err := RawData(b).CastWithOptions(&terms[i], DecoderOptions{orderedMaps: true}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sort the indexes.
+	order := make([]int, len(encoded))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return compareKeys(terms[order[a]], terms[order[b]]) < 0
+	})
+	return order, nil
+}
@@ -1,12 +1,17 @@
 package erlpack
 
 import (
+	"compress/zlib"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/jakemakesstuff/structs"
+	"math"
+	"math/big"
 	"reflect"
+	"strconv"
+	"strings"
 	"unsafe"
 )
 
@@ -71,30 +76,102 @@ func packString(Data string, pad *scratchpad) {
 	pad.endAppend([]byte(Data)...)
 }
 
+// packAtom is used to pack a atom.
+func packAtom(Data Atom, pad *scratchpad) {
+	if len(Data) > 255 {
+		pad.endAppend('v', byte(len(Data)>>8), byte(len(Data)))
+	} else {
+		pad.endAppend('w', byte(len(Data)))
+	}
+	pad.endAppend([]byte(Data)...)
+}
+
+// Defines a struct field which should be packed.
+type packedField struct {
+	name  string
+	value interface{}
+}
+
+// packedStructFields is used to get the fields of a struct which should be packed in the order they were declared.
+// This follows the same tag rules as structs.Map ("-", "omitempty", "string" and "flatten").
+func packedStructFields(i interface{}) []packedField {
+	// Create a struct parser.
+	s := structs.New(i)
+	s.TagName = "erlpack"
+
+	// Go through each field.
+	fields := []packedField{}
+	for _, field := range s.Fields() {
+		if !field.IsExported() {
+			continue
+		}
+
+		// Get the name and options.
+		tag := strings.Split(field.Tag("erlpack"), ",")
+		name := tag[0]
+		if name == "" {
+			name = field.Name()
+		}
+		hasOption := func(option string) bool {
+			for _, v := range tag[1:] {
+				if v == option {
+					return true
+				}
+			}
+			return false
+		}
+
+		// Handle the options.
+		value := field.Value()
+		if hasOption("omitempty") && field.IsZero() {
+			continue
+		}
+		if hasOption("string") {
+			if s, ok := value.(fmt.Stringer); ok {
+				fields = append(fields, packedField{name: name, value: s.String()})
+			}
+			continue
+		}
+		if hasOption("flatten") {
+			v := reflect.ValueOf(value)
+			if v.Kind() == reflect.Ptr && !v.IsNil() {
+				v = v.Elem()
+			}
+			if v.Kind() == reflect.Struct {
+				fields = append(fields, packedStructFields(v.Interface())...)
+				continue
+			}
+		}
+		fields = append(fields, packedField{name: name, value: value})
+	}
+	return fields
+}
+
 // packNil is used to pack a nil.
 func packNil(pad *scratchpad) {
-	pad.endAppend('s', 3, 'n', 'i', 'l')
+	pad.endAppend('w', 3, 'n', 'i', 'l')
 }
 
 // packInt64 is used to pack a 64-bit integer.
 func packInt64(Data int64, pad *scratchpad) {
+	if 0 > Data {
+		packSmallBig(uint64(Data*-1), true, pad)
+	} else {
+		packSmallBig(uint64(Data), false, pad)
+	}
+}
+
+// packSmallBig is used to pack the absolute value of a integer and its sign.
+func packSmallBig(ull uint64, negative bool, pad *scratchpad) {
 	// Create the initial allocation and define the header.
 	a := make([]byte, 11)
 	a[0] = 'n'
 
 	// Define the int signature.
-	if 0 > Data {
+	if negative {
 		a[2] = 1
 	}
 
-	// Create the unsigned int64.
-	var ull uint64
-	if 0 > Data {
-		ull = uint64(Data * -1)
-	} else {
-		ull = uint64(Data)
-	}
-
 	// Defines how many bytes were encoded.
 	BytesEnc := 0
 
@@ -109,27 +186,49 @@ func packInt64(Data int64, pad *scratchpad) {
 	a[1] = byte(BytesEnc)
 
 	// Append the data.
-	pad.endAppend(a...)
+	pad.endAppend(a[:3+BytesEnc]...)
 }
 
-// packInt is used to pack a int.
-func packInt(Data int, pad *scratchpad) {
-	if Data < 256 && Data > 0 {
-		// We can pack as a small int.
+// packInteger is used to pack a integer using the smallest encoding possible (the same as term_to_binary).
+func packInteger(Data int64, pad *scratchpad) {
+	if Data >= 0 && Data <= 255 {
 		pad.endAppend('a', byte(Data))
-	} else if 2147483647 > Data && Data > -2147483647 {
-		// We should pack as a standard int.
+	} else if Data >= math.MinInt32 && Data <= math.MaxInt32 {
 		a := make([]byte, 5)
 		a[0] = 'b'
-		i32 := int32(Data)
-		ntohl32(*(*uint32)(unsafe.Pointer(&i32)), a, 1)
+		ntohl32(uint32(Data), a, 1)
 		pad.endAppend(a...)
 	} else {
-		// Call packInt64 (this will only ever get here on a 64-bit system).
-		packInt64(int64(Data), pad)
+		packInt64(Data, pad)
+	}
+}
+
+// packTupleHeader is used to pack the tuple header.
+func packTupleHeader(pad *scratchpad, l uint32) {
+	if l < 256 {
+		pad.endAppend('h', byte(l))
+	} else {
+		a := make([]byte, 5)
+		a[0] = 'i'
+		ntohl32(l, a, 1)
+		pad.endAppend(a...)
 	}
 }
 
+// packBinary is used to pack a binary.
+func packBinary(Data []byte, pad *scratchpad) {
+	// Create the initial allocation and define the header.
+	a := make([]byte, 5)
+	a[0] = 'm'
+
+	// Write the length.
+	ntohl32(uint32(len(Data)), a, 1)
+
+	// Append the header and data.
+	pad.endAppend(a...)
+	pad.endAppend(Data...)
+}
+
 // packFloat64 is used to pack a 64-bit floating point number.
 func packFloat64(Data float64, pad *scratchpad) {
 	// Allocate the bytes.
@@ -148,38 +247,191 @@ func packFloat64(Data float64, pad *scratchpad) {
 	pad.endAppend(a...)
 }
 
+// packLegacyFloat64 is used to pack a 64-bit floating point number as a null padded string.
+func packLegacyFloat64(Data float64, pad *scratchpad) {
+	// Allocate the bytes.
+	a := make([]byte, 32)
+
+	// Set the header.
+	a[0] = 'c'
+
+	// Write the string (the remainder is null padding).
+	copy(a[1:], strconv.FormatFloat(Data, 'e', 20, 64))
+
+	// Write to the pad.
+	pad.endAppend(a...)
+}
+
 // packBool is used to pack a boolean.
 func packBool(Data bool, pad *scratchpad) {
 	if Data {
-		pad.endAppend('s', 4, 't', 'r', 'u', 'e')
+		pad.endAppend('w', 4, 't', 'r', 'u', 'e')
 	} else {
-		pad.endAppend('s', 5, 'f', 'a', 'l', 's', 'e')
+		pad.endAppend('w', 5, 'f', 'a', 'l', 's', 'e')
 	}
 }
 
-// Pack is used to pack a interface given to it.
+// Pack is used to pack a interface given to it. *big.Int is packed as SMALL_BIG_EXT or LARGE_BIG_EXT, and ImproperList
+// as a list ending in its tail.
 // Note that to ensure compatibility in codebases where you have both erlpack and json, json.RawMessage is treated the same as erlpack.RawData.
 func Pack(Interface interface{}) ([]byte, error) {
+	return PackWithOptions(Interface, EncoderOptions{})
+}
+
+// PackWithOptions is used to pack a interface given to it with the encoder options specified.
+func PackWithOptions(Interface interface{}, Options EncoderOptions) ([]byte, error) {
 	// Create a scratchpad which will be used for creating this.
 	pad := newScratchpad(INITIAL_ALLOC)
 	pad.endAppend(131)
 
+	// Create the float packer.
+	floatPacker := func(Data float64) error {
+		if math.IsNaN(Data) || math.IsInf(Data, 0) {
+			if !Options.NonFiniteFloatsAsAtoms {
+				return &NonFiniteFloatError{Value: Data}
+			}
+			packAtom(nonFiniteFloatAtom(Data), pad)
+			return nil
+		}
+		if Options.LegacyFloats {
+			packLegacyFloat64(Data, pad)
+		} else {
+			packFloat64(Data, pad)
+		}
+		return nil
+	}
+
 	// Add a switch for the type.
 	var handler func(i interface{}) error
 	handler = func(i interface{}) error {
+		// Use the marshaler from the options if there is one for the type (or the type a pointer is to).
+		if len(Options.Marshalers) != 0 {
+			t := reflect.TypeOf(i)
+			m, ok := Options.Marshalers[t]
+			if !ok && t != nil && t.Kind() == reflect.Ptr {
+				if m, ok = Options.Marshalers[t.Elem()]; ok {
+					v := reflect.ValueOf(i)
+					if v.IsNil() {
+						packNil(pad)
+						return nil
+					}
+					i = v.Elem().Interface()
+				}
+			}
+			if ok {
+				raw, err := m(i)
+				if err != nil {
+					return err
+				}
+				pad.endAppend(raw...)
+				return nil
+			}
+		}
+
 		switch b := i.(type) {
+		case Marshaler:
+			// Let the type pack itself, unless it is a nil pointer.
+			if v := reflect.ValueOf(b); v.Kind() == reflect.Ptr && v.IsNil() {
+				packNil(pad)
+				return nil
+			}
+			raw, err := b.MarshalErlpack()
+			if err != nil {
+				return err
+			}
+			pad.endAppend(raw...)
+			return nil
 		case json.RawMessage:
 			// Just add the raw data (compatibility for libs using both erlpack and json).
 			pad.endAppend(b...)
 			return nil
 		case RawData:
-			// Just add the raw data.
+			// Just add the raw data, or nil if there is none (an empty term is not valid).
+			if len(b) == 0 {
+				packNil(pad)
+				return nil
+			}
 			pad.endAppend(b...)
 			return nil
+		case rawDataGetter:
+			// Add the raw data, or nil if nothing has been unpacked into it or it is a nil pointer.
+			if v := reflect.ValueOf(b); v.Kind() == reflect.Ptr && v.IsNil() {
+				packNil(pad)
+				return nil
+			}
+			raw := b.rawData()
+			if len(raw) == 0 {
+				packNil(pad)
+				return nil
+			}
+			pad.endAppend(raw...)
+			return nil
+		case *big.Int:
+			// Pack the integer, or nil if the pointer is nil.
+			if b == nil {
+				packNil(pad)
+			} else {
+				packBigInt(b, pad)
+			}
+			return nil
+		case big.Int:
+			packBigInt(&b, pad)
+			return nil
+		case ImproperList:
+			// Pack the items followed by the tail instead of a blank list. A list without items is just its tail.
+			if len(b.Items) == 0 {
+				return handler(b.Tail)
+			}
+			a := make([]byte, 5)
+			a[0] = 'l'
+			ntohl32(uint32(len(b.Items)), a, 1)
+			pad.endAppend(a...)
+			for _, v := range b.Items {
+				if err := handler(v); err != nil {
+					return err
+				}
+			}
+			return handler(b.Tail)
+		case MapKey:
+			// Map keys are the raw data of the key.
+			pad.endAppend([]byte(b)...)
+			return nil
+		case []byte:
+			// Pack the binary and return nil.
+			packBinary(b, pad)
+			return nil
+		case Tuple:
+			// Pack each item within the tuple.
+			packTupleHeader(pad, uint32(len(b)))
+			for _, v := range b {
+				if err := handler(v); err != nil {
+					return err
+				}
+			}
+			return nil
+		case OrderedMap:
+			// Pack each item in order.
+			appendMapHeader(pad, uint32(len(b)))
+			for _, v := range b {
+				if err := handler(v.Key); err != nil {
+					return err
+				}
+				if err := handler(v.Value); err != nil {
+					return err
+				}
+			}
+			return nil
 		case nil:
 			// Pack the nil bytes and return nil.
 			packNil(pad)
 			return nil
+		case Number:
+			// Pack the integer or float the number contains.
+			term, err := b.term()
+			if err != nil {
+				return err
+			}
+			return handler(term)
 		case string:
 			// Pack the string and return nil.
 			packString(i.(string), pad)
@@ -190,31 +442,59 @@ func Pack(Interface interface{}) ([]byte, error) {
 			return nil
 		case int:
 			// Pack the integer and return nil.
-			packInt(i.(int), pad)
+			packInteger(int64(b), pad)
 			return nil
 		case int64:
 			// Pack the int64 and return nil.
-			packInt64(i.(int64), pad)
+			packInteger(b, pad)
 			return nil
 		case float32:
 			// Pack the float32 as a float64 and return nil.
-			packFloat64(float64(i.(float32)), pad)
-			return nil
+			return floatPacker(float64(b))
 		case Atom:
 			// Pack a atom and return nil.
-			pad.endAppend('s', byte(len(i.(Atom))))
-			pad.endAppend([]byte(i.(Atom))...)
+			packAtom(i.(Atom), pad)
+			return nil
+		case Export:
+			// Pack a export and return nil.
+			packExport(b, pad)
+			return nil
+		case Fun:
+			// Just add the raw fun bytes.
+			pad.endAppend(b.raw...)
 			return nil
+		case Pid:
+			// Pack a pid and return nil.
+			packPid(b, pad)
+			return nil
+		case Port:
+			// Pack a port and return nil.
+			packPort(b, pad)
+			return nil
+		case Reference:
+			// Pack a reference.
+			return packReference(b, pad)
 		case UncastedResult:
 			// Pack a uncasted result.
 			return handler(i.(UncastedResult).item)
 		case float64:
 			// Pack the float64 and return nil.
-			packFloat64(i.(float64), pad)
-			return nil
+			return floatPacker(b)
 		default:
 			rt := reflect.ValueOf(i)
 			switch rt.Kind() {
+			case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int, reflect.Int64:
+				// Pack the integer and return nil.
+				packInteger(rt.Int(), pad)
+				return nil
+			case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint, reflect.Uint64, reflect.Uintptr:
+				// Pack the unsigned integer and return nil.
+				if u := rt.Uint(); u > math.MaxInt64 {
+					packSmallBig(u, false, pad)
+				} else {
+					packInteger(int64(u), pad)
+				}
+				return nil
 			case reflect.Ptr:
 				// Check if it's a null pointer.
 				if rt.IsNil() {
@@ -258,6 +538,11 @@ func Pack(Interface interface{}) ([]byte, error) {
 				keys := rt.MapKeys()
 				appendMapHeader(pad, uint32(len(keys)))
 
+				// If this is deterministic, pack the keys in order.
+				if Options.Deterministic {
+					return packSortedMap(rt, keys, pad, &Options, handler)
+				}
+
 				// Iterate the map.
 				for _, e := range keys {
 					v := rt.MapIndex(e)
@@ -276,12 +561,21 @@ func Pack(Interface interface{}) ([]byte, error) {
 				// Return nil (there were no errors).
 				return nil
 			case reflect.Struct:
-				// Create a struct parser.
-				s := structs.New(i)
-				s.TagName = "erlpack"
+				// Get the fields and create the map header.
+				fields := packedStructFields(i)
+				appendMapHeader(pad, uint32(len(fields)))
+
+				// Pack each field in the order it was declared.
+				for _, field := range fields {
+					packString(field.name, pad)
+					err := handler(field.value)
+					if err != nil {
+						return err
+					}
+				}
 
-				// Call this back with the generated map.
-				return handler(s.Map())
+				// Return nil (there were no errors).
+				return nil
 			default:
 				// Send a unknown type error.
 				return errors.New(fmt.Sprintf("unknown type: %T", i))
@@ -294,5 +588,19 @@ func Pack(Interface interface{}) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return pad.bytes(), nil
+	b := pad.bytes()
+
+	// Compress the term if this is wanted.
+	if Options.Compress && len(b)-1 >= Options.CompressionThreshold {
+		level := Options.CompressionLevel
+		if level == 0 {
+			level = zlib.DefaultCompression
+		}
+		term, err := compressTerm(b[1:], level)
+		if err != nil {
+			return nil, err
+		}
+		b = append([]byte{131}, term...)
+	}
+	return b, nil
 }
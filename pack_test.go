@@ -1,8 +1,11 @@
 package erlpack
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"math/big"
+	"reflect"
 	"testing"
 )
 
@@ -55,7 +58,7 @@ func TestPackNil(t *testing.T) {
 		t.Error(err)
 		return
 	}
-	err = assertBytes([]byte("\x83s\x03nil"), b)
+	err = assertBytes([]byte("\x83w\x03nil"), b)
 	if err != nil {
 		t.Error(err)
 	}
@@ -68,7 +71,7 @@ func TestPackTrue(t *testing.T) {
 		t.Error(err)
 		return
 	}
-	err = assertBytes([]byte("\x83s\x04true"), b)
+	err = assertBytes([]byte("\x83w\x04true"), b)
 	if err != nil {
 		t.Error(err)
 	}
@@ -81,7 +84,7 @@ func TestPackFalse(t *testing.T) {
 		t.Error(err)
 		return
 	}
-	err = assertBytes([]byte("\x83s\x05false"), b)
+	err = assertBytes([]byte("\x83w\x05false"), b)
 	if err != nil {
 		t.Error(err)
 	}
@@ -121,7 +124,7 @@ func TestPackNilStringPointer(t *testing.T) {
 		t.Error(err)
 		return
 	}
-	err = assertBytes([]byte("\x83s\x03nil"), b)
+	err = assertBytes([]byte("\x83w\x03nil"), b)
 	if err != nil {
 		t.Error(err)
 	}
@@ -219,3 +222,180 @@ func TestPackStruct(t *testing.T) {
 func BenchmarkPack(b *testing.B) {
 	_, _ = Pack(true)
 }
+
+// TestPackLegacyFloat is used to test packing a float as FLOAT_EXT.
+func TestPackLegacyFloat(t *testing.T) {
+	b, err := PackWithOptions(3.1, EncoderOptions{LegacyFloats: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = assertBytes([]byte("\x83c3.10000000000000008882e+00\x00\x00\x00\x00\x00"), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestPackMarshalers is used to test the marshalers in the encoder options are used for their types and pointers to
+// them.
+func TestPackMarshalers(t *testing.T) {
+	id := Atom("x")
+	opts := EncoderOptions{Marshalers: map[reflect.Type]func(interface{}) ([]byte, error){
+		reflect.TypeOf(Atom("")): func(v interface{}) ([]byte, error) {
+			return AppendString(nil, string(v.(Atom))), nil
+		},
+	}}
+	b, err := PackWithOptions([]interface{}{id, &id, (*Atom)(nil), true}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = assertBytes([]byte("\x83l\x00\x00\x00\x04m\x00\x00\x00\x01xm\x00\x00\x00\x01xw\x03nilw\x04truej"), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestPackDeterministic is used to test that map keys are packed in the map key order, where integers are before floats.
+func TestPackDeterministic(t *testing.T) {
+	m := map[interface{}]interface{}{
+		"bin":      1,
+		Atom("b"):  4,
+		Atom("a"):  5,
+		int64(300): 6,
+		1.5:        7,
+		1:          8,
+	}
+	for term, v := range map[int]interface{}{2: []int{1}, 3: Tuple{1}, 9: map[string]int{"a": 1}} {
+		k, err := HashableKey(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m[k] = term
+	}
+	expected := []byte("\x83t\x00\x00\x00\x09" +
+		"a\x01a\x08" + "b\x00\x00\x01\x2ca\x06" + "F\x3f\xf8\x00\x00\x00\x00\x00\x00a\x07" +
+		"w\x01aa\x05" + "w\x01ba\x04" + "h\x01a\x01a\x03" + "t\x00\x00\x00\x01m\x00\x00\x00\x01aa\x01a\x09" +
+		"l\x00\x00\x00\x01a\x01ja\x02" + "m\x00\x00\x00\x03bina\x01")
+	for i := 0; i < 10; i++ {
+		b, err := PackWithOptions(m, EncoderOptions{Deterministic: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = assertBytes(expected, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestCompareMaps is used to test that maps are compared by their keys in map key order rather than the order they
+// were packed in.
+func TestCompareMaps(t *testing.T) {
+	// #{b => 1, a => 2} < #{a => 1, c => 1}, since the smallest keys are the same and b < c.
+	x := OrderedMap{{Key: Atom("b"), Value: uint8(1)}, {Key: Atom("a"), Value: uint8(2)}}
+	y := OrderedMap{{Key: Atom("a"), Value: uint8(1)}, {Key: Atom("c"), Value: uint8(1)}}
+	if compareTerms(x, y) != -1 || compareTerms(y, x) != 1 {
+		t.Fatal("maps were not compared by sorted keys")
+	}
+
+	// #{1.0 => a, 2 => a} > #{2 => a, 3 => a}, since integer keys are before float keys.
+	x = OrderedMap{{Key: 1.0, Value: Atom("a")}, {Key: uint8(2), Value: Atom("a")}}
+	y = OrderedMap{{Key: uint8(2), Value: Atom("a")}, {Key: uint8(3), Value: Atom("a")}}
+	if compareTerms(x, y) != 1 {
+		t.Fatal("integer keys should be before float keys")
+	}
+
+	// Values are compared in key order once the keys are equal.
+	x = OrderedMap{{Key: Atom("b"), Value: uint8(1)}, {Key: Atom("a"), Value: uint8(2)}}
+	y = OrderedMap{{Key: Atom("a"), Value: uint8(3)}, {Key: Atom("b"), Value: uint8(0)}}
+	if compareTerms(x, y) != -1 {
+		t.Fatal("values should be compared in key order")
+	}
+
+	// The term order still compares integers and floats by value.
+	if compareTerms(uint8(2), 1.5) != 1 || compareKeys(uint8(2), 1.5) != -1 {
+		t.Fatal("unexpected number order")
+	}
+}
+
+// TestPackStructFieldOrder is used to test that struct fields are packed in the order they were declared.
+func TestPackStructFieldOrder(t *testing.T) {
+	type test struct {
+		B      int    `erlpack:"b"`
+		A      int    `erlpack:"a"`
+		Skip   int    `erlpack:"-"`
+		Empty  string `erlpack:"empty,omitempty"`
+		Export Export `erlpack:"export"`
+	}
+	b, err := Pack(test{B: 1, A: 2, Export: Export{Module: "m", Function: "f", Arity: 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = assertBytes([]byte("\x83t\x00\x00\x00\x03m\x00\x00\x00\x01ba\x01m\x00\x00\x00\x01aa\x02m\x00\x00\x00\x06exportqw\x01mw\x01fa\x00"), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestPackBigIntegers is used to test packing and unpacking integers which don't fit in 64 bits.
+func TestPackBigIntegers(t *testing.T) {
+	n, _ := new(big.Int).SetString("-340282366920938463463374607431768211457", 10)
+	b, err := Pack(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b[1] != 'n' {
+		t.Fatalf("expected SMALL_BIG_EXT, got %q", b[1])
+	}
+	var v interface{}
+	if err = Unpack(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if x, ok := v.(*big.Int); !ok || x.Cmp(n) != 0 {
+		t.Fatalf("expected %v, got %#v", n, v)
+	}
+	var x big.Int
+	if err = Unpack(b, &x); err != nil || x.Cmp(n) != 0 {
+		t.Fatalf("expected %v, got %v (%v)", n, &x, err)
+	}
+
+	// Use LARGE_BIG_EXT for integers over 255 bytes.
+	large := new(big.Int).Lsh(big.NewInt(1), 8*300)
+	if b, err = Pack(large); err != nil {
+		t.Fatal(err)
+	}
+	if b[1] != 'o' {
+		t.Fatalf("expected LARGE_BIG_EXT, got %q", b[1])
+	}
+	if err = Unpack(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if x, ok := v.(*big.Int); !ok || x.Cmp(large) != 0 {
+		t.Fatal("large integer did not round trip")
+	}
+
+	// Integers which fit are unpacked into smaller types.
+	if err = Unpack([]byte("\x83o\x00\x00\x00\x02\x00\x01\x00"), &v); err != nil || v != int64(1) {
+		t.Fatalf("expected 1, got %#v (%v)", v, err)
+	}
+}
+
+// TestPackImproperList is used to test packing and formatting improper lists.
+func TestPackImproperList(t *testing.T) {
+	l := ImproperList{Items: []interface{}{uint8(1), uint8(2)}, Tail: Atom("x")}
+	b, err := Pack(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, []byte("\x83l\x00\x00\x00\x02a\x01a\x02w\x01x")) {
+		t.Fatalf("unexpected bytes: % x", b)
+	}
+	if b, err = Pack(ImproperList{Tail: uint8(1)}); err != nil || !bytes.Equal(b, []byte("\x83a\x01")) {
+		t.Fatalf("expected a list without items to be its tail, got % x (%v)", b, err)
+	}
+	if s := Format(l, FormatOptions{}); s != "[1,2|x]" {
+		t.Fatal("unexpected erlang format:", s)
+	}
+	if s := Format(l, FormatOptions{Syntax: ElixirSyntax}); s != "[1, 2 | :x]" {
+		t.Fatal("unexpected elixir format:", s)
+	}
+}
@@ -0,0 +1,710 @@
+package erlpack
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TermSyntaxError is returned when the text given to ParseTerm or ParseTermData is not a valid term.
+type TermSyntaxError struct {
+	// Offset is the byte offset within the text where the error was found.
+	Offset int
+
+	// Msg is the description of the error.
+	Msg string
+}
+
+// Error is used to get the error message.
+func (e *TermSyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at offset %d: %s", e.Offset, e.Msg)
+}
+
+// ParseTerm is used to parse a term written with Erlang syntax, such as {ok, [1, 2.5, "abc"], #{<<"a">> => 'B'}}.
+// The result is the same as unpacking the term into a interface{}, so it can be compared with the result of Unpack.
+//
+// Atoms, integers (including big integers, $c characters and base#digits), floats, strings, binaries, lists
+// (including improper lists), tuples, maps and external funs (fun m:f/a) are supported. Variables and expressions are
+// not. A trailing full stop is allowed, and % comments are ignored.
+func ParseTerm(text string) (interface{}, error) {
+	raw, err := ParseTermData(text)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	err = raw.Cast(&v)
+	return v, err
+}
+
+// ParseTermData is used to parse a term written with Erlang syntax (see ParseTerm) and pack it. Map pairs are packed
+// in the order they are written. The result can be cast like any other raw data, or passed to Pack to get bytes which
+// can be given to Unpack.
+func ParseTermData(text string) (RawData, error) {
+	p := &termParser{s: text}
+	v, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.peek() == '.' {
+		p.pos++
+		p.skipSpace()
+	}
+	if p.pos != len(p.s) {
+		return nil, p.errorf("unexpected %q after term", p.s[p.pos:])
+	}
+	b, err := Pack(v)
+	if err != nil {
+		return nil, err
+	}
+	return RawData(b[1:]), nil
+}
+
+// Used to parse a term. The values are the types Pack should use for the term.
+type termParser struct {
+	s     string
+	pos   int
+	depth int
+}
+
+// Used to create a syntax error at the current offset.
+func (p *termParser) errorf(format string, args ...interface{}) error {
+	return &TermSyntaxError{Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Used to get the current byte, or 0 at the end of the text.
+func (p *termParser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+// Used to skip whitespace and comments.
+func (p *termParser) skipSpace() {
+	for p.pos < len(p.s) {
+		switch c := p.s[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			p.pos++
+		case c == '%':
+			for p.pos < len(p.s) && p.s[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Used to skip whitespace and then consume the string if it is next.
+func (p *termParser) accept(s string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.s[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// Used to consume the string, returning a error if it is not next.
+func (p *termParser) expect(s string) error {
+	if !p.accept(s) {
+		if p.pos == len(p.s) {
+			return p.errorf("expected %q, got end of text", s)
+		}
+		return p.errorf("expected %q", s)
+	}
+	return nil
+}
+
+// Used to check if the byte can be within a unquoted atom or a number.
+func isNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '@'
+}
+
+// Used to parse the next term.
+func (p *termParser) term() (interface{}, error) {
+	if p.depth++; p.depth > maxDepth {
+		return nil, p.errorf("term is nested too deeply")
+	}
+	defer func() { p.depth-- }()
+
+	p.skipSpace()
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, p.errorf("expected term, got end of text")
+	case c == '{':
+		p.pos++
+		items, _, err := p.items("}")
+		return Tuple(items), err
+	case c == '[':
+		p.pos++
+		return p.list()
+	case strings.HasPrefix(p.s[p.pos:], "#{"):
+		p.pos += 2
+		return p.mapPairs()
+	case strings.HasPrefix(p.s[p.pos:], "<<"):
+		p.pos += 2
+		return p.binary()
+	case c == '"':
+		s, err := p.strings()
+		if err != nil {
+			return nil, err
+		}
+		list := make([]interface{}, 0, len(s))
+		for _, r := range s {
+			list = append(list, big.NewInt(int64(r)))
+		}
+		return list, nil
+	case c == '\'':
+		s, err := p.quoted('\'')
+		return Atom(s), err
+	case c == '$' || c == '-' || c == '+' || c >= '0' && c <= '9':
+		return p.number()
+	case c >= 'a' && c <= 'z':
+		name := p.name()
+		if name == "fun" {
+			return p.export()
+		}
+		return Atom(name), nil
+	case c >= 'A' && c <= 'Z' || c == '_':
+		return nil, p.errorf("variables are not supported")
+	default:
+		return nil, p.errorf("unexpected %q", c)
+	}
+}
+
+// Used to read a unquoted name.
+func (p *termParser) name() string {
+	start := p.pos
+	for p.pos < len(p.s) && isNameByte(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// Used to parse a atom, which can be quoted.
+func (p *termParser) atom() (Atom, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '\'':
+		s, err := p.quoted('\'')
+		return Atom(s), err
+	case c >= 'a' && c <= 'z':
+		return Atom(p.name()), nil
+	default:
+		return "", p.errorf("expected atom")
+	}
+}
+
+// Used to parse the rest of a external fun (fun m:f/a) after the fun keyword.
+func (p *termParser) export() (interface{}, error) {
+	module, err := p.atom()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(":"); err != nil {
+		return nil, err
+	}
+	function, err := p.atom()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect("/"); err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	arity, err := strconv.ParseUint(p.s[start:p.pos], 10, 8)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("invalid arity")
+	}
+	return Export{Module: module, Function: function, Arity: uint8(arity)}, nil
+}
+
+// Used to parse the items of a tuple or list until the closing bracket specified. Within a list, the items can also
+// end with a |, in which case true is returned and the tail is next.
+func (p *termParser) items(end string) ([]interface{}, bool, error) {
+	items := []interface{}{}
+	if p.accept(end) {
+		return items, false, nil
+	}
+	for {
+		v, err := p.term()
+		if err != nil {
+			return nil, false, err
+		}
+		items = append(items, v)
+		if p.accept(",") {
+			continue
+		}
+		if end == "]" && p.accept("|") {
+			return items, true, nil
+		}
+		return items, false, p.expect(end)
+	}
+}
+
+// Used to parse the rest of a list after the opening bracket.
+func (p *termParser) list() (interface{}, error) {
+	items, hasTail, err := p.items("]")
+	if err != nil || !hasTail {
+		return items, err
+	}
+	tail, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect("]"); err != nil {
+		return nil, err
+	}
+	return listWithTail(items, tail), nil
+}
+
+// Used to parse the rest of a map after the opening brace. If a key is used more than once, the last value is used.
+func (p *termParser) mapPairs() (interface{}, error) {
+	o := OrderedMap{}
+	keys := map[MapKey]int{}
+	if p.accept("}") {
+		return o, nil
+	}
+	for {
+		k, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect("=>"); err != nil {
+			return nil, err
+		}
+		v, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		key, err := canonicalKey(k)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := keys[key]; ok {
+			o[i].Value = v
+		} else {
+			keys[key] = len(o)
+			o = append(o, MapItem{Key: k, Value: v})
+		}
+		if !p.accept(",") {
+			return o, p.expect("}")
+		}
+	}
+}
+
+// Used to parse one or more adjacent strings, which are joined together.
+func (p *termParser) strings() (string, error) {
+	s := ""
+	for {
+		part, err := p.quoted('"')
+		if err != nil {
+			return "", err
+		}
+		s += part
+		p.skipSpace()
+		if p.peek() != '"' {
+			return s, nil
+		}
+	}
+}
+
+// Used to parse a quoted string or atom.
+func (p *termParser) quoted(quote byte) (string, error) {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.s) {
+			return "", p.errorf("unterminated %c", quote)
+		}
+		c := p.s[p.pos]
+		switch c {
+		case quote:
+			p.pos++
+			return b.String(), nil
+		case '\\':
+			r, err := p.escape()
+			if err != nil {
+				return "", err
+			}
+			b.WriteRune(r)
+		default:
+			r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+			b.WriteRune(r)
+			p.pos += size
+		}
+	}
+}
+
+// Used to parse a escape sequence starting with a backslash.
+func (p *termParser) escape() (rune, error) {
+	p.pos++
+	if p.pos >= len(p.s) {
+		return 0, p.errorf("unterminated escape sequence")
+	}
+	c := p.s[p.pos]
+	p.pos++
+	switch c {
+	case 'b':
+		return '\b', nil
+	case 'd':
+		return 0x7f, nil
+	case 'e':
+		return 0x1b, nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 's':
+		return ' ', nil
+	case 't':
+		return '\t', nil
+	case 'v':
+		return '\v', nil
+	case '^':
+		if p.pos >= len(p.s) {
+			return 0, p.errorf("unterminated escape sequence")
+		}
+		p.pos++
+		return rune(p.s[p.pos-1] & 0x1f), nil
+	case 'x':
+		// Either \xHH or \x{H...}.
+		end := p.pos + 2
+		if p.peek() == '{' {
+			p.pos++
+			end = strings.IndexByte(p.s[p.pos:], '}')
+			if end == -1 {
+				return 0, p.errorf("unterminated escape sequence")
+			}
+			end += p.pos
+		}
+		if end > len(p.s) {
+			return 0, p.errorf("unterminated escape sequence")
+		}
+		n, err := strconv.ParseUint(p.s[p.pos:end], 16, 32)
+		if err != nil || n > utf8.MaxRune {
+			return 0, p.errorf("invalid escape sequence")
+		}
+		p.pos = end
+		if p.peek() == '}' {
+			p.pos++
+		}
+		return rune(n), nil
+	default:
+		if c >= '0' && c <= '7' {
+			// Up to 3 octal digits.
+			n := rune(c - '0')
+			for i := 0; i < 2 && p.peek() >= '0' && p.peek() <= '7'; i++ {
+				n = n*8 + rune(p.peek()-'0')
+				p.pos++
+			}
+			return n, nil
+		}
+		if c >= utf8.RuneSelf {
+			p.pos--
+			r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+			p.pos += size
+			return r, nil
+		}
+		return rune(c), nil
+	}
+}
+
+// Used to read digits (which can be separated by underscores) in the base specified.
+func (p *termParser) digits(base int) (string, error) {
+	start := p.pos
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c == '_' && p.pos != start && p.pos+1 < len(p.s) && digitValue(p.s[p.pos+1]) < base && digitValue(p.s[p.pos-1]) < base {
+			p.pos++
+			continue
+		}
+		if digitValue(c) >= base {
+			break
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+	if b.Len() == 0 {
+		return "", p.errorf("expected digits")
+	}
+	return b.String(), nil
+}
+
+// Used to get the value of a digit in any base up to 36, or 36 if the byte isn't a digit.
+func digitValue(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'z':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'Z':
+		return int(c-'A') + 10
+	default:
+		return 36
+	}
+}
+
+// Used to parse a integer, float or character.
+func (p *termParser) number() (interface{}, error) {
+	// Get the sign.
+	negative := false
+	if c := p.peek(); c == '-' || c == '+' {
+		negative = c == '-'
+		p.pos++
+		p.skipSpace()
+	}
+
+	// Handle a character.
+	if p.peek() == '$' {
+		p.pos++
+		if p.pos >= len(p.s) {
+			return nil, p.errorf("expected character")
+		}
+		var r rune
+		if p.s[p.pos] == '\\' {
+			var err error
+			if r, err = p.escape(); err != nil {
+				return nil, err
+			}
+		} else {
+			var size int
+			r, size = utf8.DecodeRuneInString(p.s[p.pos:])
+			p.pos += size
+		}
+		n := big.NewInt(int64(r))
+		if negative {
+			n.Neg(n)
+		}
+		return n, nil
+	}
+
+	// Get the integer part.
+	if c := p.peek(); c < '0' || c > '9' {
+		return nil, p.errorf("expected number")
+	}
+	start := p.pos
+	whole, err := p.digits(10)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.peek() == '#':
+		// This is base#digits.
+		base, err := strconv.Atoi(whole)
+		if err != nil || base < 2 || base > 36 {
+			p.pos = start
+			return nil, p.errorf("invalid base")
+		}
+		p.pos++
+		digits, err := p.digits(base)
+		if err != nil {
+			return nil, err
+		}
+		n, _ := new(big.Int).SetString(digits, base)
+		if negative {
+			n.Neg(n)
+		}
+		return n, nil
+	case p.peek() == '.' && p.pos+1 < len(p.s) && p.s[p.pos+1] >= '0' && p.s[p.pos+1] <= '9':
+		// This is a float.
+		p.pos++
+		fraction, err := p.digits(10)
+		if err != nil {
+			return nil, err
+		}
+		text := whole + "." + fraction
+		if c := p.peek(); c == 'e' || c == 'E' {
+			p.pos++
+			text += "e"
+			if c := p.peek(); c == '-' || c == '+' {
+				text += string(c)
+				p.pos++
+			}
+			exponent, err := p.digits(10)
+			if err != nil {
+				return nil, err
+			}
+			text += exponent
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil || math.IsInf(f, 0) {
+			p.pos = start
+			return nil, p.errorf("invalid float")
+		}
+		if negative {
+			f = -f
+		}
+		return f, nil
+	default:
+		n, _ := new(big.Int).SetString(whole, 10)
+		if negative {
+			n.Neg(n)
+		}
+		return n, nil
+	}
+}
+
+// Used to parse the rest of a binary after the opening brackets.
+func (p *termParser) binary() (interface{}, error) {
+	b := []byte{}
+	if p.accept(">>") {
+		return b, nil
+	}
+	for {
+		segment, err := p.segment()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, segment...)
+		if !p.accept(",") {
+			return b, p.expect(">>")
+		}
+	}
+}
+
+// Used to parse a segment of a binary, which is a integer, float or string with a optional size and type.
+func (p *termParser) segment() ([]byte, error) {
+	// Get the value.
+	p.skipSpace()
+	var value interface{}
+	var err error
+	if p.peek() == '"' {
+		value, err = p.strings()
+	} else {
+		value, err = p.number()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the size.
+	size := -1
+	if p.accept(":") {
+		p.skipSpace()
+		digits, err := p.digits(10)
+		if err != nil {
+			return nil, err
+		}
+		if size, err = strconv.Atoi(digits); err != nil || size > 1<<16 {
+			return nil, p.errorf("invalid size")
+		}
+	}
+
+	// Get the type specifiers.
+	utf8Type, floatType, little := false, false, false
+	if p.accept("/") {
+		for {
+			p.skipSpace()
+			switch spec := p.name(); spec {
+			case "utf8":
+				utf8Type = true
+			case "float":
+				floatType = true
+			case "little":
+				little = true
+			case "integer", "big", "signed", "unsigned":
+			default:
+				return nil, p.errorf("unsupported type specifier %q", spec)
+			}
+			if !p.accept("-") {
+				break
+			}
+		}
+	}
+	if utf8Type && size != -1 {
+		return nil, p.errorf("utf8 segments can't have a size")
+	}
+
+	// Turn the value into bytes.
+	var out []byte
+	switch x := value.(type) {
+	case string:
+		for _, r := range x {
+			if utf8Type {
+				out = utf8.AppendRune(out, r)
+			} else {
+				out = append(out, p.integerBytes(big.NewInt(int64(r)), size, little)...)
+			}
+		}
+		if !utf8Type && size != -1 && size%8 != 0 {
+			return nil, p.errorf("bitstrings are not supported")
+		}
+		return out, nil
+	case float64:
+		if !floatType {
+			return nil, p.errorf("float segments must have the float type")
+		}
+		switch size {
+		case -1, 64:
+			out = make([]byte, 8)
+			bits := math.Float64bits(x)
+			for i := 0; i < 8; i++ {
+				out[i] = byte(bits >> (56 - 8*i))
+			}
+		case 32:
+			out = make([]byte, 4)
+			bits := math.Float32bits(float32(x))
+			for i := 0; i < 4; i++ {
+				out[i] = byte(bits >> (24 - 8*i))
+			}
+		default:
+			return nil, p.errorf("float segments must be 32 or 64 bits")
+		}
+		if little {
+			for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+				out[i], out[j] = out[j], out[i]
+			}
+		}
+		return out, nil
+	default:
+		n := x.(*big.Int)
+		if utf8Type {
+			if !n.IsInt64() || n.Int64() < 0 || n.Int64() > utf8.MaxRune {
+				return nil, p.errorf("invalid utf8 character")
+			}
+			return utf8.AppendRune(nil, rune(n.Int64())), nil
+		}
+		if floatType {
+			return nil, p.errorf("float segments must be floats")
+		}
+		if size != -1 && size%8 != 0 {
+			return nil, p.errorf("bitstrings are not supported")
+		}
+		return p.integerBytes(n, size, little), nil
+	}
+}
+
+// Used to get the bytes of a integer within a binary. Like Erlang, the integer is truncated to the size in bits.
+func (p *termParser) integerBytes(n *big.Int, size int, little bool) []byte {
+	if size == -1 {
+		size = 8
+	}
+	l := size / 8
+
+	// Get the two's complement of the integer within the size.
+	mod := new(big.Int).Lsh(big.NewInt(1), uint(size))
+	v := new(big.Int).Mod(n, mod)
+	out := v.FillBytes(make([]byte, l))
+	if little {
+		for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+			out[i], out[j] = out[j], out[i]
+		}
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package erlpack
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// TestParseTermGolden is used to test the terms in the golden fixtures parse to the expected bytes.
+func TestParseTermGolden(t *testing.T) {
+	// These fixtures use function calls or operators rather than literals.
+	nonLiterals := map[string]bool{
+		"tuple_large": true, "atom_utf8_long": true, "large_big": true, "large_big_negative": true, "pid": true,
+		"compressed": true,
+	}
+	for _, f := range loadGoldenFixtures(t) {
+		if nonLiterals[f.name] || f.unpackOnly || f.opts.LegacyFloats {
+			// The parser always produces the default format.
+			continue
+		}
+		raw, err := ParseTermData(f.term)
+		if err != nil {
+			t.Errorf("%s: %v", f.name, err)
+			continue
+		}
+		if !bytes.Equal(raw, f.expected[1:]) {
+			t.Errorf("%s: expected % x, got % x", f.name, f.expected[1:], []byte(raw))
+		}
+	}
+}
+
+// TestParseTerm is used to test parsing terms matches unpacking them.
+func TestParseTerm(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	tests := []struct {
+		text     string
+		expected interface{}
+	}{
+		{"ok.", Atom("ok")},
+		{"'hello world'", Atom("hello world")},
+		{"'it\\'s'", Atom("it's")},
+		{"true", true},
+		{"nil", nil},
+		{"node@host", Atom("node@host")},
+		{"42", uint8(42)},
+		{"-1_000", int32(-1000)},
+		{"16#ff", uint8(255)},
+		{"2#1010", uint8(10)},
+		{"-123456789012345678901234567890", huge},
+		{"$a", uint8('a')},
+		{"$\\n", uint8('\n')},
+		{"1.5e3", 1500.0},
+		{"-0.25", -0.25},
+		{`"ab"`, []interface{}{uint8('a'), uint8('b')}},
+		{`"a" "b"`, []interface{}{uint8('a'), uint8('b')}},
+		{`""`, []interface{}{}},
+		{`<<"héllo"/utf8>>`, []byte("héllo")},
+		{`<<"a\x{41}\101">>`, []byte("aAA")},
+		{"<<256:16, 1:16/little, -1>>", []byte{1, 0, 1, 0, 255}},
+		{"<<1.5/float, 0.5:32/float>>", []byte{0x3f, 0xf8, 0, 0, 0, 0, 0, 0, 0x3f, 0, 0, 0}},
+		{"[1, [2] | 3]", ImproperList{Items: []interface{}{uint8(1), []interface{}{uint8(2)}}, Tail: uint8(3)}},
+		{"[1 | [2 | []]]", []interface{}{uint8(1), uint8(2)}},
+		{"{ok, {}} % a comment", Tuple{Atom("ok"), Tuple{}}},
+		{"#{a => 1, a => 2}", map[interface{}]interface{}{Atom("a"): uint8(2)}},
+		{"fun lists:map/2", Export{Module: "lists", Function: "map", Arity: 2}},
+	}
+	for _, tt := range tests {
+		v, err := ParseTerm(tt.text)
+		if err != nil {
+			t.Errorf("%s: %v", tt.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, tt.expected) {
+			t.Errorf("%s: expected %#v, got %#v", tt.text, tt.expected, v)
+		}
+	}
+}
+
+// TestParseTermErrors is used to test invalid terms return syntax errors.
+func TestParseTermErrors(t *testing.T) {
+	tests := []struct {
+		text   string
+		offset int
+	}{
+		{"", 0},
+		{"X", 0},
+		{"{ok", 3},
+		{"[1 2]", 3},
+		{"'abc", 4},
+		{"ok ok", 3},
+		{"<<1:4>>", 5},
+		{"#{a}", 3},
+		{"37#1", 0},
+	}
+	for _, tt := range tests {
+		_, err := ParseTerm(tt.text)
+		var syntaxErr *TermSyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%q: expected syntax error, got %v", tt.text, err)
+			continue
+		}
+		if syntaxErr.Offset != tt.offset {
+			t.Errorf("%q: expected offset %d, got %d (%v)", tt.text, tt.offset, syntaxErr.Offset, err)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package erlpack
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Pid is used to define a Erlang process identifier. Pids are always packed as NEW_PID_EXT.
+type Pid struct {
+	Node     Atom
+	ID       uint32
+	Serial   uint32
+	Creation uint32
+}
+
+// Port is used to define a Erlang port identifier. Ports are packed as NEW_PORT_EXT, or V4_PORT_EXT if the ID does not
+// fit in 32 bits.
+type Port struct {
+	Node     Atom
+	ID       uint64
+	Creation uint32
+}
+
+// Reference is used to define a Erlang reference. References are always packed as NEWER_REFERENCE_EXT. A Reference
+// can't be used as a Go map key, so it is turned into a MapKey when it is the key of a map.
+type Reference struct {
+	Node     Atom
+	Creation uint32
+	ID       []uint32
+}
+
+// Used to read a identifier (a pid, port or reference) during unpacking. The bytes of the term (including the tag)
+// are also returned so the term can be kept as raw data.
+func readIdentifier(DataType byte, r unpackReader, opts *DecoderOptions) (interface{}, []byte, error) {
+	raw := []byte{DataType}
+	read := func(n int) ([]byte, error) {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, errors.New("identifier larger than remainder of array")
+		}
+		raw = append(raw, b...)
+		return b, nil
+	}
+
+	// References other than REFERENCE_EXT start with the number of IDs.
+	idCount := 1
+	if DataType == 'r' || DataType == 'Z' {
+		b, err := read(2)
+		if err != nil {
+			return nil, nil, err
+		}
+		idCount = int(binary.BigEndian.Uint16(b))
+		if idCount > 5 {
+			return nil, nil, errors.New("reference has too many ids")
+		}
+	}
+
+	// Read the node.
+	atomType, node, err := readAtomTerm(r, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw = append(raw, atomType)
+	switch atomType {
+	case 's', 'w':
+		raw = append(raw, byte(len(node)))
+	default:
+		raw = append(raw, byte(len(node)>>8), byte(len(node)))
+	}
+	raw = append(raw, node...)
+
+	// Used to read the creation, which is 1 byte in the older formats and 4 in the newer ones.
+	creation := func(size int) (uint32, error) {
+		b, err := read(size)
+		if err != nil {
+			return 0, err
+		}
+		if size == 1 {
+			return uint32(b[0]), nil
+		}
+		return binary.BigEndian.Uint32(b), nil
+	}
+	creationSize := 4
+	switch DataType {
+	case 'g', 'f', 'e', 'r':
+		creationSize = 1
+	}
+
+	switch DataType {
+	case 'g', 'X': // pid
+		b, err := read(8)
+		if err != nil {
+			return nil, nil, err
+		}
+		c, err := creation(creationSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		return Pid{
+			Node:     Atom(node),
+			ID:       binary.BigEndian.Uint32(b),
+			Serial:   binary.BigEndian.Uint32(b[4:]),
+			Creation: c,
+		}, raw, nil
+	case 'f', 'Y', 'x': // port
+		var id uint64
+		if DataType == 'x' {
+			b, err := read(8)
+			if err != nil {
+				return nil, nil, err
+			}
+			id = binary.BigEndian.Uint64(b)
+		} else {
+			b, err := read(4)
+			if err != nil {
+				return nil, nil, err
+			}
+			id = uint64(binary.BigEndian.Uint32(b))
+		}
+		c, err := creation(creationSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		return Port{Node: Atom(node), ID: id, Creation: c}, raw, nil
+	default: // reference
+		ref := Reference{Node: Atom(node), ID: make([]uint32, idCount)}
+		if DataType == 'e' {
+			// REFERENCE_EXT has the ID before the creation.
+			b, err := read(4)
+			if err != nil {
+				return nil, nil, err
+			}
+			ref.ID[0] = binary.BigEndian.Uint32(b)
+			if ref.Creation, err = creation(1); err != nil {
+				return nil, nil, err
+			}
+			return ref, raw, nil
+		}
+		if ref.Creation, err = creation(creationSize); err != nil {
+			return nil, nil, err
+		}
+		b, err := read(idCount * 4)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := range ref.ID {
+			ref.ID[i] = binary.BigEndian.Uint32(b[i*4:])
+		}
+		return ref, raw, nil
+	}
+}
+
+// Used to pack a pid.
+func packPid(Data Pid, pad *scratchpad) {
+	pad.endAppend('X')
+	packAtom(Data.Node, pad)
+	a := make([]byte, 12)
+	ntohl32(Data.ID, a, 0)
+	ntohl32(Data.Serial, a, 4)
+	ntohl32(Data.Creation, a, 8)
+	pad.endAppend(a...)
+}
+
+// Used to pack a port.
+func packPort(Data Port, pad *scratchpad) {
+	var a []byte
+	if Data.ID > 0xffffffff {
+		pad.endAppend('x')
+		packAtom(Data.Node, pad)
+		a = make([]byte, 12)
+		binary.BigEndian.PutUint64(a, Data.ID)
+	} else {
+		pad.endAppend('Y')
+		packAtom(Data.Node, pad)
+		a = make([]byte, 8)
+		ntohl32(uint32(Data.ID), a, 0)
+	}
+	ntohl32(Data.Creation, a, len(a)-4)
+	pad.endAppend(a...)
+}
+
+// Used to pack a reference.
+func packReference(Data Reference, pad *scratchpad) error {
+	if len(Data.ID) == 0 || len(Data.ID) > 5 {
+		return errors.New("reference must have between 1 and 5 ids")
+	}
+	pad.endAppend('Z', byte(len(Data.ID)>>8), byte(len(Data.ID)))
+	packAtom(Data.Node, pad)
+	a := make([]byte, 4+len(Data.ID)*4)
+	ntohl32(Data.Creation, a, 0)
+	for i, id := range Data.ID {
+		ntohl32(id, a, 4+i*4)
+	}
+	pad.endAppend(a...)
+	return nil
+}
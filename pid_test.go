@@ -0,0 +1,134 @@
+package erlpack
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// TestIdentifierRoundTrip is used to test packing and unpacking pids, ports and references.
+func TestIdentifierRoundTrip(t *testing.T) {
+	values := []interface{}{
+		Pid{Node: "a@localhost", ID: 80, Serial: 1, Creation: 1700000000},
+		Port{Node: "a@localhost", ID: 5, Creation: 3},
+		Port{Node: "a@localhost", ID: 1 << 40, Creation: 3},
+		Reference{Node: "a@localhost", Creation: 2, ID: []uint32{1, 2, 3}},
+	}
+	for _, v := range values {
+		b, err := Pack(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out interface{}
+		if err = Unpack(b, &out); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(out, v) {
+			t.Fatalf("expected %#v, got %#v", v, out)
+		}
+
+		// Typed pointers and raw data should work too.
+		ptr := reflect.New(reflect.TypeOf(v))
+		if err = Unpack(b, ptr.Interface()); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ptr.Elem().Interface(), v) {
+			t.Fatalf("expected %#v, got %#v", v, ptr.Elem().Interface())
+		}
+		var raw RawData
+		if err = Unpack(b, &raw); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(raw, b[1:]) {
+			t.Fatal("raw data does not match:", raw)
+		}
+	}
+
+	// References without any ids can't be packed.
+	if _, err := Pack(Reference{Node: "a@localhost"}); err == nil {
+		t.Fatal("expected error for reference without ids")
+	}
+}
+
+// TestLegacyIdentifiers is used to test unpacking the older formats of pids, ports and references.
+func TestLegacyIdentifiers(t *testing.T) {
+	node := []byte{'w', 1, 'a'}
+	tests := []struct {
+		name string
+		data []byte
+		want interface{}
+	}{
+		{name: "PID_EXT", data: append(append([]byte{'g'}, node...), 0, 0, 0, 80, 0, 0, 0, 1, 2), want: Pid{Node: "a", ID: 80, Serial: 1, Creation: 2}},
+		{name: "PORT_EXT", data: append(append([]byte{'f'}, node...), 0, 0, 0, 5, 2), want: Port{Node: "a", ID: 5, Creation: 2}},
+		{name: "REFERENCE_EXT", data: append(append([]byte{'e'}, node...), 0, 0, 0, 7, 2), want: Reference{Node: "a", Creation: 2, ID: []uint32{7}}},
+		{name: "NEW_REFERENCE_EXT", data: append(append([]byte{'r', 0, 2}, node...), 2, 0, 0, 0, 7, 0, 0, 0, 8), want: Reference{Node: "a", Creation: 2, ID: []uint32{7, 8}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := append([]byte{131}, tt.data...)
+			var out interface{}
+			if err := Unpack(b, &out); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(out, tt.want) {
+				t.Fatalf("expected %#v, got %#v", tt.want, out)
+			}
+
+			// The raw data should keep the original format.
+			var raw RawData
+			if err := Unpack(b, &raw); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(raw, tt.data) {
+				t.Fatal("raw data does not match:", raw)
+			}
+
+			// Truncated data should error.
+			if err := Unpack(b[:len(b)-1], &out); err == nil {
+				t.Fatal("expected error for truncated data")
+			}
+		})
+	}
+}
+
+// TestIdentifierFormatAndOrder is used to test formatting and sorting pids, ports and references.
+func TestIdentifierFormatAndOrder(t *testing.T) {
+	pid := Pid{Node: "a", ID: 80, Serial: 1}
+	port := Port{Node: "a", ID: 5}
+	ref := Reference{Node: "a", ID: []uint32{1, 2, 3}}
+	for syntax, want := range map[Syntax]string{
+		ErlangSyntax: "{<0.80.1>,#Port<0.5>,#Ref<0.3.2.1>}",
+		ElixirSyntax: "{#PID<0.80.1>, #Port<0.5>, #Reference<0.3.2.1>}",
+	} {
+		if got := Format(Tuple{pid, port, ref}, FormatOptions{Syntax: syntax}); got != want {
+			t.Errorf("expected %s, got %s", want, got)
+		}
+	}
+
+	// number < atom < reference < fun < port < pid < tuple
+	ordered := []interface{}{uint8(1), Atom("a"), ref, Export{Module: "m", Function: "f"}, port, pid, Pid{Node: "a", ID: 1, Serial: 2}, Tuple{}}
+	for i := 1; i < len(ordered); i++ {
+		if compareTerms(ordered[i-1], ordered[i]) != -1 || compareTerms(ordered[i], ordered[i-1]) != 1 {
+			t.Fatalf("expected %v to be before %v", ordered[i-1], ordered[i])
+		}
+	}
+
+	// References can be used as map keys.
+	b, err := Pack(OrderedMap{{Key: ref, Value: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[interface{}]interface{}
+	if err = Unpack(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	for k := range m {
+		term, err := k.(MapKey).Term()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(term, ref) {
+			t.Fatal("unexpected key:", term)
+		}
+	}
+}
@@ -10,9 +10,12 @@ type pointerSetter struct {
 	baseCache *interface{}
 }
 
-func (s *pointerSetter) getBasePtr() interface{} {
+func (s *pointerSetter) getBasePtr() (interface{}, error) {
 	if s.baseCache != nil {
-		return *s.baseCache
+		return *s.baseCache, nil
+	}
+	if !s.ptr.IsValid() {
+		return nil, errors.New("invalid pointer")
 	}
 	x := s.ptr.Type()
 	for x.Kind() == reflect.Ptr {
@@ -20,10 +23,18 @@ func (s *pointerSetter) getBasePtr() interface{} {
 		if x.Kind() != reflect.Ptr {
 			res := reflect.NewAt(x, nil).Interface()
 			s.baseCache = &res
-			return res
+			return res, nil
 		}
 	}
-	panic("not a pointer - this is a go-erlpack bug, this should be caught in the public functions!")
+	return nil, errors.New("invalid pointer")
+}
+
+// Used to check if a pointer given to a public function can be set.
+func (s *pointerSetter) check() error {
+	if !s.ptr.IsValid() || s.ptr.Kind() != reflect.Ptr || s.ptr.IsNil() {
+		return errors.New("invalid pointer")
+	}
+	return nil
 }
 
 func (s *pointerSetter) set(ptr reflect.Value) error {
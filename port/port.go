@@ -0,0 +1,134 @@
+// Package port is used to run a Go program as a Erlang port. The port reads terms sent by Erlang with
+// open_port({spawn, ...}, [{packet, N}, binary]) from stdin, passes each one to a handler, and writes the replies to
+// stdout with the same framing. When Erlang closes the port, stdin is closed and Run returns.
+package port
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Handler is used to handle a term sent by Erlang. If the reply is not nil, it is packed and sent back. To reply
+// with the nil atom, return erlpack.Atom("nil"). If a error is returned, Run stops and returns it.
+type Handler func(Data erlpack.RawData) (Reply interface{}, err error)
+
+// Port is used to define a Erlang port. The fields should be set before Run is called, and not changed after.
+type Port struct {
+	// PacketSize is the size in bytes of the length before each term. This must be 1, 2 or 4 and match the {packet, N}
+	// option given to open_port. If this is 0, 4 is used.
+	PacketSize int
+
+	// MaxFrameSize is the largest term in bytes which will be read. If this is 0, there is no limit other than the one
+	// set by the packet size.
+	MaxFrameSize int
+
+	// In is where terms are read from. If this is nil, stdin is used.
+	In io.Reader
+
+	// Out is where replies are written to. If this is nil, stdout is used.
+	Out io.Writer
+
+	// Handler is called with each term read.
+	Handler Handler
+
+	// Decoder is the options used when unpacking terms.
+	Decoder erlpack.DecoderOptions
+
+	// Encoder is the options used when packing replies.
+	Encoder erlpack.EncoderOptions
+
+	writeLock sync.Mutex
+	writer    *erlpack.FrameWriter
+}
+
+// New is used to create a port which uses stdin and stdout with the packet size specified.
+func New(PacketSize int, Handler Handler) *Port {
+	return &Port{PacketSize: PacketSize, Handler: Handler}
+}
+
+// Serve is used to run a port using stdin and stdout with the packet size specified until Erlang closes it.
+func Serve(PacketSize int, Handler Handler) error {
+	return New(PacketSize, Handler).Run()
+}
+
+// Used to get the packet size, checking it is valid.
+func (p *Port) packetSize() (int, error) {
+	switch p.PacketSize {
+	case 0:
+		return 4, nil
+	case 1, 2, 4:
+		return p.PacketSize, nil
+	default:
+		return 0, errors.New("invalid packet size " + strconv.Itoa(p.PacketSize))
+	}
+}
+
+// Run is used to read terms and handle them until the input is closed. If the input is closed between terms, nil is
+// returned. If it is closed part of the way through a term, io.ErrUnexpectedEOF is returned.
+func (p *Port) Run() error {
+	if p.Handler == nil {
+		return errors.New("no handler set")
+	}
+	size, err := p.packetSize()
+	if err != nil {
+		return err
+	}
+	in := p.In
+	if in == nil {
+		in = os.Stdin
+	}
+
+	fr := erlpack.NewFrameReader(in, size)
+	fr.MaxFrameSize = p.MaxFrameSize
+	fr.Options = p.Decoder
+	for {
+		// Read the next term, copying it so handlers can keep it.
+		raw, err := fr.ReadFrame()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		raw = append(erlpack.RawData(nil), raw...)
+
+		// Handle it and send the reply.
+		reply, err := p.Handler(raw)
+		if err != nil {
+			return err
+		}
+		if reply != nil {
+			if err = p.Send(reply); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Send is used to pack a term and send it to Erlang. This can be called at any time, including from other goroutines
+// while Run is handling a term.
+func (p *Port) Send(Data interface{}) error {
+	size, err := p.packetSize()
+	if err != nil {
+		return err
+	}
+	b, err := erlpack.PackWithOptions(Data, p.Encoder)
+	if err != nil {
+		return err
+	}
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+	if p.writer == nil {
+		out := p.Out
+		if out == nil {
+			out = os.Stdout
+		}
+		p.writer = erlpack.NewFrameWriter(out, size)
+	}
+	return p.writer.WriteFrame(b)
+}
@@ -0,0 +1,149 @@
+package port
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	erlpack "github.com/JakeMakesStuff/go-erlpack"
+)
+
+// Used to define a port running against in-memory pipes.
+type testPort struct {
+	in   *io.PipeWriter
+	out  *io.PipeReader
+	size int
+	errs chan error
+}
+
+// Used to start a port with the packet size and handler specified.
+func startPort(t *testing.T, PacketSize int, Handler Handler) (*Port, *testPort) {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	p := &Port{PacketSize: PacketSize, In: inR, Out: outW, Handler: Handler}
+	tp := &testPort{in: inW, out: outR, size: PacketSize, errs: make(chan error, 1)}
+	go func() {
+		err := p.Run()
+		_ = outW.Close()
+		tp.errs <- err
+	}()
+	t.Cleanup(func() {
+		_ = inW.Close()
+		_ = outR.Close()
+	})
+	return p, tp
+}
+
+// Used to send a term to the port.
+func (tp *testPort) send(t *testing.T, v interface{}) {
+	t.Helper()
+	b, err := erlpack.Pack(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = erlpack.NewFrameWriter(tp.in, tp.size).WriteFrame(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Used to receive a term from the port.
+func (tp *testPort) receive(t *testing.T, Ptr interface{}) {
+	t.Helper()
+	if err := erlpack.NewFrameReader(tp.out, tp.size).Decode(Ptr); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestPort is used to test handling terms with each packet size.
+func TestPort(t *testing.T) {
+	for _, size := range []int{1, 2, 4} {
+		_, tp := startPort(t, size, func(Data erlpack.RawData) (interface{}, error) {
+			var req erlpack.Tuple
+			if err := Data.Cast(&req); err != nil {
+				return nil, err
+			}
+			switch req[0] {
+			case erlpack.Atom("echo"):
+				return req[1], nil
+			case erlpack.Atom("ignore"):
+				return nil, nil
+			}
+			return nil, errors.New("unknown request")
+		})
+
+		// Each request with a reply should get one, in order.
+		tp.send(t, erlpack.Tuple{erlpack.Atom("echo"), []byte("hello")})
+		var s []byte
+		tp.receive(t, &s)
+		if string(s) != "hello" {
+			t.Fatal("unexpected reply:", string(s))
+		}
+		tp.send(t, erlpack.Tuple{erlpack.Atom("ignore")})
+		tp.send(t, erlpack.Tuple{erlpack.Atom("echo"), 5})
+		var i int
+		tp.receive(t, &i)
+		if i != 5 {
+			t.Fatal("unexpected reply:", i)
+		}
+
+		// Closing the input between terms should stop the port without a error.
+		_ = tp.in.Close()
+		if err := <-tp.errs; err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+}
+
+// TestPortErrors is used to test the errors returned by Run.
+func TestPortErrors(t *testing.T) {
+	handler := func(Data erlpack.RawData) (interface{}, error) {
+		return nil, errors.New("handler failed")
+	}
+
+	// Closing the input part of the way through a term should return io.ErrUnexpectedEOF.
+	_, tp := startPort(t, 4, handler)
+	_, _ = tp.in.Write([]byte{0, 0, 0, 5, 131})
+	_ = tp.in.Close()
+	if err := <-tp.errs; err != io.ErrUnexpectedEOF {
+		t.Fatal("expected io.ErrUnexpectedEOF, got", err)
+	}
+
+	// Errors from the handler should be returned.
+	_, tp = startPort(t, 2, handler)
+	tp.send(t, 1)
+	if err := <-tp.errs; err == nil || err.Error() != "handler failed" {
+		t.Fatal("expected handler error, got", err)
+	}
+
+	// Invalid terms should return a error.
+	_, tp = startPort(t, 1, handler)
+	_, _ = tp.in.Write([]byte{1, 130})
+	if err := <-tp.errs; err == nil {
+		t.Fatal("expected error for invalid term")
+	}
+
+	// Invalid packet sizes should return a error.
+	if err := (&Port{PacketSize: 3, Handler: handler}).Run(); err == nil {
+		t.Fatal("expected error for invalid packet size")
+	}
+}
+
+// TestPortSend is used to test sending terms to Erlang which are not replies.
+func TestPortSend(t *testing.T) {
+	p, tp := startPort(t, 4, func(Data erlpack.RawData) (interface{}, error) {
+		return erlpack.Atom("reply"), nil
+	})
+	go func() { _ = p.Send(erlpack.Atom("event")) }()
+	var a erlpack.Atom
+	tp.receive(t, &a)
+	if a != "event" {
+		t.Fatal("unexpected term:", a)
+	}
+
+	tp.send(t, 1)
+	tp.receive(t, &a)
+	if a != "reply" {
+		t.Fatal("unexpected term:", a)
+	}
+}
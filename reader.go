@@ -0,0 +1,210 @@
+package erlpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+)
+
+// Reader is used to read terms one after another without the version byte. This is mainly used by the code which
+// erlpackgen generates, but it can also be used to write a UnmarshalErlpack method by hand.
+type Reader struct {
+	r    unpackReader
+	opts *DecoderOptions
+}
+
+// NewReader is used to create a reader for the data specified.
+func NewReader(Data []byte) *Reader {
+	return NewReaderWithOptions(Data, DecoderOptions{})
+}
+
+// NewReaderWithOptions is used to create a reader for the data specified which uses the decoder options specified.
+// The options are used by ReadRaw and ReadValue, and MaxInflatedSize is used for compressed terms.
+func NewReaderWithOptions(Data []byte, Options DecoderOptions) *Reader {
+	return &Reader{r: bytes.NewReader(Data), opts: &Options}
+}
+
+// Used to read the data type of the next term, inflating it first if it is compressed.
+func (r *Reader) readDataType() (byte, unpackReader, error) {
+	DataType, err := r.r.ReadByte()
+	if err != nil {
+		return 0, nil, errors.New("not long enough to include data type")
+	}
+	if DataType != 'P' {
+		return DataType, r.r, nil
+	}
+	inflated, err := inflateTerm(r.r, r.opts.MaxInflatedSize)
+	if err != nil {
+		return 0, nil, err
+	}
+	DataType, err = inflated.ReadByte()
+	if err != nil {
+		return 0, nil, errors.New("not long enough to include data type")
+	}
+	return DataType, inflated, nil
+}
+
+// Used to read the next term if it is a atom, binary, integer or float.
+func (r *Reader) readScalar() (interface{}, error) {
+	DataType, src, err := r.readDataType()
+	if err != nil {
+		return nil, err
+	}
+	return processScalar(DataType, src)
+}
+
+// ReadMapHeader is used to read the header of a map and return the number of pairs within it.
+func (r *Reader) ReadMapHeader() (uint32, error) {
+	DataType, src, err := r.readDataType()
+	if err != nil {
+		return 0, err
+	}
+	if DataType != 't' {
+		return 0, errors.New("expected map")
+	}
+	if src != r.r {
+		// The rest of the data is within the compressed term.
+		r.r = src
+	}
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return 0, errors.New("not enough bytes for int32")
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+// ReadKey is used to read a map key which must be a binary or atom.
+func (r *Reader) ReadKey() (string, error) {
+	Item, err := r.readScalar()
+	if err != nil {
+		return "", err
+	}
+	switch x := Item.(type) {
+	case []byte:
+		return string(x), nil
+	case Atom:
+		return string(x), nil
+	}
+	return "", errors.New("key must be string")
+}
+
+// ReadString is used to read a binary or atom as a string.
+func (r *Reader) ReadString() (string, error) {
+	Item, err := r.readScalar()
+	if err != nil {
+		return "", err
+	}
+	switch x := Item.(type) {
+	case []byte:
+		return string(x), nil
+	case Atom:
+		return string(x), nil
+	default:
+		return "", errors.New("could not de-serialize into string")
+	}
+}
+
+// ReadBinary is used to read a binary.
+func (r *Reader) ReadBinary() ([]byte, error) {
+	Item, err := r.readScalar()
+	if err != nil {
+		return nil, err
+	}
+	switch x := Item.(type) {
+	case []byte:
+		return x, nil
+	default:
+		return nil, errors.New("could not de-serialize into string")
+	}
+}
+
+// ReadAtom is used to read a atom.
+func (r *Reader) ReadAtom() (Atom, error) {
+	Item, err := r.readScalar()
+	if err != nil {
+		return "", err
+	}
+	switch x := Item.(type) {
+	case Atom:
+		return x, nil
+	case bool:
+		if x {
+			return "true", nil
+		}
+		return "false", nil
+	case nil:
+		return "nil", nil
+	default:
+		return "", errors.New("unable to unpack to pointer specified")
+	}
+}
+
+// ReadBool is used to read a boolean.
+func (r *Reader) ReadBool() (bool, error) {
+	Item, err := r.readScalar()
+	if err != nil {
+		return false, err
+	}
+	switch x := Item.(type) {
+	case bool:
+		return x, nil
+	default:
+		return false, errors.New("unable to unpack to pointer specified")
+	}
+}
+
+// ReadInt is used to read a integer which fits in a int.
+func (r *Reader) ReadInt() (int, error) {
+	Item, err := r.readScalar()
+	if err != nil {
+		return 0, err
+	}
+	switch x := Item.(type) {
+	case uint8:
+		return int(x), nil
+	case int32:
+		return int(x), nil
+	case int64:
+		return int(x), nil
+	default:
+		return 0, errors.New("could not de-serialize into int")
+	}
+}
+
+// ReadFloat is used to read a float.
+func (r *Reader) ReadFloat() (float64, error) {
+	Item, err := r.readScalar()
+	if err != nil {
+		return 0, err
+	}
+	switch x := Item.(type) {
+	case float64:
+		return x, nil
+	default:
+		return 0, errors.New("could not de-serialize into float64")
+	}
+}
+
+// ReadRaw is used to read the next term as raw data.
+func (r *Reader) ReadRaw() (RawData, error) {
+	var raw RawData
+	err := processItem(&pointerSetter{ptr: reflect.ValueOf(&raw)}, r.r, r.opts)
+	return raw, err
+}
+
+// ReadValue is used to unpack the next term into the pointer specified.
+func (r *Reader) ReadValue(Ptr interface{}) error {
+	v := &pointerSetter{ptr: reflect.ValueOf(Ptr)}
+	if err := v.check(); err != nil {
+		return err
+	}
+	return processItem(v, r.r, r.opts)
+}
+
+// Skip is used to skip the next term.
+func (r *Reader) Skip() error {
+	_, err := r.ReadRaw()
+	return err
+}
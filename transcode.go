@@ -0,0 +1,661 @@
+package erlpack
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// AtomRule is used to define how atoms are transcoded.
+type AtomRule int
+
+const (
+	// AtomRuleStrings is used to write atoms as JSON strings. When transcoding from JSON, only the strings in
+	// TranscodeOptions.AtomStrings become atoms.
+	AtomRuleStrings AtomRule = iota
+
+	// AtomRuleAnnotated is used to write atoms as {"$atom": "name"}. When transcoding from JSON, these objects become
+	// atoms.
+	AtomRuleAnnotated
+)
+
+// TupleRule is used to define how tuples are transcoded.
+type TupleRule int
+
+const (
+	// TuplesAsArrays is used to write tuples as JSON arrays. When transcoding from JSON, arrays only become tuples if
+	// TranscodeOptions.ArraysAsTuples is set.
+	TuplesAsArrays TupleRule = iota
+
+	// TuplesAnnotated is used to write tuples as {"$tuple": [...]}. When transcoding from JSON, these objects become
+	// tuples.
+	TuplesAnnotated
+)
+
+// InvalidUTF8Rule is used to define how binaries which are not valid UTF-8 are written as JSON.
+type InvalidUTF8Rule int
+
+const (
+	// InvalidUTF8Replace is used to replace the invalid bytes with U+FFFD, the same as encoding/json.
+	InvalidUTF8Replace InvalidUTF8Rule = iota
+
+	// InvalidUTF8Base64 is used to write the binary as {"$base64": "..."}. When transcoding from JSON, these objects
+	// become binaries. Map keys are written as plain base64 strings.
+	InvalidUTF8Base64
+
+	// InvalidUTF8Error is used to return a error.
+	InvalidUTF8Error
+)
+
+// BigIntegerRule is used to define how integers which can't be represented exactly by a float64 (larger than 2^53 - 1
+// or smaller than -(2^53 - 1)) are written as JSON.
+type BigIntegerRule int
+
+const (
+	// BigIntegersAsNumbers is used to write the integers as JSON numbers. Consumers such as JavaScript may lose precision.
+	BigIntegersAsNumbers BigIntegerRule = iota
+
+	// BigIntegersAsStrings is used to write the integers as JSON strings containing the number. This is what Discord
+	// does for snowflakes.
+	BigIntegersAsStrings
+)
+
+// Defines the largest integer which a float64 can represent exactly.
+const maxSafeInteger = 1<<53 - 1
+
+// TranscodeOptions is used to define the rules used by the transcoder. The zero value is the default behaviour used by
+// TranscodeToJSON and TranscodeFromJSON.
+type TranscodeOptions struct {
+	// Atoms is used to define how atoms other than true, false and nil are transcoded. true, false and nil are always
+	// the JSON true, false and null.
+	Atoms AtomRule
+
+	// Tuples is used to define how tuples are transcoded.
+	Tuples TupleRule
+
+	// InvalidUTF8 is used to define how binaries which are not valid UTF-8 are written as JSON.
+	InvalidUTF8 InvalidUTF8Rule
+
+	// BigIntegers is used to define how integers which don't fit in a float64 are written as JSON.
+	BigIntegers BigIntegerRule
+
+	// AtomKeys is used to pack JSON object keys as atoms rather than binaries.
+	AtomKeys bool
+
+	// AtomStrings is used to define JSON strings which are packed as atoms rather than binaries.
+	AtomStrings []string
+
+	// ArraysAsTuples is used to pack JSON arrays as tuples rather than lists.
+	ArraysAsTuples bool
+
+	// MaxInflatedSize is the largest a compressed term can be once it is inflated. If this is 0,
+	// DefaultMaxInflatedSize is used.
+	MaxInflatedSize int
+}
+
+// TranscodeToJSON is used to read a term from the reader and write it as JSON to the writer, without unpacking it into
+// Go values first. Maps are written as JSON objects in the order their pairs were packed, and their keys must be
+// binaries, atoms, integers or floats. Improper lists, funs and floats which aren't finite can't be written as JSON.
+func TranscodeToJSON(w io.Writer, r io.Reader) error {
+	return TranscodeToJSONWithOptions(w, r, TranscodeOptions{})
+}
+
+// TranscodeToJSONWithOptions is used to transcode a term to JSON with the options specified (see TranscodeToJSON).
+func TranscodeToJSONWithOptions(w io.Writer, r io.Reader, Options TranscodeOptions) error {
+	br := &byteReaderUpgrader{r}
+	if Version, err := br.ReadByte(); err != nil || Version != 131 {
+		return errors.New("invalid erlpack bytes")
+	}
+	t := &jsonTranscoder{w: bufio.NewWriter(w), opts: &Options}
+	if err := t.item(br); err != nil {
+		return err
+	}
+	return t.w.Flush()
+}
+
+// Used to write terms as JSON.
+type jsonTranscoder struct {
+	w     *bufio.Writer
+	opts  *TranscodeOptions
+	buf   []byte
+	depth int
+}
+
+// Used to read the type of data and transcode the term.
+func (t *jsonTranscoder) item(r unpackReader) error {
+	DataType, err := r.ReadByte()
+	if err != nil {
+		return errors.New("not long enough to include data type")
+	}
+	return t.term(DataType, r)
+}
+
+// Used to transcode a term when the type of data has already been read.
+func (t *jsonTranscoder) term(DataType byte, r unpackReader) error {
+	// Make sure the data isn't nested too deeply.
+	if t.depth++; t.depth > maxDepth {
+		return errors.New("data is nested too deeply")
+	}
+	defer func() { t.depth-- }()
+
+	switch DataType {
+	case 'P': // compressed
+		inflated, err := inflateTerm(r, t.opts.MaxInflatedSize)
+		if err != nil {
+			return err
+		}
+		return t.item(inflated)
+	case 's', 'd', 'v', 'w', 'm', 'a', 'b', 'n', 'o', 'F', 'c': // scalar
+		v, err := processScalar(DataType, r)
+		if err != nil {
+			return err
+		}
+		return t.scalar(v)
+	case 'j': // blank list
+		_, err := t.w.WriteString("[]")
+		return err
+	case 'l': // list
+		// Get the length of the list.
+		lengthBytes := make([]byte, 4)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return errors.New("not enough bytes for list length")
+		}
+		if err := t.items(binary.BigEndian.Uint32(lengthBytes), r); err != nil {
+			return err
+		}
+
+		// Make sure the list is proper.
+		tail, err := r.ReadByte()
+		if err != nil {
+			return errors.New("not enough bytes for list tail")
+		}
+		if tail != 'j' {
+			return errors.New("improper lists can't be written as JSON")
+		}
+		return nil
+	case 'k': // string (a list of small integers)
+		lengthBytes := make([]byte, 2)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return errors.New("not enough bytes for string length")
+		}
+		chars, err := readBytes(r, uint32(binary.BigEndian.Uint16(lengthBytes)))
+		if err != nil {
+			return errors.New("string length is longer than remainder of array")
+		}
+		b := append(t.buf[:0], '[')
+		for i, c := range chars {
+			if i != 0 {
+				b = append(b, ',')
+			}
+			b = strconv.AppendUint(b, uint64(c), 10)
+		}
+		t.buf = append(b, ']')
+		_, err = t.w.Write(t.buf)
+		return err
+	case 'h', 'i': // tuple
+		l, err := readTupleArity(DataType, r)
+		if err != nil {
+			return err
+		}
+		if t.opts.Tuples == TuplesAnnotated {
+			t.w.WriteString(`{"$tuple":`)
+		}
+		if err = t.items(l, r); err != nil {
+			return err
+		}
+		if t.opts.Tuples == TuplesAnnotated {
+			return t.w.WriteByte('}')
+		}
+		return nil
+	case 't': // map
+		lengthBytes := make([]byte, 4)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return errors.New("not enough bytes for map length")
+		}
+		l := binary.BigEndian.Uint32(lengthBytes)
+		t.w.WriteByte('{')
+		for i := uint32(0); i < l; i++ {
+			if i != 0 {
+				t.w.WriteByte(',')
+			}
+			if err := t.key(r); err != nil {
+				return err
+			}
+			t.w.WriteByte(':')
+			if err := t.item(r); err != nil {
+				return err
+			}
+		}
+		return t.w.WriteByte('}')
+	case 'q', 'p': // export or fun
+		return errors.New("funs can't be written as JSON")
+	case 'g', 'X', 'f', 'Y', 'x', 'e', 'r', 'Z': // pid, port or reference
+		return errors.New("pids, ports and references can't be written as JSON")
+	default: // Don't know this data type.
+		return errors.New("unknown data type")
+	}
+}
+
+// Used to transcode a number of items as a JSON array.
+func (t *jsonTranscoder) items(l uint32, r unpackReader) error {
+	t.w.WriteByte('[')
+	for i := uint32(0); i < l; i++ {
+		if i != 0 {
+			t.w.WriteByte(',')
+		}
+		if err := t.item(r); err != nil {
+			return err
+		}
+	}
+	return t.w.WriteByte(']')
+}
+
+// Used to write a scalar term unpacked by processScalar.
+func (t *jsonTranscoder) scalar(v interface{}) error {
+	b := t.buf[:0]
+	switch x := v.(type) {
+	case nil:
+		b = append(b, "null"...)
+	case bool:
+		b = strconv.AppendBool(b, x)
+	case Atom:
+		if t.opts.Atoms == AtomRuleAnnotated {
+			b = append(b, `{"$atom":`...)
+			b = appendJSONString(b, string(x))
+			b = append(b, '}')
+		} else {
+			b = appendJSONString(b, string(x))
+		}
+	case []byte:
+		if utf8.Valid(x) {
+			b = appendJSONString(b, string(x))
+			break
+		}
+		switch t.opts.InvalidUTF8 {
+		case InvalidUTF8Base64:
+			b = append(b, `{"$base64":"`...)
+			b = append(b, base64.StdEncoding.EncodeToString(x)...)
+			b = append(b, `"}`...)
+		case InvalidUTF8Error:
+			return errors.New("binary is not valid utf-8")
+		default:
+			b = appendJSONString(b, string(x))
+		}
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return &NonFiniteFloatError{Value: x}
+		}
+		f, err := json.Marshal(x)
+		if err != nil {
+			return err
+		}
+		b = append(b, f...)
+	default:
+		n := integerText(x)
+		if t.opts.BigIntegers == BigIntegersAsStrings && !isSafeInteger(x) {
+			b = appendJSONString(b, n)
+		} else {
+			b = append(b, n...)
+		}
+	}
+	t.buf = b
+	_, err := t.w.Write(b)
+	return err
+}
+
+// Used to write a map key as a JSON string.
+func (t *jsonTranscoder) key(r unpackReader) error {
+	DataType, err := r.ReadByte()
+	if err != nil {
+		return errors.New("not long enough to include data type")
+	}
+	var s string
+	switch DataType {
+	case 's', 'd', 'v', 'w', 'm', 'a', 'b', 'n', 'o', 'F', 'c':
+		v, err := processScalar(DataType, r)
+		if err != nil {
+			return err
+		}
+		switch x := v.(type) {
+		case nil:
+			s = "nil"
+		case bool:
+			s = strconv.FormatBool(x)
+		case Atom:
+			s = string(x)
+		case []byte:
+			s = string(x)
+			if !utf8.Valid(x) {
+				switch t.opts.InvalidUTF8 {
+				case InvalidUTF8Base64:
+					s = base64.StdEncoding.EncodeToString(x)
+				case InvalidUTF8Error:
+					return errors.New("binary is not valid utf-8")
+				}
+			}
+		case float64:
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				return &NonFiniteFloatError{Value: x}
+			}
+			s = strconv.FormatFloat(x, 'g', -1, 64)
+		default:
+			s = integerText(x)
+		}
+	default:
+		return errors.New("map key can't be written as JSON")
+	}
+	t.buf = appendJSONString(t.buf[:0], s)
+	_, err = t.w.Write(t.buf)
+	return err
+}
+
+// Used to get the decimal text of a integer unpacked by processScalar.
+func integerText(Integer interface{}) string {
+	switch x := Integer.(type) {
+	case uint8:
+		return strconv.FormatUint(uint64(x), 10)
+	case int32:
+		return strconv.FormatInt(int64(x), 10)
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case uint64:
+		return strconv.FormatUint(x, 10)
+	default:
+		return Integer.(*big.Int).String()
+	}
+}
+
+// Used to check if a integer unpacked by processScalar can be represented exactly by a float64.
+func isSafeInteger(Integer interface{}) bool {
+	switch x := Integer.(type) {
+	case uint8, int32:
+		return true
+	case int64:
+		return x >= -maxSafeInteger && x <= maxSafeInteger
+	case uint64:
+		return x <= maxSafeInteger
+	default:
+		return false
+	}
+}
+
+// Used to append a string to the bytes as a JSON string. Invalid UTF-8 is replaced with U+FFFD.
+func appendJSONString(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	b = append(b, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				b = append(b, '\\', c)
+			case c == '\n':
+				b = append(b, '\\', 'n')
+			case c == '\r':
+				b = append(b, '\\', 'r')
+			case c == '\t':
+				b = append(b, '\\', 't')
+			case c < 0x20:
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			default:
+				b = append(b, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			b = append(b, "\ufffd"...)
+		case r == '\u2028' || r == '\u2029':
+			// These are valid JSON but not valid JavaScript.
+			b = append(b, '\\', 'u', '2', '0', '2', hex[r&0xf])
+		default:
+			b = append(b, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(b, '"')
+}
+
+// TranscodeFromJSON is used to read a JSON value from the reader and write it as a term (including the version byte)
+// to the writer, without unpacking it into Go values first. Objects are packed as maps in the order their keys were
+// written, strings as binaries, null as nil, and numbers as integers if they have no fraction or exponent, or floats
+// otherwise. The reader must only contain the one JSON value.
+func TranscodeFromJSON(w io.Writer, r io.Reader) error {
+	return TranscodeFromJSONWithOptions(w, r, TranscodeOptions{})
+}
+
+// TranscodeFromJSONWithOptions is used to transcode a JSON value to a term with the options specified (see
+// TranscodeFromJSON).
+func TranscodeFromJSONWithOptions(w io.Writer, r io.Reader, Options TranscodeOptions) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	t := &termTranscoder{dec: dec, opts: &Options}
+	if len(Options.AtomStrings) != 0 {
+		t.atoms = make(map[string]struct{}, len(Options.AtomStrings))
+		for _, s := range Options.AtomStrings {
+			t.atoms[s] = struct{}{}
+		}
+	}
+
+	// Transcode the value.
+	b, err := t.value([]byte{131})
+	if err != nil {
+		return err
+	}
+
+	// Make sure nothing follows the value.
+	if _, err = dec.Token(); err != io.EOF {
+		if err == nil {
+			err = errors.New("unexpected data after JSON value")
+		}
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Used to write JSON values as terms. JSON does not contain the lengths needed for lists and maps, so the term is
+// built in memory and the lengths are filled in as each list or map ends.
+type termTranscoder struct {
+	dec   *json.Decoder
+	opts  *TranscodeOptions
+	atoms map[string]struct{}
+	depth int
+}
+
+// Used to read the next JSON value and append it to the bytes.
+func (t *termTranscoder) value(b []byte) ([]byte, error) {
+	tok, err := t.dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return t.token(b, tok)
+}
+
+// Used to append the JSON value which starts with the token specified.
+func (t *termTranscoder) token(b []byte, tok json.Token) ([]byte, error) {
+	// Make sure the data isn't nested too deeply.
+	if t.depth++; t.depth > maxDepth {
+		return nil, errors.New("data is nested too deeply")
+	}
+	defer func() { t.depth-- }()
+
+	switch x := tok.(type) {
+	case nil:
+		return AppendNil(b), nil
+	case bool:
+		return AppendBool(b, x), nil
+	case string:
+		if _, ok := t.atoms[x]; ok {
+			return AppendAtom(b, Atom(x)), nil
+		}
+		return AppendString(b, x), nil
+	case json.Number:
+		return appendJSONNumber(b, x)
+	case json.Delim:
+		if x == '[' {
+			if t.opts.ArraysAsTuples {
+				return t.tuple(b)
+			}
+			return t.list(b)
+		}
+		return t.object(b)
+	default:
+		return nil, errors.New("unexpected JSON token")
+	}
+}
+
+// Used to append a JSON number as a integer or float.
+func appendJSONNumber(b []byte, n json.Number) ([]byte, error) {
+	s := string(n)
+	if !strings.ContainsAny(s, ".eE") {
+		if i, err := n.Int64(); err == nil {
+			return AppendInt(b, i), nil
+		}
+		if x, ok := new(big.Int).SetString(s, 10); ok {
+			pad := newScratchpad(16)
+			packBigInt(x, pad)
+			return append(b, pad.bytes()...), nil
+		}
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
+	}
+	return AppendFloat(b, f)
+}
+
+// Used to append the rest of a JSON array as a list.
+func (t *termTranscoder) list(b []byte) ([]byte, error) {
+	start := len(b)
+	b = append(b, 'l', 0, 0, 0, 0)
+	l, b, err := t.items(b)
+	if err != nil {
+		return nil, err
+	}
+	if l == 0 {
+		return append(b[:start], 'j'), nil
+	}
+	binary.BigEndian.PutUint32(b[start+1:], l)
+	return append(b, 'j'), nil
+}
+
+// Used to append the rest of a JSON array as a tuple.
+func (t *termTranscoder) tuple(b []byte) ([]byte, error) {
+	start := len(b)
+	b = append(b, 'i', 0, 0, 0, 0)
+	l, b, err := t.items(b)
+	if err != nil {
+		return nil, err
+	}
+	if l > 255 {
+		binary.BigEndian.PutUint32(b[start+1:], l)
+		return b, nil
+	}
+
+	// Use SMALL_TUPLE_EXT, moving the items to after the 1 byte arity.
+	b[start], b[start+1] = 'h', byte(l)
+	return append(b[:start+2], b[start+5:]...), nil
+}
+
+// Used to append the items of a JSON array until it ends, returning the number of items.
+func (t *termTranscoder) items(b []byte) (uint32, []byte, error) {
+	l := uint32(0)
+	for t.dec.More() {
+		var err error
+		if b, err = t.value(b); err != nil {
+			return 0, nil, err
+		}
+		l++
+	}
+	if _, err := t.dec.Token(); err != nil {
+		return 0, nil, err
+	}
+	return l, b, nil
+}
+
+// Used to append the rest of a JSON object. This is a map unless it is a annotation enabled by the options.
+func (t *termTranscoder) object(b []byte) ([]byte, error) {
+	start := len(b)
+	b = append(b, 't', 0, 0, 0, 0)
+	l := uint32(0)
+	for t.dec.More() {
+		tok, err := t.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+
+		// If the first key is a annotation, the object describes a single term.
+		if l == 0 {
+			annotated, ok, err := t.annotation(b[:start], key)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				if t.dec.More() {
+					return nil, errors.New("annotated objects must only have 1 key")
+				}
+				if _, err = t.dec.Token(); err != nil {
+					return nil, err
+				}
+				return annotated, nil
+			}
+		}
+
+		// Append the key and value.
+		if t.opts.AtomKeys {
+			b = AppendAtom(b, Atom(key))
+		} else {
+			b = AppendString(b, key)
+		}
+		if b, err = t.value(b); err != nil {
+			return nil, err
+		}
+		l++
+	}
+	if _, err := t.dec.Token(); err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint32(b[start+1:], l)
+	return b, nil
+}
+
+// Used to append the value of a annotation if the key is one enabled by the options.
+func (t *termTranscoder) annotation(b []byte, key string) ([]byte, bool, error) {
+	switch {
+	case key == "$atom" && t.opts.Atoms == AtomRuleAnnotated:
+		var s string
+		if err := t.dec.Decode(&s); err != nil {
+			return nil, false, err
+		}
+		return AppendAtom(b, Atom(s)), true, nil
+	case key == "$tuple" && t.opts.Tuples == TuplesAnnotated:
+		if tok, err := t.dec.Token(); err != nil || tok != json.Delim('[') {
+			return nil, false, errors.New("expected array for tuple")
+		}
+		b, err := t.tuple(b)
+		return b, err == nil, err
+	case key == "$base64" && t.opts.InvalidUTF8 == InvalidUTF8Base64:
+		var data []byte
+		if err := t.dec.Decode(&data); err != nil {
+			return nil, false, err
+		}
+		return AppendBinary(b, data), true, nil
+	default:
+		return nil, false, nil
+	}
+}
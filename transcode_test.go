@@ -0,0 +1,157 @@
+package erlpack
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// TestTranscodeToJSON is used to test transcoding terms to JSON with the different rules.
+func TestTranscodeToJSON(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	term := OrderedMap{
+		{Key: "op", Value: 0},
+		{Key: Atom("t"), Value: Atom("READY")},
+		{Key: 1, Value: Tuple{true, nil, 1.5}},
+		{Key: "d", Value: []interface{}{[]byte("a\"\n\x01"), []byte{0xff, 'a'}, int64(1) << 60, huge, []interface{}{}}},
+	}
+	tests := []struct {
+		name     string
+		opts     TranscodeOptions
+		expected string
+	}{
+		{
+			name:     "defaults",
+			expected: `{"op":0,"t":"READY","1":[true,null,1.5],"d":["a\"\n\u0001","` + "�" + `a",1152921504606846976,123456789012345678901234567890,[]]}`,
+		},
+		{
+			name: "annotated",
+			opts: TranscodeOptions{
+				Atoms: AtomRuleAnnotated, Tuples: TuplesAnnotated,
+				InvalidUTF8: InvalidUTF8Base64, BigIntegers: BigIntegersAsStrings,
+			},
+			expected: `{"op":0,"t":{"$atom":"READY"},"1":{"$tuple":[true,null,1.5]},"d":["a\"\n\u0001",{"$base64":"/2E="},"1152921504606846976","123456789012345678901234567890",[]]}`,
+		},
+	}
+	b, err := Pack(term)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		buf := &bytes.Buffer{}
+		if err := TranscodeToJSONWithOptions(buf, bytes.NewReader(b), tt.opts); err != nil {
+			t.Fatal(tt.name, err)
+		}
+		if buf.String() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, buf.String())
+		}
+	}
+
+	// Check the terms which can't be written as JSON.
+	for _, v := range []interface{}{
+		ImproperList{Items: []interface{}{1}, Tail: 2},
+		Export{Module: "lists", Function: "map", Arity: 2},
+		OrderedMap{{Key: Tuple{}, Value: 1}},
+	} {
+		b, err := Pack(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = TranscodeToJSON(&bytes.Buffer{}, bytes.NewReader(b)); err == nil {
+			t.Errorf("%#v: expected error", v)
+		}
+	}
+	b, _ = Pack([]byte{0xff})
+	if err = TranscodeToJSONWithOptions(&bytes.Buffer{}, bytes.NewReader(b), TranscodeOptions{InvalidUTF8: InvalidUTF8Error}); err == nil {
+		t.Error("expected error for invalid utf-8")
+	}
+	if err = TranscodeToJSON(&bytes.Buffer{}, bytes.NewReader([]byte("\x83l\x00\x00\x00\x01a\x01"))); err == nil {
+		t.Error("expected error for list without a tail")
+	}
+}
+
+// TestTranscodeFromJSON is used to test transcoding JSON to terms with the different rules.
+func TestTranscodeFromJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		opts     TranscodeOptions
+		expected interface{}
+	}{
+		{
+			name: "defaults",
+			json: `{"op": 0, "d": [null, true, "ok", 1.5, 1e2, -300, 18446744073709551616, {"$atom": "x"}], "e": []}`,
+			expected: OrderedMap{
+				{Key: "op", Value: 0},
+				{Key: "d", Value: []interface{}{nil, true, []byte("ok"), 1.5, 100.0, -300, new(big.Int).Lsh(big.NewInt(1), 64),
+					OrderedMap{{Key: "$atom", Value: []byte("x")}}}},
+				{Key: "e", Value: []interface{}{}},
+			},
+		},
+		{
+			name: "rules",
+			json: `{"status": ["ok", "fine"], "t": {"$atom": "x"}, "b": {"$base64": "/w=="}, "u": {"$tuple": []}}`,
+			opts: TranscodeOptions{
+				AtomKeys: true, AtomStrings: []string{"ok"}, ArraysAsTuples: true,
+				Atoms: AtomRuleAnnotated, Tuples: TuplesAnnotated, InvalidUTF8: InvalidUTF8Base64,
+			},
+			expected: OrderedMap{
+				{Key: Atom("status"), Value: Tuple{Atom("ok"), []byte("fine")}},
+				{Key: Atom("t"), Value: Atom("x")},
+				{Key: Atom("b"), Value: []byte{0xff}},
+				{Key: Atom("u"), Value: Tuple{}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		buf := &bytes.Buffer{}
+		if err := TranscodeFromJSONWithOptions(buf, strings.NewReader(tt.json), tt.opts); err != nil {
+			t.Fatal(tt.name, err)
+		}
+		expected, err := Pack(tt.expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), expected) {
+			t.Errorf("%s: expected % x, got % x", tt.name, expected, buf.Bytes())
+		}
+	}
+
+	// Tuples with more than 255 items use LARGE_TUPLE_EXT.
+	buf := &bytes.Buffer{}
+	json := "[" + strings.Repeat("0,", 299) + "0]"
+	if err := TranscodeFromJSONWithOptions(buf, strings.NewReader(json), TranscodeOptions{ArraysAsTuples: true}); err != nil {
+		t.Fatal(err)
+	}
+	large := make(Tuple, 300)
+	for i := range large {
+		large[i] = 0
+	}
+	if expected, _ := Pack(large); !bytes.Equal(buf.Bytes(), expected) || expected[1] != 'i' {
+		t.Errorf("unexpected large tuple: % x", buf.Bytes()[:8])
+	}
+
+	for _, invalid := range []string{``, `[1`, `{"a": 1} 2`, `{"$atom": "a", "b": 1}`} {
+		err := TranscodeFromJSONWithOptions(&bytes.Buffer{}, strings.NewReader(invalid), TranscodeOptions{Atoms: AtomRuleAnnotated})
+		if err == nil {
+			t.Errorf("%q: expected error", invalid)
+		}
+	}
+}
+
+// TestTranscodeRoundTrip is used to test JSON transcoded to a term and back is unchanged.
+func TestTranscodeRoundTrip(t *testing.T) {
+	const json = `{"t":"MESSAGE_CREATE","s":42,"op":0,"d":{"id":"1234","mentions":[],"pinned":false,"nonce":null,"x":0.25}}`
+	term := &bytes.Buffer{}
+	if err := TranscodeFromJSON(term, strings.NewReader(json)); err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	if err := TranscodeToJSON(out, term); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != json {
+		t.Fatalf("expected %s, got %s", json, out.String())
+	}
+}
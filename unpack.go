@@ -5,9 +5,12 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"errors"
-	"github.com/jakemakesstuff/structs"
 	"io"
+	"math"
+	"math/big"
 	"reflect"
+	"strconv"
+	"strings"
 	"unsafe"
 )
 
@@ -24,53 +27,192 @@ var uncastedResultType = reflect.TypeOf((*UncastedResult)(nil))
 // Atom is used to define an atom within the codebase.
 type Atom string
 
+// Tuple is used to define a tuple within the codebase.
+type Tuple []interface{}
+
 // RawData is used to define data which was within an Erlpack array but has not been parsed yet.
 // This is different to UncastedResult since it has not been processed yet.
 type RawData []byte
 
 // Cast is used to cast the result to a pointer.
 func (r RawData) Cast(Ptr interface{}) error {
+	return r.CastWithOptions(Ptr, DecoderOptions{})
+}
+
+// CastWithOptions is used to cast the result to a pointer with the decoder options specified.
+func (r RawData) CastWithOptions(Ptr interface{}, Options DecoderOptions) error {
 	v := &pointerSetter{ptr: reflect.ValueOf(Ptr)}
-	if v.ptr.Kind() != reflect.Ptr {
-		return errors.New("invalid pointer")
+	if err := v.check(); err != nil {
+		return err
 	}
-	return processItem(v, bytes.NewReader(r))
+	return processItem(v, bytes.NewReader(r), &Options)
 }
 
 // UncastedResult is used to define a result which has not been casted yet.
 // You can call Cast on this to cast the item after the initial unpacking.
 type UncastedResult struct {
 	item interface{}
+	opts *DecoderOptions
 }
 
 // Cast is used to cast the result to a pointer.
 func (u *UncastedResult) Cast(Ptr interface{}) error {
 	v := &pointerSetter{ptr: reflect.ValueOf(Ptr)}
-	if v.ptr.Kind() != reflect.Ptr {
-		return errors.New("invalid pointer")
+	if err := v.check(); err != nil {
+		return err
+	}
+	opts := u.opts
+	if opts == nil {
+		opts = &DecoderOptions{}
 	}
-	return handleItemCasting(u.item, v)
+	return handleItemCasting(u.item, v, opts)
 }
 
 // Used to cast the item.
-func handleItemCasting(Item interface{}, setter *pointerSetter) error {
+func handleItemCasting(Item interface{}, setter *pointerSetter, opts *DecoderOptions) error {
 	// Get the base pointer.
-	Ptr := setter.getBasePtr()
+	Ptr, err := setter.getBasePtr()
+	if err != nil {
+		return err
+	}
 
 	// Handle a interface or uncasted result.
 	switch Ptr.(type) {
 	case *interface{}:
+		Item, err = genericItem(Item, opts)
+		if err != nil {
+			return err
+		}
 		return setter.set(reflect.ValueOf(&Item))
 	case *UncastedResult:
-		return setter.set(reflect.ValueOf(&UncastedResult{item: Item}))
+		return setter.set(reflect.ValueOf(&UncastedResult{item: Item, opts: opts}))
+	case *RawData, rawDataSetter, Unmarshaler:
+		// Pack the item again to get the raw data.
+		b, err := PackWithOptions(Item, EncoderOptions{Deterministic: true})
+		if err != nil {
+			return err
+		}
+		return setRawData(setter, Ptr, b[1:], opts)
+	case *big.Int:
+		if n, ok := bigIntFromTerm(Item); ok {
+			return setter.set(reflect.ValueOf(n))
+		}
+	case *Number:
+		switch x := Item.(type) {
+		case Number:
+			return setter.set(reflect.ValueOf(&x))
+		case uint8, int32, int64, uint64, *big.Int, float64:
+			n := numberFromTerm(x)
+			return setter.set(reflect.ValueOf(&n))
+		}
+	}
+
+	// Handle types defined with a basic kind (such as type Opcode int) by casting into the kind and converting.
+	// Builtin types other than int8, int16, uint16 and uint32 are handled below.
+	if kindType, ok := basicKindTypes[reflect.TypeOf(Ptr).Elem().Kind()]; ok && Item != nil {
+		switch e := reflect.TypeOf(Ptr).Elem(); Ptr.(type) {
+		case *Atom, *Number, *MapKey:
+		default:
+			switch e.Kind() {
+			case reflect.Int8, reflect.Int16, reflect.Uint16, reflect.Uint32:
+				return castByKind(Item, setter, e, kindType, opts)
+			}
+			if e.PkgPath() != "" {
+				return castByKind(Item, setter, e, kindType, opts)
+			}
+		}
 	}
 
 	// Handle specific type casting.
 	switch x := Item.(type) {
+	case Number:
+		// Cast the integer or float instead.
+		term, err := x.term()
+		if err != nil {
+			return err
+		}
+		return handleItemCasting(term, setter, opts)
+	case map[string]interface{}:
+		// Cast the map with interface keys instead.
+		m := make(map[interface{}]interface{}, len(x))
+		for k, v := range x {
+			m[k] = v
+		}
+		return handleItemCasting(m, setter, opts)
+	case *big.Int:
+		return errors.New("could not de-serialize big integer")
+	case ImproperList:
+		switch Ptr.(type) {
+		case *ImproperList:
+			return setter.set(reflect.ValueOf(&x))
+		default:
+			return errors.New("could not de-serialize into improper list")
+		}
 	case Atom:
 		switch Ptr.(type) {
 		case *Atom:
 			return setter.set(reflect.ValueOf(&x))
+		case *string:
+			p := string(x)
+			return setter.set(reflect.ValueOf(&p))
+		case *float64:
+			if f, ok := nonFiniteFloat(x); ok && opts.AtomsAsNonFiniteFloats {
+				return setter.set(reflect.ValueOf(&f))
+			}
+		case *float32:
+			if f, ok := nonFiniteFloat(x); ok && opts.AtomsAsNonFiniteFloats {
+				p := float32(f)
+				return setter.set(reflect.ValueOf(&p))
+			}
+		}
+	case Tuple:
+		switch Ptr.(type) {
+		case *Tuple:
+			return setter.set(reflect.ValueOf(&x))
+		default:
+			return errors.New("could not de-serialize into tuple")
+		}
+	case MapKey:
+		// Cast the original term instead.
+		term, err := x.Term()
+		if err != nil {
+			return err
+		}
+		return handleItemCasting(term, setter, opts)
+	case Export:
+		switch Ptr.(type) {
+		case *Export:
+			return setter.set(reflect.ValueOf(&x))
+		default:
+			return errors.New("could not de-serialize into export")
+		}
+	case Fun:
+		switch Ptr.(type) {
+		case *Fun:
+			return setter.set(reflect.ValueOf(&x))
+		default:
+			return errors.New("could not de-serialize into fun")
+		}
+	case Pid:
+		switch Ptr.(type) {
+		case *Pid:
+			return setter.set(reflect.ValueOf(&x))
+		default:
+			return errors.New("could not de-serialize into pid")
+		}
+	case Port:
+		switch Ptr.(type) {
+		case *Port:
+			return setter.set(reflect.ValueOf(&x))
+		default:
+			return errors.New("could not de-serialize into port")
+		}
+	case Reference:
+		switch Ptr.(type) {
+		case *Reference:
+			return setter.set(reflect.ValueOf(&x))
+		default:
+			return errors.New("could not de-serialize into reference")
 		}
 	case int64:
 		switch Ptr.(type) {
@@ -79,9 +221,37 @@ func handleItemCasting(Item interface{}, setter *pointerSetter) error {
 			return setter.set(reflect.ValueOf(&p))
 		case *int64:
 			return setter.set(reflect.ValueOf(&x))
+		case *int32:
+			if x < math.MinInt32 || x > math.MaxInt32 {
+				return errors.New("int is too large for int32")
+			}
+			p := int32(x)
+			return setter.set(reflect.ValueOf(&p))
+		case *uint8:
+			if x < 0 || x > math.MaxUint8 {
+				return errors.New("int is too large for uint8")
+			}
+			p := uint8(x)
+			return setter.set(reflect.ValueOf(&p))
+		case *uint64:
+			if 0 > x {
+				return errors.New("could not de-serialize negative int into uint64")
+			}
+			p := uint64(x)
+			return setter.set(reflect.ValueOf(&p))
 		default:
 			return errors.New("could not de-serialize into int")
 		}
+	case uint64:
+		switch Ptr.(type) {
+		case *uint64:
+			return setter.set(reflect.ValueOf(&x))
+		case *uint:
+			p := uint(x)
+			return setter.set(reflect.ValueOf(&p))
+		default:
+			return errors.New("could not de-serialize into uint64")
+		}
 	case int32:
 		switch Ptr.(type) {
 		case *int:
@@ -89,6 +259,15 @@ func handleItemCasting(Item interface{}, setter *pointerSetter) error {
 			return setter.set(reflect.ValueOf(&p))
 		case *int32:
 			return setter.set(reflect.ValueOf(&x))
+		case *int64:
+			p := int64(x)
+			return setter.set(reflect.ValueOf(&p))
+		case *uint64:
+			if 0 > x {
+				return errors.New("could not de-serialize negative int into uint64")
+			}
+			p := uint64(x)
+			return setter.set(reflect.ValueOf(&p))
 		default:
 			return errors.New("could not de-serialize into int")
 		}
@@ -96,6 +275,9 @@ func handleItemCasting(Item interface{}, setter *pointerSetter) error {
 		switch Ptr.(type) {
 		case *float64:
 			return setter.set(reflect.ValueOf(&x))
+		case *float32:
+			p := float32(x)
+			return setter.set(reflect.ValueOf(&p))
 		default:
 			return errors.New("could not de-serialize into float64")
 		}
@@ -109,15 +291,30 @@ func handleItemCasting(Item interface{}, setter *pointerSetter) error {
 		case *int:
 			p := int(x)
 			return setter.set(reflect.ValueOf(&p))
+		case *int32:
+			p := int32(x)
+			return setter.set(reflect.ValueOf(&p))
+		case *int64:
+			p := int64(x)
+			return setter.set(reflect.ValueOf(&p))
+		case *uint64:
+			p := uint64(x)
+			return setter.set(reflect.ValueOf(&p))
 		default:
 			return errors.New("could not de-serialize into uint8")
 		}
 	case string:
-		// Map key.
+		// Map key, or a binary or atom unpacked with BinariesAsStrings or AtomsAsStrings.
 		switch Ptr.(type) {
 		case *string:
 			p := x
 			return setter.set(reflect.ValueOf(&p))
+		case *[]byte:
+			p := []byte(x)
+			return setter.set(reflect.ValueOf(&p))
+		case *Atom:
+			p := Atom(x)
+			return setter.set(reflect.ValueOf(&p))
 		default:
 			return errors.New("could not de-serialize into string")
 		}
@@ -167,14 +364,25 @@ func handleItemCasting(Item interface{}, setter *pointerSetter) error {
 			return setter.set(reflect.ValueOf(&x))
 		default:
 			// Get the reflect value.
-			r := reflect.MakeSlice(reflect.ValueOf(Ptr).Type().Elem(), len(x), len(x))
+			var r reflect.Value
+			switch e := reflect.ValueOf(Ptr).Type().Elem(); e.Kind() {
+			case reflect.Slice:
+				r = reflect.MakeSlice(e, len(x), len(x))
+			case reflect.Array:
+				if len(x) > e.Len() {
+					return errors.New("list is longer than the array")
+				}
+				r = reflect.New(e).Elem()
+			default:
+				return errors.New("could not de-serialize list")
+			}
 
 			// Set all the items.
 			for i, v := range x {
 				indexItem := r.Index(i)
 				x := reflect.New(indexItem.Type())
 				t := x.Interface()
-				err := handleItemCasting(v, &pointerSetter{ptr: reflect.ValueOf(t)})
+				err := handleItemCasting(v, &pointerSetter{ptr: reflect.ValueOf(t)}, opts)
 				if err != nil {
 					return err
 				}
@@ -192,6 +400,13 @@ func handleItemCasting(Item interface{}, setter *pointerSetter) error {
 		case *map[interface{}]interface{}:
 			// This is the first thing we check for since it is by far the best situation.
 			return setter.set(reflect.ValueOf(&x))
+		case *OrderedMap:
+			// The original order is lost at this point, so sort the items to make the result predictable.
+			o, err := orderedMapFromMap(x)
+			if err != nil {
+				return err
+			}
+			return setter.set(reflect.ValueOf(&o))
 		}
 
 		// Check the type of the pointer.
@@ -216,48 +431,33 @@ func handleItemCasting(Item interface{}, setter *pointerSetter) error {
 					return errors.New("result is not error")
 				}
 				f := function.Interface().(func(*UncastedResult) error)
-				return f(&UncastedResult{item: Item})
-			}
-
-			// Get the struct object.
-			s := structs.New(i.Interface())
-			s.TagName = "erlpack"
-
-			// Set tag > field.
-			tag2field := map[string]string{}
-			for _, field := range s.Fields() {
-				t := field.Tag("erlpack")
-				if t != "-" {
-					if t == "" {
-						tag2field[field.Name()] = field.Name()
-						continue
-					}
-					tag2field[t] = field.Name()
+				if err := f(&UncastedResult{item: Item, opts: opts}); err != nil {
+					return err
 				}
+				return setter.set(i)
 			}
 
+			// Get the fields by their keys.
+			fields := structFieldsByKey(e)
+
 			// Iterate through the map.
 			for k, v := range x {
+				if a, ok := k.(Atom); ok {
+					k = string(a)
+				}
 				switch str := k.(type) {
 				case string:
-					fieldName, ok := tag2field[str]
+					index, ok := fields[str]
 					if !ok {
 						continue
 					}
-					field, ok := s.FieldOk(fieldName)
-					if !ok {
-						return errors.New("failed to get field")
-					}
+					field := i.Elem().Field(index)
 					r := reflect.New(field.Type())
-					x := r.Interface()
-					err := handleItemCasting(v, &pointerSetter{ptr: reflect.ValueOf(x)})
-					if err != nil {
-						return err
-					}
-					err = field.Set(r.Elem().Interface())
+					err := handleItemCasting(v, &pointerSetter{ptr: r}, opts)
 					if err != nil {
 						return err
 					}
+					field.Set(r.Elem())
 				default:
 					return errors.New("key must be string")
 				}
@@ -285,7 +485,7 @@ func handleItemCasting(Item interface{}, setter *pointerSetter) error {
 				pptr.Elem().Set(reflectKey)
 
 				// Handle the item casting for the key.
-				err := handleItemCasting(k, &pointerSetter{ptr: pptr})
+				err := handleItemCasting(k, &pointerSetter{ptr: pptr}, opts)
 				if err != nil {
 					return err
 				}
@@ -297,7 +497,7 @@ func handleItemCasting(Item interface{}, setter *pointerSetter) error {
 				pptr.Elem().Set(reflectValue)
 
 				// Handle the item casting for the value.
-				err = handleItemCasting(v, &pointerSetter{ptr: pptr})
+				err = handleItemCasting(v, &pointerSetter{ptr: pptr}, opts)
 				if err != nil {
 					return err
 				}
@@ -320,83 +520,333 @@ func handleItemCasting(Item interface{}, setter *pointerSetter) error {
 	return errors.New("unable to unpack to pointer specified")
 }
 
-// Used to process an atom during unpacking.
-func processAtom(Data []byte) interface{} {
-	matchRest := func(d []byte) bool {
-		if len(d) > len(Data)-1 {
-			return false
+// Defines the type which is cast into for each basic kind in castByKind.
+var basicKindTypes = map[reflect.Kind]reflect.Type{
+	reflect.Int:     reflect.TypeOf(int64(0)),
+	reflect.Int8:    reflect.TypeOf(int64(0)),
+	reflect.Int16:   reflect.TypeOf(int64(0)),
+	reflect.Int32:   reflect.TypeOf(int64(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint64(0)),
+	reflect.Uint8:   reflect.TypeOf(uint64(0)),
+	reflect.Uint16:  reflect.TypeOf(uint64(0)),
+	reflect.Uint32:  reflect.TypeOf(uint64(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Float32: reflect.TypeOf(float64(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+	reflect.String:  reflect.TypeOf(""),
+	reflect.Bool:    reflect.TypeOf(false),
+}
+
+// Used to cast the item into the type of the kind specified, then convert it to the target type. An error is
+// returned if the value overflows the target type.
+func castByKind(Item interface{}, setter *pointerSetter, e, kindType reflect.Type, opts *DecoderOptions) error {
+	v := reflect.New(kindType)
+	if err := handleItemCasting(Item, &pointerSetter{ptr: v}, opts); err != nil {
+		return err
+	}
+	p := reflect.New(e)
+	switch e.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if p.Elem().OverflowInt(v.Elem().Int()) {
+			return errors.New("int is too large for " + e.String())
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if p.Elem().OverflowUint(v.Elem().Uint()) {
+			return errors.New("int is too large for " + e.String())
+		}
+	}
+	p.Elem().Set(v.Elem().Convert(e))
+	return setter.set(p)
+}
+
+// Used to apply the decoder options for unpacking into a interface{} to a item. Items within lists, tuples and maps
+// have already been unpacked into a interface{}, so only the item itself needs changing.
+func genericItem(Item interface{}, opts *DecoderOptions) (interface{}, error) {
+	switch x := Item.(type) {
+	case []byte:
+		if opts.BinariesAsStrings {
+			return string(x), nil
+		}
+	case Atom:
+		if opts.AtomsAsStrings {
+			return string(x), nil
+		}
+	case uint8, int32, int64, uint64, *big.Int, float64:
+		if opts.UseNumber {
+			return numberFromTerm(x), nil
 		}
-		for i := 0; i < len(d); i++ {
-			if Data[i+1] != d[i] {
-				return false
+		if opts.IntegersAsInt64 {
+			switch i := x.(type) {
+			case uint8:
+				return int64(i), nil
+			case int32:
+				return int64(i), nil
 			}
 		}
-		return true
+	case map[interface{}]interface{}:
+		if opts.MapsAsStringKeyed {
+			m := make(map[string]interface{}, len(x))
+			for k, v := range x {
+				str, ok := k.(string)
+				if !ok {
+					return nil, errors.New("map key must be string")
+				}
+				m[str] = v
+			}
+			return m, nil
+		}
 	}
-	switch Data[0] {
-	case 't':
-		matched := matchRest([]byte("rue"))
-		if !matched {
-			return Atom(Data)
+	return Item, nil
+}
+
+// Implemented by types which are given the raw data of a term when they are unpacked (such as Lazy).
+type rawDataSetter interface {
+	setRawData(RawData, *DecoderOptions)
+}
+
+// Used to set raw data to the pointer, or unmarshal it if the pointer is a Unmarshaler.
+func setRawData(setter *pointerSetter, Ptr interface{}, raw RawData, opts *DecoderOptions) error {
+	switch Ptr.(type) {
+	case *RawData:
+		return setter.set(reflect.ValueOf(&raw))
+	case rawDataSetter:
+		v := reflect.New(reflect.TypeOf(Ptr).Elem())
+		v.Interface().(rawDataSetter).setRawData(raw, opts)
+		return setter.set(v)
+	default:
+		v := reflect.New(reflect.TypeOf(Ptr).Elem())
+		if err := v.Interface().(Unmarshaler).UnmarshalErlpack(raw); err != nil {
+			return err
 		}
-		return true
-	case 'f':
-		matched := matchRest([]byte("alse"))
-		if !matched {
-			return Atom(Data)
+		return setter.set(v)
+	}
+}
+
+// Used to get the index of each struct field by the key it is unpacked from.
+func structFieldsByKey(e reflect.Type) map[string]int {
+	fields := map[string]int{}
+	for i := 0; i < e.NumField(); i++ {
+		field := e.Field(i)
+		if field.PkgPath != "" {
+			continue
 		}
-		return false
-	case 'n':
-		matched := matchRest([]byte("il"))
-		if !matched {
-			return Atom(Data)
+		t := field.Tag.Get("erlpack")
+		if t == "-" {
+			continue
+		}
+		name := strings.Split(t, ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = i
+	}
+	return fields
+}
+
+// Used to process a map straight into a struct. Each value is unpacked into the field, so fields which want raw data
+// get the original bytes.
+func processStruct(setter *pointerSetter, e reflect.Type, l uint32, r unpackReader, opts *DecoderOptions) error {
+	// Make the new struct and get the fields by their keys.
+	v := reflect.New(e)
+	fields := structFieldsByKey(e)
+
+	// Get each item from the map.
+	for i := uint32(0); i < l; i++ {
+		// Get the key.
+		var Key interface{}
+		err := processItem(&pointerSetter{ptr: reflect.ValueOf(&Key)}, r, opts)
+		if err != nil {
+			return err
+		}
+		var str string
+		switch k := Key.(type) {
+		case []byte:
+			str = string(k)
+		case string:
+			str = k
+		case Atom:
+			str = string(k)
+		default:
+			return errors.New("key must be string")
+		}
+
+		// Unpack the value into the field, or skip it if there is no field.
+		index, ok := fields[str]
+		if !ok {
+			var raw RawData
+			err = processItem(&pointerSetter{ptr: reflect.ValueOf(&raw)}, r, opts)
+		} else {
+			err = processItem(&pointerSetter{ptr: v.Elem().Field(index).Addr()}, r, opts)
 		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Set the struct.
+	return setter.set(v)
+}
+
+// Used to process an atom during unpacking.
+func processAtom(Data []byte) interface{} {
+	switch string(Data) {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "nil":
 		return nil
 	default:
 		return Atom(Data)
 	}
 }
 
+// Used to resolve a ATOM_CACHE_REF. The tag of the atom is returned with a reader for the rest of the atom, so it
+// can be read like any other atom.
+func resolveAtomCacheRef(r unpackReader, opts *DecoderOptions) (byte, unpackReader, error) {
+	i, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, errors.New("not long enough to include atom cache reference")
+	}
+	if opts == nil || int(i) >= len(opts.AtomCacheRefs) {
+		return 0, nil, errors.New("atom cache reference is not in the distribution header")
+	}
+	a := opts.AtomCacheRefs[i]
+	if len(a) > 255 {
+		return 'v', bytes.NewReader(append([]byte{byte(len(a) >> 8), byte(len(a))}, a...)), nil
+	}
+	return 'w', bytes.NewReader(append([]byte{byte(len(a))}, a...)), nil
+}
+
+// Defines the deepest data can be nested before unpacking fails. This stops malformed data from exhausting the stack.
+const maxDepth = 10000
+
+// Defines the deepest map keys can be nested within other map keys. Keys which can't be used as a Go map key are
+// packed again to get their canonical encoding, so without this the time taken grows with the square of the size.
+const maxKeyDepth = 100
+
+// Defines the largest number of items which will be allocated up front, since the length can not be trusted.
+const maxCapacityHint = 1024
+
+// Used to get the capacity to allocate for a number of items specified by the data.
+func capacityHint(l uint32) int {
+	if l > maxCapacityHint {
+		return maxCapacityHint
+	}
+	return int(l)
+}
+
+// Used to read a number of bytes specified by the data. Large lengths are read in chunks so the allocation can not be
+// larger than the data actually is.
+func readBytes(r io.Reader, l uint32) ([]byte, error) {
+	if l <= 65536 {
+		b := make([]byte, l)
+		_, err := io.ReadFull(r, b)
+		return b, err
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, 65536))
+	if _, err := io.CopyN(buf, r, int64(l)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Used to read the arity of a tuple.
+func readTupleArity(DataType byte, r unpackReader) (uint32, error) {
+	if DataType == 'h' {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, errors.New("failed to read tuple arity")
+		}
+		return uint32(b), nil
+	}
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return 0, errors.New("not enough bytes for tuple arity")
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
 // Process the raw data.
-func processRawData(DataType byte, setter *pointerSetter, r unpackReader, jsonType bool) error {
+func processRawData(DataType byte, setter *pointerSetter, r unpackReader, jsonType bool, opts *DecoderOptions) error {
+	// Make sure the data isn't nested too deeply.
+	if opts.depth++; opts.depth > maxDepth {
+		return errors.New("data is nested too deeply")
+	}
+	defer func() { opts.depth-- }()
+
+	// Replace atom cache references with the atom, so the raw data can be used without the distribution header.
+	if DataType == 'R' {
+		var err error
+		if DataType, r, err = resolveAtomCacheRef(r, opts); err != nil {
+			return err
+		}
+	}
+
 	// Defines the byte array it'll go into.
 	var bytes []byte
 
 	// Get the right data type.
 	switch DataType {
-	case 's': // atom
-		b, err := r.ReadByte()
+	case 's', 'w': // small atom
+		Data, err := readAtomData(DataType, r)
 		if err != nil {
 			return err
 		}
-		Len := int(b)
-		bytes = make([]byte, Len+2)
-		bytes[0] = 's'
-		bytes[1] = b
-		for Total := 0; Total != Len; Total++ {
-			b, err := r.ReadByte()
-			if err != nil {
-				return errors.New("atom size larger than remainder of array")
-			}
-			bytes[Total+2] = b
+		bytes = append([]byte{DataType, byte(len(Data))}, Data...)
+	case 'd', 'v': // atom
+		Data, err := readAtomData(DataType, r)
+		if err != nil {
+			return err
 		}
+		bytes = append([]byte{DataType, byte(len(Data) >> 8), byte(len(Data))}, Data...)
 	case 'j': // blank list
 		bytes = []byte{'j'}
 	case 'l': // list
 		// Get the length of the list.
 		lengthBytes := make([]byte, 4)
-		_, err := r.Read(lengthBytes)
+		_, err := io.ReadFull(r, lengthBytes)
 		if err != nil {
 			return errors.New("not enough bytes for list length")
 		}
 		l := binary.BigEndian.Uint32(lengthBytes)
-		bytes = make([]byte, 5, (l*3)+5)
+		bytes = make([]byte, 5, capacityHint(l)*3+5)
 		bytes[0] = 'l'
 		for i := uint8(0); i < 4; i++ {
 			bytes[i+1] = lengthBytes[i]
 		}
 
-		// Try and get each item from the list.
+		// Try and get each item from the list (including the tail).
+		for i := 0; i < int(l)+1; i++ {
+			DataType, err := r.ReadByte()
+			if err != nil {
+				if i == int(l) {
+					return errors.New("not enough bytes for list tail")
+				}
+				return errors.New("not long enough to include data type")
+			}
+			var raw RawData
+			itemSetter := &pointerSetter{ptr: reflect.ValueOf(&raw)}
+			if err = processRawData(DataType, itemSetter, r, false, opts); err != nil {
+				return err
+			}
+			bytes = append(bytes, raw...)
+		}
+	case 'h', 'i': // tuple
+		// Get the arity of the tuple.
+		l, err := readTupleArity(DataType, r)
+		if err != nil {
+			return err
+		}
+		bytes = []byte{DataType}
+		if DataType == 'h' {
+			bytes = append(bytes, byte(l))
+		} else {
+			bytes = append(bytes, byte(l>>24), byte(l>>16), byte(l>>8), byte(l))
+		}
+
+		// Try and get each item from the tuple.
 		for i := 0; i < int(l); i++ {
 			DataType, err := r.ReadByte()
 			if err != nil {
@@ -404,7 +854,7 @@ func processRawData(DataType byte, setter *pointerSetter, r unpackReader, jsonTy
 			}
 			var raw RawData
 			itemSetter := &pointerSetter{ptr: reflect.ValueOf(&raw)}
-			if err = processRawData(DataType, itemSetter, r, false); err != nil {
+			if err = processRawData(DataType, itemSetter, r, false, opts); err != nil {
 				return err
 			}
 			bytes = append(bytes, raw...)
@@ -412,28 +862,28 @@ func processRawData(DataType byte, setter *pointerSetter, r unpackReader, jsonTy
 	case 'm': // string
 		// Get the length of the string.
 		lengthBytes := make([]byte, 4)
-		_, err := r.Read(lengthBytes)
+		_, err := io.ReadFull(r, lengthBytes)
 		if err != nil {
 			return errors.New("not enough bytes for list length")
 		}
 		l := binary.BigEndian.Uint32(lengthBytes)
 
-		// Create the byte array.
-		Len := int(l)
-		bytes = make([]byte, Len+5)
-		bytes[0] = 'm'
-		for i := uint8(0); i < 4; i++ {
-			bytes[i+1] = lengthBytes[i]
+		// Read the string.
+		data, err := readBytes(r, l)
+		if err != nil {
+			return errors.New("string size larger than remainder of array")
 		}
-
-		// Write each byte.
-		for Total := 0; Total != Len; Total++ {
-			b, err := r.ReadByte()
-			if err != nil {
-				return errors.New("atom size larger than remainder of array")
-			}
-			bytes[Total+5] = b
+		bytes = append(append([]byte{'m'}, lengthBytes...), data...)
+	case 'k': // string (a list of small integers)
+		lengthBytes := make([]byte, 2)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return errors.New("not enough bytes for string length")
 		}
+		data, err := readBytes(r, uint32(binary.BigEndian.Uint16(lengthBytes)))
+		if err != nil {
+			return errors.New("string length is longer than remainder of array")
+		}
+		bytes = append(append([]byte{'k'}, lengthBytes...), data...)
 	case 'a': // small int
 		i, err := r.ReadByte()
 		if err != nil {
@@ -442,7 +892,7 @@ func processRawData(DataType byte, setter *pointerSetter, r unpackReader, jsonTy
 		bytes = []byte{'a', i}
 	case 'b': // int32
 		b := make([]byte, 4)
-		_, err := r.Read(b)
+		_, err := io.ReadFull(r, b)
 		if err != nil {
 			return errors.New("not enough bytes for int32")
 		}
@@ -454,19 +904,32 @@ func processRawData(DataType byte, setter *pointerSetter, r unpackReader, jsonTy
 			return errors.New("unable to read int64 byte count")
 		}
 
-		// Create the byte array.
-		bytes = make([]byte, encodedBytes+2)
+		// Create the byte array (with room for the sign).
+		bytes = make([]byte, int(encodedBytes)+3)
 		bytes[0] = 'n'
 		bytes[1] = encodedBytes
 
-		// Write each byte.
-		for Total := uint8(0); Total != encodedBytes; Total++ {
-			b, err := r.ReadByte()
-			if err != nil {
-				return errors.New("int size larger than remainder of array")
-			}
-			bytes[Total+2] = b
+		// Write the sign and each byte.
+		if _, err := io.ReadFull(r, bytes[2:]); err != nil {
+			return errors.New("int size larger than remainder of array")
 		}
+	case 'o': // large big integer
+		// Get the number of encoded bytes.
+		lengthBytes := make([]byte, 4)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return errors.New("unable to read big integer byte count")
+		}
+		l := binary.BigEndian.Uint32(lengthBytes)
+		if l > maxBigBytes {
+			return errors.New("integer is too large")
+		}
+
+		// Read the sign and each byte.
+		data, err := readBytes(r, l+1)
+		if err != nil {
+			return errors.New("int size larger than remainder of array")
+		}
+		bytes = append(append([]byte{'o'}, lengthBytes...), data...)
 	case 'F': // float
 		// Get the next 8 bytes.
 		bytes = make([]byte, 9)
@@ -478,15 +941,33 @@ func processRawData(DataType byte, setter *pointerSetter, r unpackReader, jsonTy
 			}
 			bytes[i+1] = b
 		}
+	case 'P': // compressed
+		// Inflate the term and get the raw data of that instead.
+		inflated, err := inflateTerm(r, opts.MaxInflatedSize)
+		if err != nil {
+			return err
+		}
+		DataType, err := inflated.ReadByte()
+		if err != nil {
+			return errors.New("not long enough to include data type")
+		}
+		return processRawData(DataType, setter, inflated, jsonType, opts)
+	case 'c': // legacy float
+		// Get the next 31 bytes.
+		bytes = make([]byte, 32)
+		bytes[0] = 'c'
+		if _, err := io.ReadFull(r, bytes[1:]); err != nil {
+			return errors.New("float size larger than remainder of array")
+		}
 	case 't': // map
 		// Get the length of the map.
 		lengthBytes := make([]byte, 4)
-		_, err := r.Read(lengthBytes)
+		_, err := io.ReadFull(r, lengthBytes)
 		if err != nil {
 			return errors.New("not enough bytes for list length")
 		}
 		l := binary.BigEndian.Uint32(lengthBytes)
-		bytes = make([]byte, 5, (l*6)+5)
+		bytes = make([]byte, 5, capacityHint(l)*6+5)
 		bytes[0] = 't'
 		for i := uint8(0); i < 4; i++ {
 			bytes[i+1] = lengthBytes[i]
@@ -500,7 +981,7 @@ func processRawData(DataType byte, setter *pointerSetter, r unpackReader, jsonTy
 			}
 			var raw RawData
 			itemSetter := &pointerSetter{ptr: reflect.ValueOf(&raw)}
-			if err = processRawData(DataType, itemSetter, r, false); err != nil {
+			if err = processRawData(DataType, itemSetter, r, false, opts); err != nil {
 				return err
 			}
 			bytes = append(bytes, raw...)
@@ -508,11 +989,43 @@ func processRawData(DataType byte, setter *pointerSetter, r unpackReader, jsonTy
 			if err != nil {
 				return errors.New("not long enough to include data type")
 			}
-			if err = processRawData(DataType, itemSetter, r, false); err != nil {
+			if err = processRawData(DataType, itemSetter, r, false, opts); err != nil {
 				return err
 			}
 			bytes = append(bytes, raw...)
 		}
+	case 'q': // export
+		if opts.DisallowFuns {
+			return errors.New("funs are not allowed")
+		}
+		bytes = []byte{'q'}
+		for i := 0; i < 3; i++ {
+			DataType, err := r.ReadByte()
+			if err != nil {
+				return errors.New("not long enough to include data type")
+			}
+			var raw RawData
+			itemSetter := &pointerSetter{ptr: reflect.ValueOf(&raw)}
+			if err = processRawData(DataType, itemSetter, r, false, opts); err != nil {
+				return err
+			}
+			bytes = append(bytes, raw...)
+		}
+	case 'p': // fun
+		if opts.DisallowFuns {
+			return errors.New("funs are not allowed")
+		}
+		f, err := processFun(r, opts)
+		if err != nil {
+			return err
+		}
+		bytes = f.raw
+	case 'g', 'X', 'f', 'Y', 'x', 'e', 'r', 'Z': // pid, port or reference
+		_, raw, err := readIdentifier(DataType, r, opts)
+		if err != nil {
+			return err
+		}
+		bytes = raw
 	default:
 		return errors.New("unknown data type")
 	}
@@ -527,174 +1040,273 @@ func processRawData(DataType byte, setter *pointerSetter, r unpackReader, jsonTy
 	}
 }
 
+// Used to process a term which does not contain other terms (atoms, binaries, integers and floats) during unpacking.
+// Integers are unpacked as the smallest type which their encoding fits in, or as a *big.Int if they are larger than 64 bits.
+func processScalar(DataType byte, r unpackReader) (interface{}, error) {
+	switch DataType {
+	case 's', 'd', 'v', 'w': // atom
+		// Get the atom information.
+		Data, err := readAtomData(DataType, r)
+		if err != nil {
+			return nil, err
+		}
+		return processAtom(Data), nil
+	case 'm': // string
+		// Get the length of the string.
+		lengthBytes := make([]byte, 4)
+		_, err := io.ReadFull(r, lengthBytes)
+		if err != nil {
+			return nil, errors.New("not enough bytes for list length")
+		}
+		l := binary.BigEndian.Uint32(lengthBytes)
+
+		// Read the string.
+		Data, err := readBytes(r, l)
+		if err != nil {
+			return nil, errors.New("string length is longer than remainder of array")
+		}
+		return Data, nil
+	case 'a': // small int
+		i, err := r.ReadByte()
+		if err != nil {
+			return nil, errors.New("failed to read small int")
+		}
+		return i, nil
+	case 'b': // int32
+		b := make([]byte, 4)
+		_, err := io.ReadFull(r, b)
+		if err != nil {
+			return nil, errors.New("not enough bytes for int32")
+		}
+		l := binary.BigEndian.Uint32(b)
+		return *(*int32)(unsafe.Pointer(&l)), nil
+	case 'n', 'o': // big integer
+		return readBig(DataType, r)
+	case 'F': // float
+		// Get the next 8 bytes.
+		encodedBytes := make([]byte, 8)
+
+		// Read said encoded bytes.
+		_, err := io.ReadFull(r, encodedBytes)
+		if err != nil {
+			return nil, errors.New("not enough bytes to decode")
+		}
+
+		// Get the item as a uint64.
+		i := binary.BigEndian.Uint64(encodedBytes)
+
+		// Turn it into a float64.
+		return *(*float64)(unsafe.Pointer(&i)), nil
+	case 'c': // legacy float
+		// Get the null padded string.
+		encodedBytes := make([]byte, 31)
+		if _, err := io.ReadFull(r, encodedBytes); err != nil {
+			return nil, errors.New("not enough bytes to decode")
+		}
+
+		// Parse the string without the padding.
+		f, err := strconv.ParseFloat(string(bytes.TrimRight(encodedBytes, "\x00 ")), 64)
+		if err != nil {
+			return nil, errors.New("invalid float string")
+		}
+		return f, nil
+	default:
+		return nil, errors.New("expected atom, binary, integer or float")
+	}
+}
+
 // Processes a item.
-func processItem(setter *pointerSetter, r unpackReader) error {
+func processItem(setter *pointerSetter, r unpackReader, opts *DecoderOptions) error {
 	// Gets the type of data.
 	DataType, err := r.ReadByte()
 	if err != nil {
 		return errors.New("not long enough to include data type")
 	}
+	return processTerm(DataType, setter, r, opts)
+}
+
+// Processes a item when the type of data has already been read.
+func processTerm(DataType byte, setter *pointerSetter, r unpackReader, opts *DecoderOptions) error {
+	// Make sure the data isn't nested too deeply.
+	if opts.depth++; opts.depth > maxDepth {
+		return errors.New("data is nested too deeply")
+	}
+	defer func() { opts.depth-- }()
+
+	// Process atom cache references as the atom they refer to.
+	if DataType == 'R' {
+		var err error
+		if DataType, r, err = resolveAtomCacheRef(r, opts); err != nil {
+			return err
+		}
+	}
+
+	// Inflate compressed terms and process the term within.
+	if DataType == 'P' {
+		inflated, err := inflateTerm(r, opts.MaxInflatedSize)
+		if err != nil {
+			return err
+		}
+		return processItem(setter, inflated, opts)
+	}
 
 	// Check if this is meant to be raw data and process that differently if so.
-	switch setter.getBasePtr().(type) {
+	Ptr, err := setter.getBasePtr()
+	if err != nil {
+		return err
+	}
+	switch Ptr.(type) {
 	case *json.RawMessage:
-		return processRawData(DataType, setter, r, true)
+		return processRawData(DataType, setter, r, true, opts)
 	case *RawData:
-		return processRawData(DataType, setter, r, false)
+		return processRawData(DataType, setter, r, false, opts)
+	case rawDataSetter, Unmarshaler:
+		var raw RawData
+		if err := processRawData(DataType, &pointerSetter{ptr: reflect.ValueOf(&raw)}, r, false, opts); err != nil {
+			return err
+		}
+		return setRawData(setter, Ptr, raw, opts)
 	}
 
 	// Handle the various different data types.
 	var Item interface{}
 	switch DataType {
-	case 's': // atom
-		// Get the atom information.
-		b, err := r.ReadByte()
+	case 's', 'd', 'v', 'w', 'm', 'a', 'b', 'n', 'o', 'F', 'c': // scalar
+		Item, err = processScalar(DataType, r)
 		if err != nil {
 			return err
 		}
-		Len := int(b)
-		Data := make([]byte, Len)
-		for Total := 0; Total != Len; Total++ {
-			b, err := r.ReadByte()
-			if err != nil {
-				return errors.New("atom size larger than remainder of array")
-			}
-			Data[Total] = b
-		}
-		Item = processAtom(Data)
 	case 'j': // blank list
 		Item = []interface{}{}
 	case 'l': // list
 		// Get the length of the list.
 		lengthBytes := make([]byte, 4)
-		_, err := r.Read(lengthBytes)
+		_, err := io.ReadFull(r, lengthBytes)
 		if err != nil {
 			return errors.New("not enough bytes for list length")
 		}
 		l := binary.BigEndian.Uint32(lengthBytes)
 
 		// Try and get each item from the list.
-		Item = make([]interface{}, l)
+		a := make([]interface{}, 0, capacityHint(l))
 		for i := 0; i < int(l); i++ {
 			var x interface{}
-			err := processItem(&pointerSetter{ptr: reflect.ValueOf(&x)}, r)
+			err := processItem(&pointerSetter{ptr: reflect.ValueOf(&x)}, r, opts)
 			if err != nil {
 				return err
 			}
-			Item.([]interface{})[i] = x
-		}
-	case 'm': // string
-		// Get the length of the string.
-		lengthBytes := make([]byte, 4)
-		_, err := r.Read(lengthBytes)
-		if err != nil {
-			return errors.New("not enough bytes for list length")
+			a = append(a, x)
 		}
-		l := binary.BigEndian.Uint32(lengthBytes)
-
-		// Make an array of the specified length.
-		Item = make([]byte, l)
+		Item = a
 
-		// Write into it if we can.
-		_, err = r.Read(Item.([]byte))
+		// Get the tail of the list.
+		tail, err := r.ReadByte()
 		if err != nil {
-			return errors.New("string length is longer than remainder of array")
+			return errors.New("not enough bytes for list tail")
 		}
-	case 'a': // small int
-		i, err := r.ReadByte()
-		if err != nil {
-			return errors.New("failed to read small int")
-		}
-		Item = i
-	case 'b': // int32
-		b := make([]byte, 4)
-		_, err := r.Read(b)
-		if err != nil {
-			return errors.New("not enough bytes for int32")
+		if tail != 'j' {
+			var t interface{}
+			if err = processTerm(tail, &pointerSetter{ptr: reflect.ValueOf(&t)}, r, opts); err != nil {
+				return err
+			}
+			Item = listWithTail(a, t)
 		}
-		l := binary.BigEndian.Uint32(b)
-		Item = *(*int32)(unsafe.Pointer(&l))
-	case 'n': // int64
-		// Get the number of encoded bytes.
-		encodedBytes, err := r.ReadByte()
-		if err != nil {
-			return errors.New("unable to read int64 byte count")
+	case 'k': // string (a list of small integers)
+		// Get the length of the string.
+		lengthBytes := make([]byte, 2)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return errors.New("not enough bytes for string length")
 		}
+		l := binary.BigEndian.Uint16(lengthBytes)
 
-		// Get the signature.
-		signatureChar, err := r.ReadByte()
+		// Read the characters.
+		chars, err := readBytes(r, uint32(l))
 		if err != nil {
-			return errors.New("unable to read int64 signature")
+			return errors.New("string length is longer than remainder of array")
 		}
-		negative := signatureChar == 1
-
-		// Create the uint64.
-		u := uint64(0)
-
-		// Decode the int64.
-		x := uint64(0)
-		for i := 0; i < int(encodedBytes); i++ {
-			// Read the next byte.
-			b, err := r.ReadByte()
-			if err != nil {
-				return errors.New("int64 length greater than array")
+		// Each character goes through the same options as any other small integer in a list.
+		a := make([]interface{}, len(chars))
+		for i, c := range chars {
+			if a[i], err = genericItem(c, opts); err != nil {
+				return err
 			}
-
-			// Add the byte.
-			u += uint64(b) * x
-			x <<= 8
 		}
-
-		// Turn the uint64 into a int64.
-		if negative {
-			Item = int64(u) * -1
-		} else {
-			Item = int64(u)
-		}
-	case 'F': // float
-		// Get the next 8 bytes.
-		encodedBytes := make([]byte, 8)
-
-		// Read said encoded bytes.
-		_, err := r.Read(encodedBytes)
+		Item = a
+	case 'h', 'i': // tuple
+		// Get the arity of the tuple.
+		l, err := readTupleArity(DataType, r)
 		if err != nil {
-			return errors.New("not enough bytes to decode")
+			return err
 		}
 
-		// Get the item as a uint64.
-		i := binary.BigEndian.Uint64(encodedBytes)
-
-		// Turn it into a float64.
-		Item = *(*float64)(unsafe.Pointer(&i))
+		// Try and get each item from the tuple.
+		t := make(Tuple, 0, capacityHint(l))
+		for i := 0; i < int(l); i++ {
+			var x interface{}
+			err := processItem(&pointerSetter{ptr: reflect.ValueOf(&x)}, r, opts)
+			if err != nil {
+				return err
+			}
+			t = append(t, x)
+		}
+		Item = t
 	case 't': // map
 		// Get the length.
 		b := make([]byte, 4)
-		_, err := r.Read(b)
+		_, err := io.ReadFull(r, b)
 		if err != nil {
 			return errors.New("not enough bytes for int32")
 		}
 		l := binary.BigEndian.Uint32(b)
 
+		// If this is a struct, unpack each value straight into its field.
+		if e := reflect.TypeOf(Ptr).Elem(); e.Kind() == reflect.Struct && e != uncastedResultType.Elem() {
+			if _, ok := reflect.PtrTo(e).MethodByName("UncastedErlpack"); !ok {
+				return processStruct(setter, e, l, r, opts)
+			}
+		}
+
+		// If this is an ordered map, keep the original keys in order.
+		if _, ok := Ptr.(*OrderedMap); ok || opts.orderedMaps {
+			o := make(OrderedMap, 0, capacityHint(l))
+			for i := uint32(0); i < l; i++ {
+				var item MapItem
+				err := processItem(&pointerSetter{ptr: reflect.ValueOf(&item.Key)}, r, opts)
+				if err != nil {
+					return err
+				}
+				err = processItem(&pointerSetter{ptr: reflect.ValueOf(&item.Value)}, r, opts)
+				if err != nil {
+					return err
+				}
+				o = append(o, item)
+			}
+			return setter.set(reflect.ValueOf(&o))
+		}
+
 		// Create the map.
-		m := make(map[interface{}]interface{}, l)
+		m := make(map[interface{}]interface{}, capacityHint(l))
 
 		// Get each item from the map.
 		for i := uint32(0); i < l; i++ {
 			// Get the key.
+			if opts.keyDepth++; opts.keyDepth > maxKeyDepth {
+				return errors.New("map keys are nested too deeply")
+			}
 			var Key interface{}
-			err := processItem(&pointerSetter{ptr: reflect.ValueOf(&Key)}, r)
+			err := processItem(&pointerSetter{ptr: reflect.ValueOf(&Key)}, r, opts)
+			opts.keyDepth--
 			if err != nil {
 				return err
 			}
-			switch x := Key.(type) {
-			case []byte:
-				// bytes should be stored as strings for maps
-				Key = string(x)
+			Key, err = HashableKey(Key)
+			if err != nil {
+				return err
 			}
 
 			// Get the value.
 			var Value interface{}
-			err = processItem(&pointerSetter{ptr: reflect.ValueOf(&Value)}, r)
+			err = processItem(&pointerSetter{ptr: reflect.ValueOf(&Value)}, r, opts)
 			if err != nil {
 				return err
 			}
@@ -705,12 +1317,33 @@ func processItem(setter *pointerSetter, r unpackReader) error {
 
 		// Set the item to the map.
 		Item = m
+	case 'q': // export
+		if opts.DisallowFuns {
+			return errors.New("funs are not allowed")
+		}
+		Item, err = processExport(r, opts)
+		if err != nil {
+			return err
+		}
+	case 'p': // fun
+		if opts.DisallowFuns {
+			return errors.New("funs are not allowed")
+		}
+		Item, err = processFun(r, opts)
+		if err != nil {
+			return err
+		}
+	case 'g', 'X', 'f', 'Y', 'x', 'e', 'r', 'Z': // pid, port or reference
+		Item, _, err = readIdentifier(DataType, r, opts)
+		if err != nil {
+			return err
+		}
 	default: // Don't know this data type.
 		return errors.New("unknown data type")
 	}
 
 	// Handle the item casting.
-	return handleItemCasting(Item, setter)
+	return handleItemCasting(Item, setter, opts)
 }
 
 // Create a special reader that handles all types we need for ease of user.
@@ -725,17 +1358,22 @@ func (r *byteReaderUpgrader) ReadByte() (byte, error) {
 		return reader.ReadByte()
 	}
 	ob := make([]byte, 1)
-	_, err := r.Reader.Read(ob)
+	_, err := io.ReadFull(r.Reader, ob)
 	return ob[0], err
 }
 
 // UnpackReader is used to unpack a value to a pointer from a reader.
 // Note that to ensure compatibility in codebases where you have both erlpack and json, json.RawMessage is treated the same as erlpack.RawData.
 func UnpackReader(reader io.Reader, Ptr interface{}) error {
+	return UnpackReaderWithOptions(reader, Ptr, DecoderOptions{})
+}
+
+// UnpackReaderWithOptions is used to unpack a value to a pointer from a reader with the decoder options specified.
+func UnpackReaderWithOptions(reader io.Reader, Ptr interface{}, Options DecoderOptions) error {
 	// Check if the ptr is actually a pointer.
 	v := &pointerSetter{ptr: reflect.ValueOf(Ptr)}
-	if v.ptr.Kind() != reflect.Ptr {
-		return errors.New("invalid pointer")
+	if err := v.check(); err != nil {
+		return err
 	}
 
 	// The invalid erlpack handler.
@@ -753,15 +1391,20 @@ func UnpackReader(reader io.Reader, Ptr interface{}) error {
 	}
 
 	// Return the data unpacking.
-	return processItem(v, r)
+	return processItem(v, r, &Options)
 }
 
 // Unpack is used to unpack a value to a pointer.
 // Note that to ensure compatibility in codebases where you have both erlpack and json, json.RawMessage is treated the same as erlpack.RawData.
 func Unpack(Data []byte, Ptr interface{}) error {
+	return UnpackWithOptions(Data, Ptr, DecoderOptions{})
+}
+
+// UnpackWithOptions is used to unpack a value to a pointer with the decoder options specified.
+func UnpackWithOptions(Data []byte, Ptr interface{}, Options DecoderOptions) error {
 	l := len(Data)
 	if 2 > l {
 		return errors.New("erlpack bytes cannot be shorter than 2 bytes")
 	}
-	return UnpackReader(bytes.NewReader(Data), Ptr)
+	return UnpackReaderWithOptions(bytes.NewReader(Data), Ptr, Options)
 }
@@ -1,10 +1,14 @@
 package erlpack
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"reflect"
 	"testing"
+	"testing/iotest"
 )
 
 // TestUnpackTrue is used to unpack the true boolean.
@@ -144,7 +148,7 @@ func TestUnpackGenericArray(t *testing.T) {
 
 // TestUnpackcArray is used to unpack a array.
 func TestUnpackArray(t *testing.T) {
-	packed := []byte("\x83l\x00\x00\x00\x01a\x01")
+	packed := []byte("\x83l\x00\x00\x00\x01a\x01j")
 	var a []int
 	err := Unpack(packed, &a)
 	if err != nil {
@@ -161,11 +165,11 @@ func TestUnpackArray(t *testing.T) {
 // TestUnpackArrayRawData is used to unpack a array as RawData.
 func TestUnpackArrayRawData(t *testing.T) {
 	var r RawData
-	err := Unpack([]byte("\x83l\x00\x00\x00\x01a\x01"), &r)
+	err := Unpack([]byte("\x83l\x00\x00\x00\x01a\x01j"), &r)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = bytesAssert([]byte("l\x00\x00\x00\x01a\x01"), r)
+	err = bytesAssert([]byte("l\x00\x00\x00\x01a\x01j"), r)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -207,6 +211,31 @@ func TestUnpack32BitInt(t *testing.T) {
 	}
 }
 
+// TestUnpackLegacyFloat is used to test a FLOAT_EXT float.
+func TestUnpackLegacyFloat(t *testing.T) {
+	var f float64
+	err := Unpack([]byte("\x83c3.10000000000000008882e+00\x00\x00\x00\x00\x00"), &f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f != 3.1 {
+		t.Fatal("unexpected result:", f)
+	}
+	var r RawData
+	err = Unpack([]byte("\x83l\x00\x00\x00\x01c-1.00000000000000000000e+02\x00\x00\x00\x00j"), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a []float64
+	err = r.Cast(&a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 1 || a[0] != -100 {
+		t.Fatal("unexpected result:", a)
+	}
+}
+
 // TestUnpackGenericMap is used to test a generic map.
 func TestUnpackGenericMap(t *testing.T) {
 	var x map[interface{}]interface{}
@@ -323,6 +352,125 @@ func TestUnpackUncastedResult(t *testing.T) {
 	}
 }
 
+// TestUnpackInvalidPointer is used to test that invalid pointers return a error rather than panicking.
+func TestUnpackInvalidPointer(t *testing.T) {
+	var p *int
+	for _, ptr := range []interface{}{nil, 1, p} {
+		if err := Unpack([]byte("\x83a\x01"), ptr); err == nil {
+			t.Fatal("expected error for", ptr)
+		}
+	}
+}
+
+// TestUnpackFixedArray is used to test unpacking a list into a array.
+func TestUnpackFixedArray(t *testing.T) {
+	var a [3]int
+	err := Unpack([]byte("\x83l\x00\x00\x00\x02a\x01a\x02j"), &a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != [3]int{1, 2, 0} {
+		t.Fatal("unexpected result:", a)
+	}
+	var b [1]int
+	if err = Unpack([]byte("\x83l\x00\x00\x00\x02a\x01a\x02j"), &b); err == nil {
+		t.Fatal("expected error")
+	}
+	var i int
+	if err = Unpack([]byte("\x83l\x00\x00\x00\x02a\x01a\x02j"), &i); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// TestUnpackDeeplyNested is used to test that deeply nested data returns a error rather than exhausting the stack.
+func TestUnpackDeeplyNested(t *testing.T) {
+	data := append([]byte{131}, bytes.Repeat([]byte("l\x00\x00\x00\x01"), 1000000)...)
+	var x interface{}
+	if err := Unpack(data, &x); err == nil {
+		t.Fatal("expected error")
+	}
+	var r RawData
+	if err := Unpack(data, &r); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// TestUnpackLargeLength is used to test that a large length does not allocate more than the data.
+func TestUnpackLargeLength(t *testing.T) {
+	for _, data := range []string{"\x83l\xff\xff\xff\xff", "\x83m\xff\xff\xff\xff", "\x83t\xff\xff\xff\xff", "\x83i\xff\xff\xff\xff"} {
+		var x interface{}
+		if err := Unpack([]byte(data), &x); err == nil {
+			t.Fatal("expected error")
+		}
+		var r RawData
+		if err := Unpack([]byte(data), &r); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+// TestUnpackShortReads is used to test that readers which return less bytes than asked for are read until the end.
+func TestUnpackShortReads(t *testing.T) {
+	data := "\x83t\x00\x00\x00\x01m\x00\x00\x00\x05hellol\x00\x00\x00\x02b\x00\x00\x01\x00F\x3f\xf8\x00\x00\x00\x00\x00\x00j"
+	var x map[string][]interface{}
+	if err := UnpackReader(iotest.OneByteReader(bytes.NewReader([]byte(data))), &x); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(x, map[string][]interface{}{"hello": {int32(256), 1.5}}) {
+		t.Fatal("unexpected result:", x)
+	}
+	var r RawData
+	if err := UnpackReader(iotest.OneByteReader(bytes.NewReader([]byte(data))), &r); err != nil {
+		t.Fatal(err)
+	}
+	if string(r) != data[1:] {
+		t.Fatalf("unexpected raw data: %q", r)
+	}
+
+	// A list which ends before the tail should error rather than leaving the tail out.
+	truncated := "\x83l\x00\x00\x00\x01a\x01"
+	var l []interface{}
+	if err := UnpackReader(iotest.OneByteReader(bytes.NewReader([]byte(truncated))), &l); err == nil {
+		t.Fatal("expected error for list without a tail")
+	}
+	if err := UnpackReader(iotest.OneByteReader(bytes.NewReader([]byte(truncated))), &r); err == nil {
+		t.Fatal("expected error for raw list without a tail")
+	}
+}
+
+// TestUnpackEmptyAtom is used to test that a empty atom unpacks rather than panicking.
+func TestUnpackEmptyAtom(t *testing.T) {
+	for _, data := range []string{"\x83s\x00", "\x83w\x00", "\x83d\x00\x00"} {
+		var x interface{}
+		if err := Unpack([]byte(data), &x); err != nil {
+			t.Fatal(err)
+		}
+		if x != Atom("") {
+			t.Fatal("unexpected result:", x)
+		}
+	}
+}
+
+// TestUnpackUint64 is used to test that integers which only fit in a uint64 unpack as one.
+func TestUnpackUint64(t *testing.T) {
+	data := []byte("\x83n\x08\x00\xff\xff\xff\xff\xff\xff\xff\xff")
+	var x interface{}
+	if err := Unpack(data, &x); err != nil || x != uint64(math.MaxUint64) {
+		t.Fatal("unexpected result:", x, err)
+	}
+	var u uint64
+	if err := Unpack(data, &u); err != nil || u != math.MaxUint64 {
+		t.Fatal("unexpected result:", u, err)
+	}
+	var i int64
+	if err := Unpack(data, &i); err == nil {
+		t.Fatal("expected error unpacking into a int64")
+	}
+	if err := Unpack([]byte("\x83n\x01\x01\x01"), &u); err == nil {
+		t.Fatal("expected error unpacking a negative integer into a uint64")
+	}
+}
+
 // BenchmarkUnpack is used to benchmark unpacking.
 func BenchmarkUnpack(b *testing.B) {
 	type test struct {
@@ -349,3 +497,261 @@ func BenchmarkLargerUnpack(b *testing.B) {
 		b.Fatal(err)
 	}
 }
+
+// TestUnpackGenericOptions is used to test the decoder options for unpacking into a interface{}.
+func TestUnpackGenericOptions(t *testing.T) {
+	b, err := Pack(OrderedMap{
+		{Key: "a", Value: []interface{}{[]byte("x"), Atom("y"), uint8(1), int32(-2), int64(1) << 40, 1.0, true}},
+		{Key: Atom("b"), Value: map[string]interface{}{"c": 3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DecoderOptions{BinariesAsStrings: true, AtomsAsStrings: true, MapsAsStringKeyed: true, IntegersAsInt64: true}
+	expected := map[string]interface{}{
+		"a": []interface{}{"x", "y", int64(1), int64(-2), int64(1) << 40, 1.0, true},
+		"b": map[string]interface{}{"c": int64(3)},
+	}
+	var v interface{}
+	if err = UnpackWithOptions(b, &v, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, v)
+	}
+
+	// The same result should come from casting a uncasted result.
+	var u UncastedResult
+	if err = UnpackWithOptions(b, &u, opts); err != nil {
+		t.Fatal(err)
+	}
+	v = nil
+	if err = u.Cast(&v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, v)
+	}
+
+	// Typed targets should still work with the options set.
+	var typed []map[string]int
+	if err = UnpackWithOptions([]byte("\x83l\x00\x00\x00\x01t\x00\x00\x00\x01m\x00\x00\x00\x01ca\x03j"), &typed, opts); err != nil {
+		t.Fatal(err)
+	}
+	if typed[0]["c"] != 3 {
+		t.Fatalf("unexpected result: %#v", typed)
+	}
+	var list []string
+	if err = UnpackWithOptions([]byte("\x83l\x00\x00\x00\x01s\x01aj"), &list, opts); err != nil || list[0] != "a" {
+		t.Fatalf("unexpected result: %#v (%v)", list, err)
+	}
+
+	// Keys which aren't strings should error.
+	if err = UnpackWithOptions([]byte("\x83t\x00\x00\x00\x01a\x01a\x02"), &v, DecoderOptions{MapsAsStringKeyed: true}); err == nil {
+		t.Fatal("expected error for integer key")
+	}
+}
+
+// TestUnpackUseNumber is used to test unpacking numbers into a interface{} as a Number.
+func TestUnpackUseNumber(t *testing.T) {
+	b, err := Pack([]interface{}{uint8(1), int32(-2), uint64(1) << 63, 1.0, 0.25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	if err = UnpackWithOptions(b, &v, DecoderOptions{UseNumber: true, IntegersAsInt64: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{Number("1"), Number("-2"), Number("9223372036854775808"), Number("1.0"), Number("0.25")}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, v)
+	}
+
+	// Numbers should pack back to the same term.
+	b2, err := Pack(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, b2) {
+		t.Fatalf("expected % x, got % x", b, b2)
+	}
+
+	// Numbers should cast into typed targets.
+	var n Number
+	if err = Unpack([]byte("\x83b\xff\xff\xff\xfe"), &n); err != nil || n != "-2" {
+		t.Fatalf("unexpected result: %v (%v)", n, err)
+	}
+	var i []int
+	if err = UnpackWithOptions([]byte("\x83l\x00\x00\x00\x02a\x01a\x02j"), &i, DecoderOptions{UseNumber: true}); err != nil || i[1] != 2 {
+		t.Fatalf("unexpected result: %v (%v)", i, err)
+	}
+}
+
+// TestUnpackNamedKinds is used to test unpacking into types defined with a basic kind and smaller integer types.
+func TestUnpackNamedKinds(t *testing.T) {
+	type opcode uint16
+	type status string
+	var v struct {
+		Op     opcode `erlpack:"op"`
+		Status status `erlpack:"status"`
+		Small  int8   `erlpack:"small"`
+	}
+	b, err := Pack(OrderedMap{
+		{Key: Atom("op"), Value: 10},
+		{Key: Atom("status"), Value: Atom("online")},
+		{Key: "small", Value: -5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Unpack(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Op != 10 || v.Status != "online" || v.Small != -5 {
+		t.Fatalf("unexpected result: %+v", v)
+	}
+
+	// Values which overflow the type should error.
+	var small int8
+	if err = Unpack([]byte("\x83a\xff"), &small); err == nil {
+		t.Fatal("expected overflow error")
+	}
+}
+
+// TestUnpackAtomCacheRefs is used to test atom cache references are resolved with the atoms from the options.
+func TestUnpackAtomCacheRefs(t *testing.T) {
+	// {R0, <R1.1.0>, fun R0:R2/1}
+	b := []byte("\x83h\x03R\x00XR\x01\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x05qR\x00R\x02a\x01")
+	opts := DecoderOptions{AtomCacheRefs: []Atom{"erlang", "a@localhost", "node"}}
+	var tuple Tuple
+	if err := UnpackWithOptions(b, &tuple, opts); err != nil {
+		t.Fatal(err)
+	}
+	pid := Pid{Node: "a@localhost", ID: 1, Creation: 5}
+	if tuple[0] != Atom("erlang") || tuple[1] != pid || tuple[2] != (Export{Module: "erlang", Function: "node", Arity: 1}) {
+		t.Fatalf("unexpected result: %v", tuple)
+	}
+
+	// Raw data should have the atoms in it, so it can be cast without the references.
+	var raw RawData
+	if err := UnpackWithOptions(b, &raw, opts); err != nil {
+		t.Fatal(err)
+	}
+	var again Tuple
+	if err := raw.Cast(&again); err != nil || again[1] != pid || again[2] != tuple[2] {
+		t.Fatalf("unexpected result: %v (%v)", again, err)
+	}
+
+	// References which aren't in the list should error.
+	if err := Unpack(b, &tuple); err == nil {
+		t.Fatal("expected error without atom cache refs")
+	}
+	opts.AtomCacheRefs = opts.AtomCacheRefs[:2]
+	if err := UnpackWithOptions(b, &tuple, opts); err == nil {
+		t.Fatal("expected error for reference outside of the list")
+	}
+}
+
+// TestUnpackStringExt is used to test unpacking STRING_EXT as a list.
+func TestUnpackStringExt(t *testing.T) {
+	var v interface{}
+	if err := Unpack([]byte("\x83k\x00\x02ab"), &v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, []interface{}{uint8('a'), uint8('b')}) {
+		t.Fatalf("unexpected result: %#v", v)
+	}
+	var b []uint8
+	if err := Unpack([]byte("\x83k\x00\x02ab"), &b); err != nil || string(b) != "ab" {
+		t.Fatalf("unexpected result: %v (%v)", b, err)
+	}
+	var r RawData
+	if err := Unpack([]byte("\x83k\x00\x02ab"), &r); err != nil || string(r) != "k\x00\x02ab" {
+		t.Fatalf("unexpected raw data: %q (%v)", r, err)
+	}
+	if err := Unpack([]byte("\x83k\x00\x03ab"), &v); err == nil {
+		t.Fatal("expected error for truncated string")
+	}
+
+	// The characters should follow the integer options like any other list.
+	opts := DecoderOptions{IntegersAsInt64: true}
+	if err := UnpackWithOptions([]byte("\x83k\x00\x02ab"), &v, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, []interface{}{int64('a'), int64('b')}) {
+		t.Fatalf("unexpected result: %#v", v)
+	}
+	opts.UseNumber = true
+	if err := UnpackWithOptions([]byte("\x83k\x00\x02ab"), &v, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, []interface{}{Number("97"), Number("98")}) {
+		t.Fatalf("unexpected result: %#v", v)
+	}
+	if err := UnpackWithOptions([]byte("\x83k\x00\x02ab"), &b, opts); err != nil || string(b) != "ab" {
+		t.Fatalf("unexpected result: %v (%v)", b, err)
+	}
+}
+
+// TestUnpackImproperList is used to test unpacking lists which don't end with a blank list.
+func TestUnpackImproperList(t *testing.T) {
+	data := []byte("\x83l\x00\x00\x00\x02a\x01a\x02w\x01x")
+	expected := ImproperList{Items: []interface{}{uint8(1), uint8(2)}, Tail: Atom("x")}
+	var v interface{}
+	if err := Unpack(data, &v); err != nil || !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected %#v, got %#v (%v)", expected, v, err)
+	}
+	var l ImproperList
+	if err := Unpack(data, &l); err != nil || !reflect.DeepEqual(l, expected) {
+		t.Fatalf("expected %#v, got %#v (%v)", expected, l, err)
+	}
+	var a []int
+	if err := Unpack(data, &a); err == nil {
+		t.Fatal("expected error unpacking a improper list into a slice")
+	}
+}
+
+// TestUnpackLargeBig is used to test unpacking LARGE_BIG_EXT integers.
+func TestUnpackLargeBig(t *testing.T) {
+	var v interface{}
+	if err := Unpack([]byte("\x83o\x00\x00\x00\x02\x01\x01\x01"), &v); err != nil || v != int64(-257) {
+		t.Fatalf("expected -257, got %#v (%v)", v, err)
+	}
+	if err := Unpack([]byte("\x83o\x00\x00\x00\x09\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01"), &v); err != nil {
+		t.Fatal(err)
+	}
+	if x, ok := v.(*big.Int); !ok || x.String() != "18446744073709551616" {
+		t.Fatalf("unexpected result: %#v", v)
+	}
+	if err := Unpack([]byte("\x83o\x00\x00\x00\x09\x00\x01"), &v); err == nil {
+		t.Fatal("expected error for truncated integer")
+	}
+	if err := Unpack([]byte("\x83o\xff\xff\xff\xff\x00"), &v); err == nil {
+		t.Fatal("expected error for integer which is too large")
+	}
+}
+
+// TestReaderOptions is used to test a reader uses the decoder options it was created with.
+func TestReaderOptions(t *testing.T) {
+	data := []byte("m\x00\x00\x00\x01aP\x00\x00\x00\x69x\x9c\xcbe``HI\xa4\x03\x00\x00\xceu&\xb6")
+	r := NewReaderWithOptions(data, DecoderOptions{BinariesAsStrings: true, MaxInflatedSize: 104})
+	var v interface{}
+	if err := r.ReadValue(&v); err != nil || v != "a" {
+		t.Fatalf("expected string, got %#v (%v)", v, err)
+	}
+	if _, err := r.ReadRaw(); err == nil {
+		t.Fatal("expected error for term larger than the maximum inflated size")
+	}
+
+	// The default reader should unpack binaries as bytes and inflate the term.
+	r = NewReader(data)
+	if err := r.ReadValue(&v); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.([]byte); !ok {
+		t.Fatalf("expected bytes, got %#v", v)
+	}
+	if _, err := r.ReadBinary(); err != nil {
+		t.Fatal(err)
+	}
+}